@@ -3,27 +3,62 @@
 // Usage:
 //
 //	piphos ping [-beacon=PROVIDER]                     # Detect public IP
-//	piphos pull [-tender=PROVIDER]                     # Retrieve all tracked hosts
+//	piphos pull [-tender=PROVIDER -o=FORMAT]           # Retrieve all tracked hosts
+//	piphos get [-family=4|6 -wait=DURATION] <host>     # Print a single host's IP
 //	piphos push [-tender=PROVIDER -beacon=PROVIDER]    # Update current hostname's IP
+//	piphos watch [-interval=DURATION -heartbeat=DUR]   # Push on IP changes, instead of cron
+//	piphos ssh [user@]<host> [ssh args]                # Connect to a host with ssh
+//	piphos ssh-config [-file=PATH]                     # Generate ssh_config Host blocks
+//	piphos hosts sync [-domain=DOMAIN -dry-run]        # Write hosts into /etc/hosts
+//	piphos serve-dns [-listen=ADDR -zone=ZONE]         # Answer DNS queries for hosts
+//	piphos auth check [-tender=PROVIDER]               # Verify credentials and permissions
+//	piphos namespaces [-tender=PROVIDER]               # List namespaces
+//	piphos history [-since=DURATION -o=FORMAT] [host]  # Show when IPs changed
+//	piphos prune [-older-than=DURATION -dry-run]       # Remove hosts that stopped reporting
+//	piphos rm <host>                                   # Remove a host
+//	piphos mv <old> <new>                              # Rename a host
+//	piphos set <host> <ip>                             # Set a host's IP by hand
+//	piphos key generate|rotate                         # Manage the encryption key
+//	piphos identity                                    # Print this host's signing key
+//	piphos trust add|revoke|list                       # Manage trusted signing keys
+//	piphos config show [-profile=NAME]                 # Print the effective configuration
+//	piphos migrate -from=TENDER -to=TENDER             # Copy all hosts to another tender
+//	piphos export [-format=json|csv -file=PATH]        # Back up all hosts
+//	piphos import [-conflict=STRATEGY] <file>          # Load hosts from a JSON or CSV file
 //
-// The push and pull commands require the PIPHOS_GITHUB_TOKEN environment variable.
+// The -namespace flag (or PIPHOS_NAMESPACE) selects an independent set of hosts.
+// Flag defaults can be set in ~/.config/piphos/config.toml or /etc/piphos/config.toml,
+// with named profiles selected by -profile (or PIPHOS_PROFILE).
+//
+// The push and pull commands require a GitHub token, read from PIPHOS_GITHUB_TOKEN,
+// PIPHOS_GITHUB_TOKEN_FILE, GITHUB_TOKEN, GH_TOKEN, the gh CLI or the OS keyring.
+// Hosts can also be kept in a local directory (-tender=file) or an S3 bucket
+// (-tender=s3), or in several tenders at once (-tender=gh,s3).
 //
 // Examples:
 //
 //	export PIPHOS_GITHUB_TOKEN=ghp_xxx
 //	piphos ping                    # 203.0.113.42
 //	piphos push                    # 203.0.113.42
-//	piphos pull                    # laptop: 203.0.113.42
+//	piphos pull -o json            # all hosts as JSON
+//	ssh admin@$(piphos get laptop)
 package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	osexec "os/exec"
 
 	"github.com/kappapee/piphos/internal/exec"
+	"github.com/kappapee/piphos/internal/tender"
 )
 
+// exitHostNotFound is the exit status of get when the host is unknown, so scripts can
+// tell it apart from other failures, which exit with 1, and from invalid flags, which exit with 2.
+const exitHostNotFound = 3
+
 func main() {
 	if len(os.Args) < 2 {
 		exec.Help()
@@ -40,21 +75,194 @@ func main() {
 		}
 		fmt.Fprintln(os.Stdout, publicIP)
 	case "pull":
-		data, err := exec.Pull(ctx, os.Args[2:])
-		if err != nil {
+		if _, err := exec.Pull(ctx, os.Args[2:], os.Stdout); err != nil {
 			fmt.Fprintf(os.Stderr, "failed to run pull command: %v\n", err)
+			if !tender.Partial(err) {
+				exec.Help()
+			}
+			os.Exit(1)
+		}
+	case "get":
+		// No help on failure, it would end up in the output of $(piphos get host)
+		if _, err := exec.Get(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run get command: %v\n", err)
+			if errors.Is(err, tender.ErrHostNotFound) {
+				os.Exit(exitHostNotFound)
+			}
+			os.Exit(1)
+		}
+	case "watch":
+		if err := exec.Watch(ctx, os.Args[2:], os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run watch command: %v\n", err)
 			exec.Help()
 			os.Exit(1)
 		}
-		for k, v := range data {
-			fmt.Fprintf(os.Stdout, "%s: %s\n", k, v)
+	case "ssh":
+		if err := exec.SSH(ctx, os.Args[2:]); err != nil {
+			// ssh reports its own failures, only pass on its exit status
+			var exitErr *osexec.ExitError
+			if errors.As(err, &exitErr) {
+				os.Exit(exitErr.ExitCode())
+			}
+			fmt.Fprintf(os.Stderr, "failed to run ssh command: %v\n", err)
+			os.Exit(1)
+		}
+	case "ssh-config":
+		if err := exec.SSHConfig(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run ssh-config command: %v\n", err)
+			exec.Help()
+			os.Exit(1)
+		}
+	case "hosts":
+		if len(os.Args) < 3 || os.Args[2] != "sync" {
+			fmt.Fprintln(os.Stderr, "unknown hosts command, expected: hosts sync")
+			exec.Help()
+			os.Exit(1)
+		}
+		if err := exec.HostsSync(ctx, os.Args[3:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run hosts sync command: %v\n", err)
+			os.Exit(1)
+		}
+	case "serve-dns":
+		if err := exec.ServeDNS(ctx, os.Args[2:], os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run serve-dns command: %v\n", err)
+			os.Exit(1)
 		}
 	case "push":
 		if err := exec.Push(ctx, os.Args[2:]); err != nil {
 			fmt.Fprintf(os.Stderr, "failed to run push command: %v\n", err)
+			if !tender.Partial(err) {
+				exec.Help()
+			}
+			os.Exit(1)
+		}
+	case "auth":
+		if len(os.Args) < 3 || os.Args[2] != "check" {
+			fmt.Fprintln(os.Stderr, "unknown auth command, expected: auth check")
+			exec.Help()
+			os.Exit(1)
+		}
+		status, err := exec.AuthCheck(ctx, os.Args[3:], os.Stdout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run auth check command: %v\n", err)
+			os.Exit(1)
+		}
+		if !status.Ready() {
+			os.Exit(1)
+		}
+	case "namespaces":
+		if _, err := exec.Namespaces(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run namespaces command: %v\n", err)
+			exec.Help()
+			os.Exit(1)
+		}
+	case "history":
+		if _, err := exec.History(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run history command: %v\n", err)
+			exec.Help()
+			os.Exit(1)
+		}
+	case "migrate":
+		if _, err := exec.Migrate(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run migrate command: %v\n", err)
+			exec.Help()
+			os.Exit(1)
+		}
+	case "export":
+		if err := exec.Export(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run export command: %v\n", err)
+			exec.Help()
+			os.Exit(1)
+		}
+	case "import":
+		// No help on failure, it would bury the list of invalid rows
+		if _, err := exec.Import(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run import command: %v\n", err)
+			os.Exit(1)
+		}
+	case "prune":
+		if _, err := exec.Prune(ctx, os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run prune command: %v\n", err)
+			exec.Help()
+			os.Exit(1)
+		}
+	case "rm":
+		if err := exec.Remove(ctx, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run rm command: %v\n", err)
+			exec.Help()
+			os.Exit(1)
+		}
+	case "mv":
+		if err := exec.Move(ctx, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run mv command: %v\n", err)
+			exec.Help()
+			os.Exit(1)
+		}
+	case "set":
+		if err := exec.Set(ctx, os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run set command: %v\n", err)
+			exec.Help()
+			os.Exit(1)
+		}
+	case "key":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "unknown key command, expected: key generate or key rotate")
+			exec.Help()
+			os.Exit(1)
+		}
+		var err error
+		switch os.Args[2] {
+		case "generate":
+			err = exec.KeyGenerate(ctx, os.Args[3:], os.Stdout)
+		case "rotate":
+			err = exec.KeyRotate(ctx, os.Args[3:], os.Stdout)
+		default:
+			fmt.Fprintln(os.Stderr, "unknown key command, expected: key generate or key rotate")
 			exec.Help()
 			os.Exit(1)
 		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run key %s command: %v\n", os.Args[2], err)
+			os.Exit(1)
+		}
+	case "identity":
+		if _, err := exec.Identity(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run identity command: %v\n", err)
+			os.Exit(1)
+		}
+	case "trust":
+		if len(os.Args) < 3 {
+			fmt.Fprintln(os.Stderr, "unknown trust command, expected: trust add, trust revoke or trust list")
+			exec.Help()
+			os.Exit(1)
+		}
+		var err error
+		switch os.Args[2] {
+		case "add":
+			err = exec.TrustAdd(ctx, os.Args[3:], os.Stdout)
+		case "revoke":
+			err = exec.TrustRevoke(os.Args[3:], os.Stdout)
+		case "list":
+			err = exec.TrustList(os.Args[3:], os.Stdout)
+		default:
+			fmt.Fprintln(os.Stderr, "unknown trust command, expected: trust add, trust revoke or trust list")
+			exec.Help()
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run trust %s command: %v\n", os.Args[2], err)
+			os.Exit(1)
+		}
+	case "config":
+		if len(os.Args) < 3 || os.Args[2] != "show" {
+			fmt.Fprintln(os.Stderr, "unknown config command, expected: config show")
+			exec.Help()
+			os.Exit(1)
+		}
+		if err := exec.ConfigShow(os.Args[3:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to run config show command: %v\n", err)
+			os.Exit(1)
+		}
 	case "help":
 		exec.Help()
 	default:
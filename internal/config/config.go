@@ -1,18 +1,46 @@
-// Package config defines application-wide constants used throughout piphos.
+// Package config defines application-wide constants used throughout piphos and reads
+// the configuration files holding default settings and profiles.
 package config
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 const (
 	// HTTPClientTimeout is the maximum duration for HTTP requests.
 	HTTPClientTimeout = 10 * time.Second
-
 	// MaxResponseBodySize is the limit for the response size
 	MaxResponseBodySize = 10 << 20 // 10MB
-
+	// Version is the piphos release, recorded with every pushed host record.
+	Version = "1.0"
 	// PiphosUserAgent is the User-Agent header value for HTTP requests.
-	PiphosUserAgent = "piphos/1.0"
-
+	PiphosUserAgent = "piphos/" + Version
 	// PiphosStamp is the identifier used for gist descriptions and filenames.
 	PiphosStamp = "_piphos_"
+	// DefaultNamespace is the name of the namespace stored under PiphosStamp.
+	DefaultNamespace = "default"
 )
+
+// Stamp returns the identifier used for gist descriptions and filenames of a namespace.
+// The empty and default namespaces map to PiphosStamp, so existing gists keep working;
+// any other namespace ns maps to "_piphos_<ns>_".
+func Stamp(namespace string) string {
+	if namespace == "" || namespace == DefaultNamespace {
+		return PiphosStamp
+	}
+	return PiphosStamp + namespace + "_"
+}
+
+// Namespace returns the namespace a stamp belongs to, reversing Stamp.
+// Returns false if stamp is not a piphos stamp.
+func Namespace(stamp string) (string, bool) {
+	if stamp == PiphosStamp {
+		return DefaultNamespace, true
+	}
+	inner, ok := strings.CutPrefix(stamp, PiphosStamp)
+	if !ok || len(inner) < 2 || !strings.HasSuffix(inner, "_") {
+		return "", false
+	}
+	return strings.TrimSuffix(inner, "_"), true
+}
@@ -0,0 +1,47 @@
+package config
+
+import "testing"
+
+func TestStamp(t *testing.T) {
+	tests := []struct {
+		namespace     string
+		expectedStamp string
+	}{
+		{namespace: "", expectedStamp: "_piphos_"},
+		{namespace: "default", expectedStamp: "_piphos_"},
+		{namespace: "family", expectedStamp: "_piphos_family_"},
+		{namespace: "home-lab", expectedStamp: "_piphos_home-lab_"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			if stamp := Stamp(tt.namespace); stamp != tt.expectedStamp {
+				t.Errorf("expected stamp %s but got %s", tt.expectedStamp, stamp)
+			}
+		})
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	tests := []struct {
+		stamp             string
+		expectedNamespace string
+		expectedOK        bool
+	}{
+		{stamp: "_piphos_", expectedNamespace: "default", expectedOK: true},
+		{stamp: "_piphos_family_", expectedNamespace: "family", expectedOK: true},
+		{stamp: "_piphos_family", expectedOK: false},
+		{stamp: "_piphos__", expectedOK: false},
+		{stamp: "my notes", expectedOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stamp, func(t *testing.T) {
+			namespace, ok := Namespace(tt.stamp)
+			if ok != tt.expectedOK {
+				t.Fatalf("expected ok %v but got %v", tt.expectedOK, ok)
+			}
+			if namespace != tt.expectedNamespace {
+				t.Errorf("expected namespace %s but got %s", tt.expectedNamespace, namespace)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SystemFile is the configuration file shared by all users of a machine.
+const SystemFile = "/etc/piphos/config.toml"
+
+// Settings maps setting names to their values. Every value is a list, which holds a
+// single element unless the setting may be repeated, like the flag of the same name.
+type Settings map[string][]string
+
+// File is a parsed configuration file: default settings at the top, and named profiles
+// in [profiles.NAME] tables overriding them.
+type File struct {
+	Defaults Settings
+	Profiles map[string]Settings
+}
+
+// keyPattern matches bare TOML keys, which is all that piphos settings need.
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Files returns the configuration files to read, in increasing order of precedence:
+// SystemFile and config.toml in the user's piphos configuration directory
+// (~/.config/piphos on Linux), or only the file named by PIPHOS_CONFIG.
+func Files() []string {
+	if path := os.Getenv("PIPHOS_CONFIG"); path != "" {
+		return []string{path}
+	}
+	files := []string{SystemFile}
+	if dir, err := os.UserConfigDir(); err == nil {
+		files = append(files, filepath.Join(dir, "piphos", "config.toml"))
+	}
+	return files
+}
+
+// Load reads and merges the configuration files, settings of later files replacing those
+// of earlier ones. Missing files are skipped, except one named by PIPHOS_CONFIG.
+// Returns the merged file and the paths that were read.
+func Load() (*File, []string, error) {
+	merged := &File{Defaults: Settings{}, Profiles: map[string]Settings{}}
+	var read []string
+	for _, path := range Files() {
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) && os.Getenv("PIPHOS_CONFIG") == "" {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		f, err := Parse(string(data))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		maps.Copy(merged.Defaults, f.Defaults)
+		for name, settings := range f.Profiles {
+			if merged.Profiles[name] == nil {
+				merged.Profiles[name] = Settings{}
+			}
+			maps.Copy(merged.Profiles[name], settings)
+		}
+		read = append(read, path)
+	}
+	return merged, read, nil
+}
+
+// Profile returns the settings of the named profile.
+func (f *File) Profile(name string) (Settings, error) {
+	settings, ok := f.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q", name)
+	}
+	return settings, nil
+}
+
+// Parse parses a configuration file in the subset of TOML used by piphos: comments,
+// key = value pairs and [profiles.NAME] tables, where a value is a string, a number,
+// a boolean or an array of these, which may span several lines.
+func Parse(data string) (*File, error) {
+	f := &File{Defaults: Settings{}, Profiles: map[string]Settings{}}
+	current := f.Defaults
+	lines := strings.Split(data, "\n")
+	for i := 0; i < len(lines); i++ {
+		n := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			table, ok := strings.CutSuffix(strings.TrimPrefix(line, "["), "]")
+			name, isProfile := strings.CutPrefix(strings.TrimSpace(table), "profiles.")
+			if !ok || !isProfile || !keyPattern.MatchString(name) {
+				return nil, fmt.Errorf("line %d: invalid table %s, expected [profiles.NAME]", n, line)
+			}
+			if _, exists := f.Profiles[name]; exists {
+				return nil, fmt.Errorf("line %d: duplicate profile %q", n, name)
+			}
+			current = Settings{}
+			f.Profiles[name] = current
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || !keyPattern.MatchString(key) {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		if _, exists := current[key]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n, key)
+		}
+		// An array continues until its closing bracket
+		for strings.HasPrefix(value, "[") && !closed(value) && i+1 < len(lines) {
+			i++
+			value += " " + strings.TrimSpace(stripComment(lines[i]))
+		}
+		values, err := parseValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value of %s: %w", n, key, err)
+		}
+		current[key] = values
+	}
+	return f, nil
+}
+
+// parseValue parses a scalar, returned as a single element, or an array of scalars.
+func parseValue(value string) ([]string, error) {
+	inner, isArray := strings.CutPrefix(value, "[")
+	if !isArray {
+		scalar, rest, err := parseScalar(value)
+		if err != nil {
+			return nil, err
+		}
+		if rest != "" {
+			return nil, fmt.Errorf("unexpected %q after value", rest)
+		}
+		return []string{scalar}, nil
+	}
+	values := []string{}
+	rest := strings.TrimSpace(inner)
+	for {
+		if after, ok := strings.CutPrefix(rest, "]"); ok {
+			if strings.TrimSpace(after) != "" {
+				return nil, fmt.Errorf("unexpected %q after array", after)
+			}
+			return values, nil
+		}
+		scalar, after, err := parseScalar(rest)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, scalar)
+		rest = strings.TrimSpace(after)
+		if after, ok := strings.CutPrefix(rest, ","); ok {
+			rest = strings.TrimSpace(after)
+		} else if !strings.HasPrefix(rest, "]") {
+			return nil, errors.New("expected , or ] in array")
+		}
+	}
+}
+
+// parseScalar parses the string, number or boolean at the start of s and returns it
+// together with the rest of s.
+func parseScalar(s string) (string, string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		end := stringEnd(s)
+		if end < 0 {
+			return "", "", errors.New("unterminated string")
+		}
+		unquoted, err := strconv.Unquote(s[:end+1])
+		if err != nil {
+			return "", "", fmt.Errorf("invalid string %s", s[:end+1])
+		}
+		return unquoted, strings.TrimSpace(s[end+1:]), nil
+	case strings.HasPrefix(s, "'"):
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", errors.New("unterminated string")
+		}
+		return s[1 : end+1], strings.TrimSpace(s[end+2:]), nil
+	}
+	end := strings.IndexAny(s, ", ]")
+	if end < 0 {
+		end = len(s)
+	}
+	bare := s[:end]
+	if _, err := strconv.ParseFloat(strings.ReplaceAll(bare, "_", ""), 64); err != nil && bare != "true" && bare != "false" {
+		return "", "", fmt.Errorf("expected a quoted string, number or boolean but got %q", bare)
+	}
+	return bare, strings.TrimSpace(s[end:]), nil
+}
+
+// stringEnd returns the index of the quote closing the basic string at the start of s, or -1.
+func stringEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// stripComment removes a # comment from line, unless the # is part of a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == 0 && c == '#':
+			return line[:i]
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case c == quote:
+			quote = 0
+		}
+	}
+	return line
+}
+
+// closed reports whether the array in value has its closing bracket, outside of strings.
+func closed(value string) bool {
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote == 0 && c == ']':
+			return true
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == '"' && c == '\\':
+			i++
+		case c == quote:
+			quote = 0
+		}
+	}
+	return false
+}
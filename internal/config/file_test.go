@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name             string
+		data             string
+		expectedDefaults Settings
+		expectedProfiles map[string]Settings
+		expectedError    bool
+	}{
+		{
+			name: "defaults and profiles",
+			data: `# piphos configuration
+tender = "gh"
+beacon = 'haz'   # faster
+verbose = true
+
+[profiles.home]
+namespace = "homelab"
+heartbeat = "6h"
+notify = ["ntfy://ntfy.sh/a#b", 'slack://hooks.slack.com/x']
+
+[ profiles.family ]
+namespace = "family"
+hook = [
+  "echo \"changed\"",  # quoted
+  "true",
+]
+`,
+			expectedDefaults: Settings{"tender": {"gh"}, "beacon": {"haz"}, "verbose": {"true"}},
+			expectedProfiles: map[string]Settings{
+				"home":   {"namespace": {"homelab"}, "heartbeat": {"6h"}, "notify": {"ntfy://ntfy.sh/a#b", "slack://hooks.slack.com/x"}},
+				"family": {"namespace": {"family"}, "hook": {`echo "changed"`, "true"}},
+			},
+		},
+		{name: "empty", data: "\n# nothing\n", expectedDefaults: Settings{}, expectedProfiles: map[string]Settings{}},
+		{name: "numbers", data: "ttl = 3600\n", expectedDefaults: Settings{"ttl": {"3600"}}, expectedProfiles: map[string]Settings{}},
+		{name: "empty array", data: "hook = []\n", expectedDefaults: Settings{"hook": {}}, expectedProfiles: map[string]Settings{}},
+		{name: "bare string", data: "tender = gh\n", expectedError: true},
+		{name: "missing value", data: "tender\n", expectedError: true},
+		{name: "unterminated string", data: "tender = \"gh\n", expectedError: true},
+		{name: "trailing garbage", data: "tender = \"gh\" \"aws\"\n", expectedError: true},
+		{name: "duplicate key", data: "tender = \"gh\"\ntender = \"gh\"\n", expectedError: true},
+		{name: "unknown table", data: "[server]\n", expectedError: true},
+		{name: "duplicate profile", data: "[profiles.a]\n[profiles.a]\n", expectedError: true},
+		{name: "unterminated array", data: "hook = [\"true\"\n", expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.data)
+			if tt.expectedError {
+				if err == nil {
+					t.Errorf("expected error but got %+v", f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if !reflect.DeepEqual(f.Defaults, tt.expectedDefaults) {
+				t.Errorf("expected defaults %v but got %v", tt.expectedDefaults, f.Defaults)
+			}
+			if !reflect.DeepEqual(f.Profiles, tt.expectedProfiles) {
+				t.Errorf("expected profiles %v but got %v", tt.expectedProfiles, f.Profiles)
+			}
+		})
+	}
+}
+
+func TestFile_Profile(t *testing.T) {
+	f := &File{Profiles: map[string]Settings{"home": {"namespace": {"homelab"}}}}
+	settings, err := f.Profile("home")
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if settings["namespace"][0] != "homelab" {
+		t.Errorf("unexpected settings: %v", settings)
+	}
+	if _, err := f.Profile("office"); err == nil {
+		t.Error("expected error for an unknown profile but got nil")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	t.Setenv("PIPHOS_CONFIG", path)
+	if _, _, err := Load(); err == nil {
+		t.Error("expected an error for a missing PIPHOS_CONFIG file but got nil")
+	}
+	if err := os.WriteFile(path, []byte("tender = \"gh\"\n[profiles.home]\nnamespace = \"homelab\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, read, err := Load()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !reflect.DeepEqual(read, []string{path}) {
+		t.Errorf("expected to read %s but read %v", path, read)
+	}
+	if f.Defaults["tender"][0] != "gh" || f.Profiles["home"]["namespace"][0] != "homelab" {
+		t.Errorf("unexpected file: %+v", f)
+	}
+}
@@ -0,0 +1,270 @@
+// Package crypt encrypts the stored host records client-side, so that the storage
+// provider, or anyone who obtains a link to the storage, only sees ciphertext.
+//
+// Hosts share a team key: 256 random bits used with AES-256-GCM. Keys are kept in a
+// key file, one per line, newest first. The newest key encrypts, every key in the file
+// decrypts, so old revisions stay readable after a rotation.
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kappapee/piphos/internal/fileutil"
+)
+
+const (
+	// keyPrefix marks an encoded key, so keys are recognisable in files and environment variables.
+	keyPrefix = "piphos-key-"
+	// keySize is the size of a key in bytes, selecting AES-256.
+	keySize = 32
+	// algorithm names the cipher in the envelope.
+	algorithm = "aes-256-gcm"
+	// additionalData binds the ciphertext to its purpose.
+	additionalData = "piphos host records"
+)
+
+// Key is a team key for encrypting the stored host records.
+type Key struct {
+	secret []byte
+}
+
+// GenerateKey creates a new random key.
+func GenerateKey() (Key, error) {
+	secret := make([]byte, keySize)
+	if _, err := rand.Read(secret); err != nil {
+		return Key{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	return Key{secret: secret}, nil
+}
+
+// ParseKey decodes a key in the format written by Key.String.
+func ParseKey(encoded string) (Key, error) {
+	body, ok := strings.CutPrefix(strings.TrimSpace(encoded), keyPrefix)
+	if !ok {
+		return Key{}, fmt.Errorf("invalid key: missing %s prefix", keyPrefix)
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return Key{}, fmt.Errorf("invalid key: %w", err)
+	}
+	if len(secret) != keySize {
+		return Key{}, fmt.Errorf("invalid key: expected %d bytes but got %d", keySize, len(secret))
+	}
+	return Key{secret: secret}, nil
+}
+
+// String encodes the key for storing it in a key file. The result is secret.
+func (k Key) String() string {
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(k.secret)
+}
+
+// ID returns a short fingerprint of the key, safe to print and to store next to the ciphertext.
+func (k Key) ID() string {
+	sum := sha256.Sum256(k.secret)
+	return hex.EncodeToString(sum[:8])
+}
+
+// envelope is the stored form of encrypted content.
+type envelope struct {
+	Encrypted  string `json:"encrypted"`
+	KeyID      string `json:"key_id"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ErrNotEncrypted is returned by Keyring.Decode for content that is not encrypted. Anyone
+// with write access to the storage could have written it, so it is not trusted.
+var ErrNotEncrypted = errors.New("stored hosts are not encrypted although a key file is configured, encrypt them with piphos key rotate if they were stored before the key file existed")
+
+// Keyring holds the keys of a key file, newest first.
+// It implements tender.Codec.
+type Keyring struct {
+	keys []Key
+	// allowPlaintext makes Decode accept unencrypted content, see AllowPlaintext.
+	allowPlaintext bool
+}
+
+// NewKeyring creates a keyring from keys, newest first.
+func NewKeyring(keys ...Key) *Keyring {
+	return &Keyring{keys: keys}
+}
+
+// AllowPlaintext returns a keyring with the keys of kr whose Decode also accepts content
+// that is not encrypted. It is only meant for encrypting hosts stored before the key file
+// existed, e.g. by piphos key generate.
+func (kr *Keyring) AllowPlaintext() *Keyring {
+	return &Keyring{keys: kr.keys, allowPlaintext: true}
+}
+
+// Keys returns the keys of the keyring, newest first.
+func (kr *Keyring) Keys() []Key {
+	return kr.keys
+}
+
+// Encode encrypts plaintext with the newest key and returns the JSON envelope.
+func (kr *Keyring) Encode(plaintext []byte) ([]byte, error) {
+	if len(kr.keys) == 0 {
+		return nil, errors.New("failed to encrypt: keyring is empty")
+	}
+	key := kr.keys[0]
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	env := envelope{
+		Encrypted:  algorithm,
+		KeyID:      key.ID(),
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(additionalData)),
+	}
+	content, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+	return content, nil
+}
+
+// Decode decrypts an envelope written by Encode with whichever key of the keyring wrote it.
+// Content that is not encrypted is rejected with ErrNotEncrypted, unless the keyring was
+// created by AllowPlaintext, which returns it unchanged.
+func (kr *Keyring) Decode(content []byte) ([]byte, error) {
+	if !IsEncrypted(content) {
+		if kr.allowPlaintext {
+			return content, nil
+		}
+		return nil, ErrNotEncrypted
+	}
+	var env envelope
+	if err := json.Unmarshal(content, &env); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal envelope: %w", err)
+	}
+	if env.Encrypted != algorithm {
+		return nil, fmt.Errorf("unsupported encryption: %s", env.Encrypted)
+	}
+	for _, key := range kr.keys {
+		if key.ID() != env.KeyID {
+			continue
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		if len(env.Nonce) != aead.NonceSize() {
+			return nil, errors.New("failed to decrypt: invalid nonce")
+		}
+		plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, []byte(additionalData))
+		if err != nil {
+			return nil, errors.New("failed to decrypt: content was tampered with or the key is wrong")
+		}
+		return plaintext, nil
+	}
+	return nil, fmt.Errorf("failed to decrypt: content is encrypted with key %s, which is not in the key file", env.KeyID)
+}
+
+// IsEncrypted reports whether content is an envelope written by Keyring.Encode.
+func IsEncrypted(content []byte) bool {
+	var probe struct {
+		Encrypted string `json:"encrypted"`
+	}
+	return json.Unmarshal(content, &probe) == nil && probe.Encrypted != ""
+}
+
+// newAEAD creates the AES-256-GCM cipher for key.
+func newAEAD(key Key) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key.secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return aead, nil
+}
+
+// ParseKeyring reads a key file: one key per line, newest first. Empty lines and
+// lines starting with # are ignored.
+func ParseKeyring(content []byte) (*Keyring, error) {
+	var keys []Key
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, err := ParseKey(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("key file holds no keys")
+	}
+	return NewKeyring(keys...), nil
+}
+
+// KeyFilePath returns the key file location: PIPHOS_KEY_FILE if set, otherwise
+// "piphos/key" in the user's configuration directory.
+func KeyFilePath() (string, error) {
+	if path := os.Getenv("PIPHOS_KEY_FILE"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find configuration directory: %w", err)
+	}
+	return filepath.Join(dir, "piphos", "key"), nil
+}
+
+// Load reads the keyring from the key file. It returns nil if encryption is not set up,
+// i.e. PIPHOS_KEY_FILE is unset and there is no key file at the default location.
+func Load() (*Keyring, error) {
+	path, err := KeyFilePath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && os.Getenv("PIPHOS_KEY_FILE") == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	keyring, err := ParseKeyring(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	return keyring, nil
+}
+
+// Save writes the keyring to path, readable only by the owner. The file is replaced
+// atomically, so a failed write never leaves a truncated key file behind.
+func Save(path string, keyring *Keyring) error {
+	var b strings.Builder
+	b.WriteString("# piphos encryption keys, newest first. Keep this file secret.\n")
+	for _, key := range keyring.keys {
+		fmt.Fprintf(&b, "%s\n", key)
+	}
+	if err := fileutil.WriteAtomic(path, []byte(b.String()), 0o600); err != nil {
+		return fmt.Errorf("failed to save key file: %w", err)
+	}
+	return nil
+}
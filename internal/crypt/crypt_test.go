@@ -0,0 +1,189 @@
+package crypt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mustGenerateKey(t *testing.T) Key {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func TestKeyring_RoundTrip(t *testing.T) {
+	plaintext := []byte(`{"schema": 2, "hosts": {"laptop": {"ipv4": "203.0.113.42"}}}`)
+	oldKey := mustGenerateKey(t)
+	newKey := mustGenerateKey(t)
+	sealedOld, err := NewKeyring(oldKey).Encode(plaintext)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if bytes.Contains(sealedOld, []byte("203.0.113.42")) || bytes.Contains(sealedOld, []byte("laptop")) {
+		t.Errorf("expected ciphertext only but got: %s", sealedOld)
+	}
+	if !IsEncrypted(sealedOld) {
+		t.Error("expected content to be recognised as encrypted")
+	}
+	rotated := NewKeyring(newKey, oldKey)
+	sealedNew, err := rotated.Encode(plaintext)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	tests := []struct {
+		name          string
+		keyring       *Keyring
+		content       []byte
+		expectedError bool
+	}{
+		{name: "same key", keyring: NewKeyring(oldKey), content: sealedOld},
+		{name: "old key after rotation", keyring: rotated, content: sealedOld},
+		{name: "new key after rotation", keyring: rotated, content: sealedNew},
+		{name: "plaintext is rejected", keyring: rotated, content: plaintext, expectedError: true},
+		{name: "plaintext allowed for encrypting it", keyring: rotated.AllowPlaintext(), content: plaintext},
+		{name: "unknown key", keyring: NewKeyring(oldKey), content: sealedNew, expectedError: true},
+		{name: "tampered", keyring: NewKeyring(oldKey), content: bytes.Replace(sealedOld, []byte(`"ciphertext": "`), []byte(`"ciphertext": "AAAA`), 1), expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoded, err := tt.keyring.Decode(tt.content)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if !bytes.Equal(decoded, plaintext) {
+				t.Errorf("expected %s but got %s", plaintext, decoded)
+			}
+		})
+	}
+}
+
+func TestKeyring_NonceIsRandom(t *testing.T) {
+	keyring := NewKeyring(mustGenerateKey(t))
+	first, err := keyring.Encode([]byte("same"))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	second, err := keyring.Encode([]byte("same"))
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if bytes.Equal(first, second) {
+		t.Error("expected encrypting the same content twice to differ")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	key := mustGenerateKey(t)
+	tests := []struct {
+		name          string
+		encoded       string
+		expectedError bool
+	}{
+		{name: "valid key", encoded: key.String()},
+		{name: "surrounding whitespace", encoded: " " + key.String() + "\n"},
+		{name: "missing prefix", encoded: strings.TrimPrefix(key.String(), keyPrefix), expectedError: true},
+		{name: "invalid base64", encoded: keyPrefix + "!!!", expectedError: true},
+		{name: "short key", encoded: keyPrefix + "AAAA", expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := ParseKey(tt.encoded)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if parsed.ID() != key.ID() {
+				t.Errorf("expected key %s but got %s", key.ID(), parsed.ID())
+			}
+		})
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "piphos", "key")
+	t.Setenv("PIPHOS_KEY_FILE", path)
+	if _, err := Load(); err == nil {
+		t.Error("expected error for missing PIPHOS_KEY_FILE but got nil")
+	}
+	newKey := mustGenerateKey(t)
+	oldKey := mustGenerateKey(t)
+	if err := Save(path, NewKeyring(newKey, oldKey)); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat key file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected key file mode 0600 but got %o", perm)
+	}
+	keyring, err := Load()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	keys := keyring.Keys()
+	if len(keys) != 2 || keys[0].ID() != newKey.ID() || keys[1].ID() != oldKey.ID() {
+		t.Errorf("expected keys %s, %s in order", newKey.ID(), oldKey.ID())
+	}
+}
+
+func TestLoad_NotConfigured(t *testing.T) {
+	t.Setenv("PIPHOS_KEY_FILE", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	keyring, err := Load()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if keyring != nil {
+		t.Error("expected no keyring without a key file")
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	key := mustGenerateKey(t)
+	tests := []struct {
+		name          string
+		content       string
+		expectedKeys  int
+		expectedError bool
+	}{
+		{name: "keys with comments", content: "# piphos\n\n" + key.String() + "\n" + key.String() + "\n", expectedKeys: 2},
+		{name: "invalid line", content: key.String() + "\nnot-a-key\n", expectedError: true},
+		{name: "empty", content: "# nothing\n", expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyring, err := ParseKeyring([]byte(tt.content))
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if len(keyring.Keys()) != tt.expectedKeys {
+				t.Errorf("expected %d keys but got %d", tt.expectedKeys, len(keyring.Keys()))
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Package diff produces unified diffs of text files, as shown by commands with a -dry-run flag.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// op is a line of a diff: kept (' '), removed ('-') or added ('+').
+// oldLine and newLine count the lines of either side preceding it.
+type op struct {
+	kind             byte
+	text             string
+	oldLine, newLine int
+}
+
+// Unified returns the differences between old and new in unified format, labelling them
+// with oldName and newName. Returns the empty string if old and new are equal.
+func Unified(oldName, newName string, old, new []byte) string {
+	if string(old) == string(new) {
+		return ""
+	}
+	ops := lineOps(splitLines(string(old)), splitLines(string(new)))
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		change := start
+		for change < len(ops) && ops[change].kind == ' ' {
+			change++
+		}
+		if change == len(ops) {
+			break
+		}
+		// Extend the hunk while the next change is close enough for the contexts to touch
+		last := change
+		for i := change; i < len(ops) && i-last <= 2*contextLines; i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			}
+		}
+		first := max(change-contextLines, start)
+		end := min(last+contextLines+1, len(ops))
+		writeHunk(&b, ops[first:end])
+		start = end
+	}
+	return b.String()
+}
+
+// writeHunk writes a hunk header followed by the lines of hunk.
+func writeHunk(b *strings.Builder, hunk []op) {
+	oldCount, newCount := 0, 0
+	for _, o := range hunk {
+		if o.kind != '+' {
+			oldCount++
+		}
+		if o.kind != '-' {
+			newCount++
+		}
+	}
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(hunk[0].oldLine, oldCount), hunkRange(hunk[0].newLine, newCount))
+	for _, o := range hunk {
+		b.WriteByte(o.kind)
+		b.WriteString(o.text)
+		if !strings.HasSuffix(o.text, "\n") {
+			b.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the start and length of a hunk on one side. An empty range
+// refers to the line before it, as in diff -u.
+func hunkRange(before, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
+
+// splitLines splits s after each newline, keeping a last line without one.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// lineOps turns old into new with a minimal number of removed and added lines.
+// The common prefix and suffix are matched directly, so the quadratic search
+// only covers the changed region, which is small for the files piphos edits.
+func lineOps(old, new []string) []op {
+	prefix := 0
+	for prefix < len(old) && prefix < len(new) && old[prefix] == new[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(new)-prefix && old[len(old)-1-suffix] == new[len(new)-1-suffix] {
+		suffix++
+	}
+	a, b := old[prefix:len(old)-suffix], new[prefix:len(new)-suffix]
+	// lcs[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var ops []op
+	o, n := 0, 0
+	emit := func(kind byte, text string) {
+		ops = append(ops, op{kind: kind, text: text, oldLine: o, newLine: n})
+		if kind != '+' {
+			o++
+		}
+		if kind != '-' {
+			n++
+		}
+	}
+	for _, line := range old[:prefix] {
+		emit(' ', line)
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			emit(' ', a[i])
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			emit('-', a[i])
+			i++
+		default:
+			emit('+', b[j])
+			j++
+		}
+	}
+	for _, line := range old[len(old)-suffix:] {
+		emit(' ', line)
+	}
+	return ops
+}
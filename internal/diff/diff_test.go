@@ -0,0 +1,51 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name         string
+		old          string
+		new          string
+		expectedDiff string
+	}{
+		{
+			name:         "equal",
+			old:          "a\nb\n",
+			new:          "a\nb\n",
+			expectedDiff: "",
+		},
+		{
+			name:         "new file",
+			old:          "",
+			new:          "a\nb\n",
+			expectedDiff: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:         "changed line with context",
+			old:          "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:          "1\n2\n3\n4\nfive\n6\n7\n8\n",
+			expectedDiff: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			expectedDiff: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			name:         "missing newline at end",
+			old:          "a\nb",
+			new:          "a\nb\nc\n",
+			expectedDiff: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n-b\n\\ No newline at end of file\n+b\n+c\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := Unified("old", "new", []byte(tt.old), []byte(tt.new)); diff != tt.expectedDiff {
+				t.Errorf("expected %q but got %q", tt.expectedDiff, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// Record types, classes, response codes and header flags used by the server, see RFC 1035.
+const (
+	typeA    uint16 = 1
+	typeAAAA uint16 = 28
+	typeANY  uint16 = 255
+	classIN  uint16 = 1
+
+	rcodeSuccess        = 0
+	rcodeFormatError    = 1
+	rcodeNameError      = 3
+	rcodeNotImplemented = 4
+	rcodeRefused        = 5
+
+	flagResponse      uint16 = 1 << 15
+	flagAuthoritative uint16 = 1 << 10
+	flagRecursion     uint16 = 1 << 8
+
+	headerLength = 12
+	// maxUDPLength is the size of a UDP response a client accepts without EDNS.
+	maxUDPLength = 512
+)
+
+var errMalformed = errors.New("malformed dns message")
+
+// question is the single question of a query.
+type question struct {
+	// name is the queried name in lowercase with a trailing dot.
+	name  string
+	qtype uint16
+	class uint16
+	// end is the offset in the query just past the question.
+	end int
+}
+
+// parseQuestion reads the question of a query. Only the first question is read,
+// queries with any other count are rejected by the caller.
+func parseQuestion(msg []byte) (question, error) {
+	name, offset, err := readName(msg, headerLength)
+	if err != nil {
+		return question{}, err
+	}
+	if offset+4 > len(msg) {
+		return question{}, errMalformed
+	}
+	return question{
+		name:  name,
+		qtype: binary.BigEndian.Uint16(msg[offset:]),
+		class: binary.BigEndian.Uint16(msg[offset+2:]),
+		end:   offset + 4,
+	}, nil
+}
+
+// readName reads the possibly compressed name starting at offset and returns it together
+// with the offset just past it.
+func readName(msg []byte, offset int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if offset >= len(msg) {
+			return "", 0, errMalformed
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			if end < 0 {
+				end = offset + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")) + ".", end, nil
+		case length&0xc0 == 0xc0:
+			// A pointer to an earlier name; bound the jumps to reject loops
+			if offset+1 >= len(msg) || jumps > 10 {
+				return "", 0, errMalformed
+			}
+			if end < 0 {
+				end = offset + 2
+			}
+			offset = int(binary.BigEndian.Uint16(msg[offset:]) & 0x3fff)
+			jumps++
+		case length&0xc0 != 0 || offset+1+length > len(msg):
+			return "", 0, errMalformed
+		default:
+			labels = append(labels, string(msg[offset+1:offset+1+length]))
+			offset += 1 + length
+		}
+	}
+}
+
+// response builds the reply to query with the given response code and answers.
+// The question is copied from the query, which must have been parsed up to q.end.
+// Additional records of the query, such as EDNS options, are not echoed.
+func response(query []byte, q question, rcode int, answers [][]byte) []byte {
+	msg := make([]byte, headerLength, q.end+16*len(answers))
+	copy(msg, query[:2])
+	flags := binary.BigEndian.Uint16(query[2:])
+	flags = flagResponse | flagAuthoritative | flags&flagRecursion | uint16(rcode)
+	binary.BigEndian.PutUint16(msg[2:], flags)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(answers)))
+	msg = append(msg, query[headerLength:q.end]...)
+	for _, a := range answers {
+		msg = append(msg, a...)
+	}
+	return msg
+}
+
+// errorResponse builds a reply carrying only a response code, for queries whose question
+// cannot be answered or read.
+func errorResponse(query []byte, rcode int) []byte {
+	msg := make([]byte, headerLength)
+	copy(msg, query[:2])
+	flags := binary.BigEndian.Uint16(query[2:])
+	binary.BigEndian.PutUint16(msg[2:], flagResponse|flags&0x7800|flags&flagRecursion|uint16(rcode))
+	return msg
+}
+
+// answer builds a resource record for the question's name, which is referenced
+// by a compression pointer to the question at the start of the message.
+func answer(rtype uint16, ttl uint32, data []byte) []byte {
+	rr := make([]byte, 12, 12+len(data))
+	binary.BigEndian.PutUint16(rr, 0xc000|headerLength)
+	binary.BigEndian.PutUint16(rr[2:], rtype)
+	binary.BigEndian.PutUint16(rr[4:], classIN)
+	binary.BigEndian.PutUint32(rr[6:], ttl)
+	binary.BigEndian.PutUint16(rr[10:], uint16(len(data)))
+	return append(rr, data...)
+}
@@ -0,0 +1,158 @@
+// Package dns implements a minimal authoritative DNS server answering A and AAAA
+// queries for the hosts stored in a tender, below a zone such as "piphos.".
+package dns
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+// tcpIdleTimeout closes TCP connections on which no query arrives for this long.
+const tcpIdleTimeout = 10 * time.Second
+
+// Server answers queries for the names below its zone from a set of host records.
+// A host "home-server" with the zone "piphos." is queried as "home-server.piphos.",
+// and so are its aliases. Names are matched case-insensitively, other names in the
+// zone get NXDOMAIN, and names outside the zone are refused.
+type Server struct {
+	zone string
+	ttl  uint32
+	// hosts maps lowercase hostnames and aliases to their records.
+	hosts atomic.Pointer[map[string]tender.Record]
+}
+
+// NewServer creates a server for zone answering with the given TTL.
+// Returns an error if the zone is not a valid domain name.
+func NewServer(zone string, ttl time.Duration) (*Server, error) {
+	zone = strings.ToLower(strings.TrimSuffix(zone, ".")) + "."
+	for _, label := range strings.Split(strings.TrimSuffix(zone, "."), ".") {
+		if label == "" || len(label) > 63 || strings.ContainsAny(label, " \t") {
+			return nil, fmt.Errorf("invalid zone: %q", zone)
+		}
+	}
+	if ttl < 0 || ttl > time.Duration(1<<31-1)*time.Second {
+		return nil, fmt.Errorf("invalid ttl: %s", ttl)
+	}
+	s := &Server{zone: zone, ttl: uint32(ttl / time.Second)}
+	s.SetRecords(nil)
+	return s, nil
+}
+
+// Zone returns the zone the server answers for, with a trailing dot.
+func (s *Server) Zone() string {
+	return s.zone
+}
+
+// SetRecords replaces the records the server answers from. It is safe to call while serving.
+func (s *Server) SetRecords(records map[string]tender.Record) {
+	hosts := make(map[string]tender.Record, len(records))
+	for _, r := range records {
+		for _, alias := range r.Aliases {
+			hosts[strings.ToLower(alias)] = r
+		}
+	}
+	// Hostnames take precedence over aliases, as in tender.Resolve
+	for hostname, r := range records {
+		hosts[strings.ToLower(hostname)] = r
+	}
+	s.hosts.Store(&hosts)
+}
+
+// Answer returns the response to query, or nil if query is too short to be answered.
+func (s *Server) Answer(query []byte) []byte {
+	if len(query) < headerLength || query[2]&0x80 != 0 {
+		return nil
+	}
+	if opcode := query[2] >> 3 & 0x0f; opcode != 0 {
+		return errorResponse(query, rcodeNotImplemented)
+	}
+	if binary.BigEndian.Uint16(query[4:]) != 1 {
+		return errorResponse(query, rcodeFormatError)
+	}
+	q, err := parseQuestion(query)
+	if err != nil {
+		return errorResponse(query, rcodeFormatError)
+	}
+	if q.class != classIN || !strings.HasSuffix(q.name, "."+s.zone) && q.name != s.zone {
+		return response(query, q, rcodeRefused, nil)
+	}
+	if q.name == s.zone {
+		return response(query, q, rcodeSuccess, nil)
+	}
+	r, ok := (*s.hosts.Load())[strings.TrimSuffix(q.name, "."+s.zone)]
+	if !ok {
+		return response(query, q, rcodeNameError, nil)
+	}
+	var answers [][]byte
+	if addr, err := netip.ParseAddr(r.IPv4); err == nil && addr.Is4() && (q.qtype == typeA || q.qtype == typeANY) {
+		answers = append(answers, answer(typeA, s.ttl, addr.AsSlice()))
+	}
+	if addr, err := netip.ParseAddr(r.IPv6); err == nil && addr.Is6() && (q.qtype == typeAAAA || q.qtype == typeANY) {
+		answers = append(answers, answer(typeAAAA, s.ttl, addr.AsSlice()))
+	}
+	return response(query, q, rcodeSuccess, answers)
+}
+
+// ServeUDP answers the queries arriving on conn until it is closed.
+func (s *Server) ServeUDP(conn net.PacketConn) error {
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read query: %w", err)
+		}
+		// Responses hold at most a name and two addresses, so they always fit maxUDPLength
+		if resp := s.Answer(buf[:n]); resp != nil {
+			conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+// ServeTCP answers the queries arriving on connections accepted from l until it is closed.
+func (s *Server) ServeTCP(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to accept connection: %w", err)
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn answers length-prefixed queries on conn until the client is done.
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		conn.SetDeadline(time.Now().Add(tcpIdleTimeout))
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		query := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, query); err != nil {
+			return
+		}
+		resp := s.Answer(query)
+		if resp == nil {
+			return
+		}
+		if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(resp))), resp...)); err != nil {
+			return
+		}
+	}
+}
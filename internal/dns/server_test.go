@@ -0,0 +1,206 @@
+package dns
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+// startServer serves s on loopback UDP and TCP ports and returns a resolver querying it
+// over network, "udp" or "tcp".
+func startServer(t *testing.T, s *Server, network string) *net.Resolver {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go s.ServeUDP(conn)
+	go s.ServeTCP(l)
+	t.Cleanup(func() {
+		conn.Close()
+		l.Close()
+	})
+	address := conn.LocalAddr().String()
+	if network == "tcp" {
+		address = l.Addr().String()
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+func TestServer(t *testing.T) {
+	s, err := NewServer("piphos", time.Minute)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	s.SetRecords(map[string]tender.Record{
+		"home-server": {IPv4: "203.0.113.42", IPv6: "2001:db8::42", Aliases: []string{"nas"}},
+		"Laptop":      {IPv4: "198.51.100.7"},
+	})
+	tests := []struct {
+		name          string
+		host          string
+		expectedIPs   []string
+		expectedError bool
+	}{
+		{name: "both families", host: "home-server.piphos.", expectedIPs: []string{"2001:db8::42", "203.0.113.42"}},
+		{name: "alias", host: "nas.piphos.", expectedIPs: []string{"2001:db8::42", "203.0.113.42"}},
+		{name: "case-insensitive", host: "LAPTOP.Piphos.", expectedIPs: []string{"198.51.100.7"}},
+		{name: "unknown host", host: "desktop.piphos.", expectedError: true},
+		{name: "outside the zone", host: "home-server.example.", expectedError: true},
+	}
+	for _, network := range []string{"udp", "tcp"} {
+		resolver := startServer(t, s, network)
+		for _, tt := range tests {
+			t.Run(network+" "+tt.name, func(t *testing.T) {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				addrs, err := resolver.LookupHost(ctx, tt.host)
+				if tt.expectedError {
+					if err == nil {
+						t.Errorf("expected error but got %v", addrs)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("expected no error but got: %v", err)
+				}
+				slices.Sort(addrs)
+				if !slices.Equal(addrs, tt.expectedIPs) {
+					t.Errorf("expected %v but got %v", tt.expectedIPs, addrs)
+				}
+			})
+		}
+	}
+}
+
+func TestServer_NotFound(t *testing.T) {
+	s, err := NewServer("piphos.", time.Minute)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	resolver := startServer(t, s, "udp")
+	_, err = resolver.LookupHost(context.Background(), "desktop.piphos.")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("expected a not found error but got: %v", err)
+	}
+}
+
+// query builds a query for name and qtype with the given opcode.
+func query(name string, qtype uint16, opcode byte) []byte {
+	msg := []byte{0x12, 0x34, opcode << 3, 0, 0, 1, 0, 0, 0, 0, 0, 0}
+	for _, label := range []string{name[:len(name)-len(".piphos.")], "piphos"} {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	return binary.BigEndian.AppendUint16(msg, classIN)
+}
+
+func TestAnswer(t *testing.T) {
+	s, err := NewServer("piphos.", 90*time.Second)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	s.SetRecords(map[string]tender.Record{"server": {IPv4: "203.0.113.1"}})
+	tests := []struct {
+		name            string
+		query           []byte
+		expectedRcode   byte
+		expectedAnswers uint16
+	}{
+		{name: "a", query: query("server.piphos.", typeA, 0), expectedRcode: rcodeSuccess, expectedAnswers: 1},
+		{name: "aaaa without ipv6", query: query("server.piphos.", typeAAAA, 0), expectedRcode: rcodeSuccess},
+		{name: "unknown host", query: query("laptop.piphos.", typeA, 0), expectedRcode: rcodeNameError},
+		{name: "other opcode", query: query("server.piphos.", typeA, 2), expectedRcode: rcodeNotImplemented},
+		{name: "truncated question", query: query("server.piphos.", typeA, 0)[:20], expectedRcode: rcodeFormatError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := s.Answer(tt.query)
+			if len(resp) < headerLength {
+				t.Fatalf("expected a response but got %v", resp)
+			}
+			if resp[0] != 0x12 || resp[1] != 0x34 || resp[2]&0x80 == 0 {
+				t.Errorf("expected a response to query 0x1234 but got header %v", resp[:4])
+			}
+			if rcode := resp[3] & 0x0f; rcode != tt.expectedRcode {
+				t.Errorf("expected rcode %d but got %d", tt.expectedRcode, rcode)
+			}
+			if answers := binary.BigEndian.Uint16(resp[6:]); answers != tt.expectedAnswers {
+				t.Errorf("expected %d answers but got %d", tt.expectedAnswers, answers)
+			}
+			if tt.expectedAnswers > 0 {
+				rr := resp[len(resp)-16:]
+				if ttl := binary.BigEndian.Uint32(rr[6:]); ttl != 90 {
+					t.Errorf("expected ttl 90 but got %d", ttl)
+				}
+				if ip := net.IP(rr[12:]).String(); ip != "203.0.113.1" {
+					t.Errorf("expected 203.0.113.1 but got %s", ip)
+				}
+			}
+		})
+	}
+}
+
+func TestAnswer_CompressionLoop(t *testing.T) {
+	s, err := NewServer("piphos.", time.Minute)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	msg := []byte{0, 1, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xc0, 12, 0, 1, 0, 1}
+	resp := s.Answer(msg)
+	if resp == nil || resp[3]&0x0f != rcodeFormatError {
+		t.Errorf("expected a format error but got %v", resp)
+	}
+}
+
+func TestNewServer(t *testing.T) {
+	tests := []struct {
+		name          string
+		zone          string
+		ttl           time.Duration
+		expectedZone  string
+		expectedError bool
+	}{
+		{name: "without trailing dot", zone: "Piphos", ttl: time.Minute, expectedZone: "piphos."},
+		{name: "nested zone", zone: "home.example.", ttl: 0, expectedZone: "home.example."},
+		{name: "empty label", zone: "home..example", ttl: time.Minute, expectedError: true},
+		{name: "empty zone", zone: "", ttl: time.Minute, expectedError: true},
+		{name: "negative ttl", zone: "piphos", ttl: -time.Second, expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewServer(tt.zone, tt.ttl)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if s.Zone() != tt.expectedZone {
+				t.Errorf("expected zone %q but got %q", tt.expectedZone, s.Zone())
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// AuthReport is the result of an auth check as written by -o, see tender.AuthStatus.
+type AuthReport struct {
+	Identity  string     `json:"identity"`
+	TokenType string     `json:"token_type"`
+	Source    string     `json:"token_source"`
+	Scopes    []string   `json:"scopes,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Read      string     `json:"gist_read"`
+	Write     string     `json:"gist_write"`
+	Hints     []string   `json:"hints,omitempty"`
+	Ready     bool       `json:"ready"`
+}
+
+// AuthCheck verifies the credentials of the specified tender provider before any push.
+// It writes the identity, token kind, expiry and whether reading and writing will work to w.
+// The tender provider can be specified with the -tender flag (default: "gh") and -o
+// selects the output format, see output.Format (default: table).
+// Returns an error if the tender does not support credential checks or the check itself fails;
+// denied permissions are reported in the returned status, see tender.AuthStatus.Ready.
+func AuthCheck(ctx context.Context, args []string, w io.Writer) (*tender.AuthStatus, error) {
+	fs := flag.NewFlagSet("auth check", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	format := outputVar(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return nil, err
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return nil, err
+	}
+	checker, ok := t.(tender.Checker)
+	if !ok {
+		return nil, fmt.Errorf("tender %s does not support credential checks", *tf.name)
+	}
+	status, err := checker.Check(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return status, output.Write(w, *format, authResult(status, time.Now()))
+}
+
+// authResult returns status as the result of an auth check, with the expiry relative to now.
+func authResult(status *tender.AuthStatus, now time.Time) output.Result {
+	report := AuthReport{
+		Identity:  status.Identity,
+		TokenType: status.TokenKind,
+		Source:    status.Source,
+		Scopes:    status.Scopes,
+		Read:      status.Read.String(),
+		Write:     status.Write.String(),
+		Hints:     status.Hints,
+		Ready:     status.Ready(),
+	}
+	expires := "never or unknown"
+	if !status.ExpiresAt.IsZero() {
+		report.ExpiresAt = &status.ExpiresAt
+		expires = fmt.Sprintf("%s (in %s)", status.ExpiresAt.Format(time.RFC3339), status.ExpiresAt.Sub(now).Round(time.Minute))
+	}
+	r := output.Result{Value: report, Header: []string{"CHECK", "RESULT"}}
+	r.Rows = append(r.Rows,
+		[]string{"identity", report.Identity},
+		[]string{"token type", report.TokenType},
+		[]string{"token source", report.Source},
+	)
+	if status.Scopes != nil {
+		r.Rows = append(r.Rows, []string{"scopes", strings.Join(status.Scopes, ", ")})
+	}
+	r.Rows = append(r.Rows,
+		[]string{"expires", expires},
+		[]string{"gist read", report.Read},
+		[]string{"gist write", report.Write},
+	)
+	for _, hint := range status.Hints {
+		r.Rows = append(r.Rows, []string{"hint", hint})
+	}
+	return r
+}
@@ -0,0 +1,89 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestAuthCheck(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError bool
+	}{
+		{
+			name:          "unknown tender",
+			args:          []string{"-tender", "unknown"},
+			expectedError: true,
+		},
+		{
+			name:          "extra arguments",
+			args:          []string{"extra"},
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := AuthCheck(ctx, tt.args, &bytes.Buffer{})
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestAuthResult(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	status := &tender.AuthStatus{
+		Identity:  "octocat",
+		TokenKind: "fine-grained",
+		Source:    "PIPHOS_GITHUB_TOKEN",
+		ExpiresAt: now.Add(48 * time.Hour),
+		Read:      tender.PermissionGranted,
+		Write:     tender.PermissionDenied,
+		Hints:     []string{"grant the gists permission"},
+	}
+	tests := []struct {
+		name             string
+		format           output.Format
+		expectedContains []string
+	}{
+		{
+			name:             "table",
+			format:           output.Format{Name: output.Table},
+			expectedContains: []string{"identity      octocat", "expires       2025-06-03T12:00:00Z (in 48h0m0s)", "gist write    denied", "hint          grant the gists permission"},
+		},
+		{
+			name:             "json",
+			format:           output.Format{Name: output.JSON},
+			expectedContains: []string{`"identity": "octocat"`, `"expires_at": "2025-06-03T12:00:00Z"`, `"gist_write": "denied"`, `"ready": false`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := output.Write(&out, tt.format, authResult(status, now)); err != nil {
+				t.Fatalf("failed to write result: %v", err)
+			}
+			for _, expected := range tt.expectedContains {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("expected output to contain %q but got:\n%s", expected, out.String())
+				}
+			}
+			if tt.format.Name == output.JSON && !json.Valid(out.Bytes()) {
+				t.Errorf("expected valid JSON but got:\n%s", out.String())
+			}
+		})
+	}
+}
@@ -1,4 +1,5 @@
-// Package exec implements the three main commands for piphos: ping, pull, and push.
+// Package exec implements the commands for piphos: the three main commands ping, pull,
+// and push, plus supporting commands such as auth check, namespaces and rm.
 //
 // Each command function handles flag parsing, provider initialization, and execution
 // of the requested operation.
@@ -8,9 +9,16 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/kappapee/piphos/internal/beacon"
+	"github.com/kappapee/piphos/internal/identity"
+	"github.com/kappapee/piphos/internal/output"
 	"github.com/kappapee/piphos/internal/tender"
 	"github.com/kappapee/piphos/internal/validate"
 )
@@ -20,7 +28,9 @@ import (
 func Ping(ctx context.Context, args []string) (string, error) {
 	fs := flag.NewFlagSet("ping", flag.ExitOnError)
 	bs := fs.String("beacon", "aws", "which beacon provider to use")
-	fs.Parse(args)
+	if err := parseFlags(fs, args); err != nil {
+		return "", err
+	}
 	if err := validate.Command(fs.NArg()); err != nil {
 		return "", err
 	}
@@ -31,53 +41,238 @@ func Ping(ctx context.Context, args []string) (string, error) {
 	return b.Ping(ctx)
 }
 
-// Pull retrieves all hostname-to-IP mappings from the specified tender provider.
-// The tender provider can be specified with the -tender flag (default: "gh").
-// Requires PIPHOS_GITHUB_TOKEN environment variable for the "gh" provider.
-func Pull(ctx context.Context, args []string) (map[string]string, error) {
+// Pull retrieves all host records from the specified tender provider and writes them to w,
+// marking records whose signature does not verify against the trusted keys file.
+// The tender provider can be specified with the -tender flag (default: "gh"). A comma-separated
+// list merges the records of several tenders, preferring the newest record of each host;
+// if some of them fail, the others' records are written and an error is returned.
+// Requires a GitHub token for the "gh" provider, see tender.New for where it is looked up.
+// The -stale flag marks hosts that have not reported in for longer than the given duration.
+// The -o flag selects the output format, see output.Format (default: a table sorted by hostname).
+// The -hook flag runs a command or webhook for every host whose IP changed since the last pull,
+// and -notify sends a message about it, see notify.New. Both only consider usable records,
+// see usableRecords and -allow-untrusted.
+// The -namespace flag selects the set of hosts and -verbose reports diagnostics on stderr.
+func Pull(ctx context.Context, args []string, w io.Writer) (map[string]tender.Record, error) {
 	fs := flag.NewFlagSet("pull", flag.ExitOnError)
-	ts := fs.String("tender", "gh", "which tender provider to use")
-	fs.Parse(args)
+	tf := addTenderFlags(fs)
+	stale := durationVar(fs, "stale", "mark hosts not seen for longer than this duration, e.g. 24h (default: off)")
+	format := outputVar(fs)
+	hf := addHookFlags(fs)
+	allowUntrusted := addTrustFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
 	if err := validate.Command(fs.NArg()); err != nil {
 		return nil, err
 	}
-	t, err := tender.New(*ts)
+	r, err := hf.reactions(*tf.namespace, os.Stderr)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create tender %s: %w", *ts, err)
+		return nil, err
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return nil, err
+	}
+	records, pullErr := t.Pull(ctx)
+	if pullErr != nil && !tender.Partial(pullErr) {
+		return nil, pullErr
+	}
+	trust, err := identity.LoadTrustStore()
+	if err != nil {
+		return nil, err
+	}
+	if r != nil {
+		// Hooks act on addresses, so they only see records another command could use
+		usable, err := usableRecords(records, *allowUntrusted, os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+		observe(ctx, r, usable, *tf.namespace, "pull", os.Stderr)
+	}
+	now := time.Now()
+	hosts := hostRecords(records, now, *stale, trust)
+	if err := output.Write(w, *format, hostsResult(hosts, now)); err != nil {
+		return nil, err
+	}
+	return records, pullErr
+}
+
+// HostRecord is a stored record as written by pull, together with the state pull derives from it.
+type HostRecord struct {
+	Host string `json:"host"`
+	tender.Record
+	// Stale is set if the host has not reported in for longer than pull -stale.
+	Stale bool `json:"stale"`
+	// Trust is the result of verifying the record's signature, empty if no key is trusted.
+	Trust string `json:"trust,omitempty"`
+}
+
+// hostRecords returns records sorted by hostname, marking stale hosts.
+// Once any key is trusted, the signature of every record is verified against trust.
+func hostRecords(records map[string]tender.Record, now time.Time, stale time.Duration, trust *identity.TrustStore) []HostRecord {
+	hosts := []HostRecord{}
+	for _, hostname := range slices.Sorted(maps.Keys(records)) {
+		r := records[hostname]
+		h := HostRecord{Host: hostname, Record: r, Stale: r.Stale(now, stale)}
+		if trust != nil && !trust.Empty() {
+			h.Trust = trust.Verify(hostname, r).String()
+		}
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// hostsResult lays out hosts as a table with one row per host, showing how long ago the
+// address changed and the host was last seen, and marking stale and manual hosts
+// as well as records that failed verification.
+func hostsResult(hosts []HostRecord, now time.Time) output.Result {
+	result := output.Result{
+		Value:  hosts,
+		Header: []string{"HOST", "IPV4", "IPV6", "ALIASES", "UPDATED", "SEEN", "STATUS"},
+	}
+	for _, h := range hosts {
+		var status []string
+		if h.Stale {
+			status = append(status, "stale")
+		}
+		if h.Manual {
+			status = append(status, "manual")
+		}
+		if h.Trust != "" && h.Trust != identity.Verified.String() {
+			status = append(status, h.Trust)
+		}
+		result.Rows = append(result.Rows, []string{
+			h.Host, h.IPv4, h.IPv6, strings.Join(h.Aliases, ","),
+			age(now, h.UpdatedAt), age(now, h.SeenAt), strings.Join(status, ","),
+		})
+	}
+	return result
+}
+
+// age returns how long before now t was, e.g. "5m ago" or "2d3h ago", or "" if t is zero.
+func age(now, t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := now.Sub(t).Round(time.Minute)
+	if d < time.Minute {
+		return "just now"
+	}
+	var s string
+	if days := d / (24 * time.Hour); days > 0 {
+		s = fmt.Sprintf("%dd", days)
+		d -= days * 24 * time.Hour
+	}
+	if hours := d / time.Hour; hours > 0 {
+		s += fmt.Sprintf("%dh", hours)
+		d -= hours * time.Hour
 	}
-	return t.Pull(ctx)
+	if d > 0 {
+		s += fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return s + " ago"
 }
 
-// Push updates the current hostname's IP address in the specified tender provider.
-// The tender provider can be specified with the -tender flag (default: "gh").
-// Requires PIPHOS_GITHUB_TOKEN environment variable for the "gh" provider.
+// Push updates the current hostname's record in the specified tender provider.
+// The record holds the detected address together with the time, beacon, piphos version and OS.
+// The tender provider can be specified with the -tender flag (default: "gh"). A comma-separated
+// list pushes to several tenders concurrently and reports the outcome of each on stderr;
+// if some of them fail, the others keep the update and an error is returned.
+// Requires a GitHub token for the "gh" provider, see tender.New for where it is looked up.
 // The beacon provider can be specified with the -beacon flag (default: "aws").
-// The hostname is automatically detected from the system.
+// The hostname is taken from the -name flag, the PIPHOS_HOSTNAME environment variable
+// or the system, in that order, and -alias sets comma-separated names resolving to the record;
+// without it, the stored aliases are kept.
+// Names must be valid, see validate.Hostname; the system's hostname is normalized first,
+// dropping its domain unless -keep-domain is set.
+// The record carries an identifier of this machine, so the tender can warn when a
+// different machine pushes under the same name, and is signed with this host's key.
+// A push detects the address of one family only, so the stored address of the other is kept.
+// The -heartbeat flag refreshes the host's last-seen time at most once per given duration
+// even if the IP is unchanged, so live hosts can be told apart from dead ones.
+// The -ttl flag lets prune remove the host once it has not reported in for that long.
+// The -hook flag runs a command or webhook and -notify sends a message if the push changed
+// the stored IP; both happen after the push and their failures are only reported.
+// The -namespace flag selects the set of hosts and -verbose reports diagnostics on stderr.
 func Push(ctx context.Context, args []string) error {
 	fs := flag.NewFlagSet("push", flag.ExitOnError)
-	ts := fs.String("tender", "gh", "which tender provider to use")
-	bs := fs.String("beacon", "aws", "which beacon provider to use")
-	fs.Parse(args)
+	tf := addTenderFlags(fs)
+	pf := addPushFlags(fs)
+	hf := addHookFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
 	if err := validate.Command(fs.NArg()); err != nil {
 		return err
 	}
-	localHostname, err := os.Hostname()
+	hostname, aliases, err := pf.host()
 	if err != nil {
-		return fmt.Errorf("failed to get system's hostname: %w", err)
+		return err
 	}
-	b, err := beacon.New(*bs)
+	b, err := beacon.New(*pf.beacon)
 	if err != nil {
-		return fmt.Errorf("failed to create beacon %s: %w", *bs, err)
+		return fmt.Errorf("failed to create beacon %s: %w", *pf.beacon, err)
 	}
-	t, err := tender.New(*ts)
+	r, err := hf.reactions(*tf.namespace, os.Stderr)
 	if err != nil {
-		return fmt.Errorf("failed to create tender %s: %w", *ts, err)
+		return err
+	}
+	tf.heartbeat = *pf.heartbeat
+	t, err := tf.newTender()
+	if err != nil {
+		return err
 	}
 	publicIP, err := b.Ping(ctx)
 	if err != nil {
 		return err
 	}
-	return t.Push(ctx, localHostname, publicIP)
+	records, err := pullRecords(ctx, t, os.Stderr)
+	if err != nil {
+		return err
+	}
+	record, err := pf.record(hostname, publicIP, aliases, time.Now().UTC(), records[hostname])
+	if err != nil {
+		return err
+	}
+	// A push reaching only some of several tenders still changed the IP, so the hooks run
+	pushErr := t.Push(ctx, hostname, record)
+	if pushErr != nil && !tender.Partial(pushErr) {
+		return pushErr
+	}
+	if r != nil {
+		pushed(ctx, r, hostname, records[hostname], record, *tf.namespace, os.Stderr)
+	}
+	return pushErr
+}
+
+// sign signs record for hostname with this host's key, generating the key on first use.
+func sign(hostname string, record *tender.Record) error {
+	signer, created, err := identity.LoadOrCreateSigner()
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Fprintf(os.Stderr, "generated signing key, trust it on other hosts with: piphos trust add %s %s\n", hostname, signer.PublicKey())
+	}
+	return signer.Sign(hostname, record)
+}
+
+// parseAliases splits a comma-separated list of aliases, dropping empty entries and duplicates.
+// An alias equal to hostname is rejected, since it would resolve to the record anyway.
+func parseAliases(list, hostname string) ([]string, error) {
+	var aliases []string
+	for _, alias := range strings.Split(list, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || slices.Contains(aliases, alias) {
+			continue
+		}
+		if alias == hostname {
+			return nil, fmt.Errorf("alias %s is the host's own name", alias)
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, nil
 }
 
 // Help displays the command-line usage information for piphos.
@@ -91,7 +286,30 @@ func Help() {
 	fmt.Println("  help                                      # print this help message")
 	fmt.Println("  ping                                      # check public IP using a beacon")
 	fmt.Println("  push                                      # push public IP to tender")
-	fmt.Println("  pull                                      # pull stored hostname->IP map from tender")
+	fmt.Println("  watch                                     # keep pushing on IP changes, instead of cron")
+	fmt.Println("  pull                                      # pull stored host records from tender")
+	fmt.Println("  get <host>                                # print a single host's IP")
+	fmt.Println("  ssh [user@]<host> [ssh args]              # connect to a host with the system's ssh")
+	fmt.Println("  ssh-config                                # print ssh_config Host blocks for all hosts")
+	fmt.Println("  hosts sync                                # write all hosts into a section of /etc/hosts")
+	fmt.Println("  serve-dns                                 # answer DNS queries for hosts below a zone")
+	fmt.Println("  auth check                                # verify tender credentials and permissions")
+	fmt.Println("  namespaces                                # list namespaces stored in the tender")
+	fmt.Println("  history [host]                            # show when hosts' IPs changed")
+	fmt.Println("  prune                                     # remove hosts that stopped reporting in")
+	fmt.Println("  rm <host>                                 # remove a host")
+	fmt.Println("  mv <old> <new>                            # rename a host")
+	fmt.Println("  set <host> <ip>                           # set a host's IP by hand")
+	fmt.Println("  key generate                              # create the encryption key and encrypt stored hosts")
+	fmt.Println("  key rotate                                # replace the encryption key and re-encrypt stored hosts")
+	fmt.Println("  identity                                  # print this host's signing key")
+	fmt.Println("  trust add <host> [key]                    # trust a key to sign a host's records")
+	fmt.Println("  trust revoke <host|key>                   # revoke a host's keys or a single key")
+	fmt.Println("  trust list                                # list trusted and revoked keys")
+	fmt.Println("  config show                               # print the effective configuration")
+	fmt.Println("  migrate -from <tender> -to <tender>       # copy all hosts to another tender")
+	fmt.Println("  export                                    # write all hosts as JSON or CSV")
+	fmt.Println("  import <file>                             # load hosts from a JSON or CSV file")
 	fmt.Println("")
 	fmt.Println("examples:")
 	fmt.Println("  piphos ping                               # use default beacon (aws)")
@@ -101,6 +319,43 @@ func Help() {
 	fmt.Println("  piphos push -tender gh -beacon haz        # push to specific tender using specific beacon")
 	fmt.Println("  piphos pull                               # retrieve stored hostname->IP map from default tender (gh)")
 	fmt.Println("  piphos pull -tender gh                    # retrieve stored hostname->IP map from specific tender")
+	fmt.Println("  piphos pull -verbose                      # report where the GitHub token was found")
+	fmt.Println("  ssh admin@$(piphos get home-server)       # connect to a host by name")
+	fmt.Println("  piphos get -family 6 -wait 5m laptop      # wait up to 5 minutes for laptop's IPv6")
+	fmt.Println("  piphos ssh admin@home-server uptime       # run a command on a host by name")
+	fmt.Println("  piphos ssh-config -file ~/.ssh/piphos     # refresh an ssh_config include, e.g. from cron")
+	fmt.Println("  sudo piphos hosts sync -domain piphos     # resolve home-server.piphos everywhere")
+	fmt.Println("  piphos hosts sync -dry-run                # show the change to /etc/hosts as a diff")
+	fmt.Println("  piphos serve-dns -listen 127.0.0.1:5353   # resolve home-server.piphos via DNS")
+	fmt.Println("  piphos push -heartbeat 6h                 # also refresh last-seen every 6h if the IP is unchanged")
+	fmt.Println("  piphos watch -interval 5m -heartbeat 6h   # check every 5 minutes, push on change or every 6h")
+	fmt.Println("  piphos pull -stale 24h                    # mark hosts not seen for a day")
+	fmt.Println("  piphos pull -o json                       # all hosts as JSON, also yaml, csv or table")
+	fmt.Println("  piphos pull -o 'template={{range .}}...'  # format hosts with a Go text/template")
+	fmt.Println("  piphos auth check                         # check token identity, expiry and gist access")
+	fmt.Println("  piphos push -namespace homelab            # push to the homelab namespace")
+	fmt.Println("  piphos namespaces                         # list all namespaces")
+	fmt.Println("  piphos history -since 1h home-server      # did home-server's IP change in the last hour?")
+	fmt.Println("  piphos history -o json                    # full change history as JSON")
+	fmt.Println("  piphos prune -older-than 30d -dry-run     # list hosts not seen for 30 days")
+	fmt.Println("  piphos prune -older-than 30d -undated     # also remove legacy hosts of unknown age")
+	fmt.Println("  piphos push -ttl 7d                       # let prune remove this host after a week of silence")
+	fmt.Println("  piphos push -name pi-kitchen -alias dns   # push under a unique name with an alias")
+	fmt.Println("  piphos push -keep-domain                  # push as laptop.local instead of laptop")
+	fmt.Println("  piphos push -hook ./wg-update.sh          # run a command when this host's IP changes")
+	fmt.Println("  piphos watch -hook https://example.com/h  # POST changed IPs of all hosts to a webhook")
+	fmt.Println("  piphos watch -notify ntfy://ntfy.sh/topic # get a phone notification when an IP changes")
+	fmt.Println("  piphos push -tender gh,file,s3            # push to several tenders, reporting each")
+	fmt.Println("  piphos pull -tender gh,s3                 # merge hosts from several tenders, newest wins")
+	fmt.Println("  piphos migrate -from gh -to s3 -dry-run   # show what moving off gists would copy")
+	fmt.Println("  piphos export -file backup.json           # back up all hosts, e.g. from cron")
+	fmt.Println("  piphos import -namespace office hosts.csv # seed a namespace from a spreadsheet")
+	fmt.Println("  piphos push -profile family               # use the family profile of the config file")
+	fmt.Println("  piphos config show -profile family        # show the settings the family profile results in")
+	fmt.Println("  piphos mv laptpo laptop                   # fix a typo in a hostname")
+	fmt.Println("  piphos set printer 203.0.113.9            # track a host that cannot run piphos")
+	fmt.Println("  piphos trust add server                   # trust the key server currently signs with")
+	fmt.Println("  piphos get -allow-untrusted laptop        # use laptop's IP although its key is not trusted")
 	fmt.Println("")
 	fmt.Println("available beacons:")
 	fmt.Println("  aws (default)                             # https://checkip.amazonaws.com")
@@ -108,5 +363,20 @@ func Help() {
 	fmt.Println("")
 	fmt.Println("available tenders:")
 	fmt.Println("  gh (default)                              # GitHub Gists")
+	fmt.Println("  file                                      # local directory, PIPHOS_FILE_DIR")
+	fmt.Println("  s3                                        # S3-compatible bucket, PIPHOS_S3_BUCKET")
+	fmt.Println("")
+	fmt.Println("S3 settings:")
+	fmt.Println("  PIPHOS_S3_BUCKET, PIPHOS_S3_PREFIX, PIPHOS_S3_REGION, PIPHOS_S3_ENDPOINT,")
+	fmt.Println("  AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN")
+	fmt.Println("")
+	fmt.Println("GitHub token lookup order:")
+	fmt.Println("  PIPHOS_GITHUB_TOKEN, PIPHOS_GITHUB_TOKEN_FILE, GITHUB_TOKEN, GH_TOKEN,")
+	fmt.Println("  gh CLI hosts.yml, OS keyring (secret-tool service piphos account github)")
+	fmt.Println("  or a GitHub App: PIPHOS_GITHUB_APP_ID, PIPHOS_GITHUB_APP_INSTALLATION_ID,")
+	fmt.Println("  PIPHOS_GITHUB_APP_PRIVATE_KEY_FILE")
+	fmt.Println("")
+	fmt.Println("encryption key file:")
+	fmt.Println("  PIPHOS_KEY_FILE, or piphos/key in the user configuration directory")
 	fmt.Println("")
 }
@@ -1,10 +1,17 @@
 package exec
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"os"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
 )
 
 func TestPing(t *testing.T) {
@@ -61,7 +68,7 @@ func TestPull(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx := context.Background()
-			_, err := Pull(ctx, tt.args)
+			_, err := Pull(ctx, tt.args, io.Discard)
 			if tt.expectedError {
 				if err == nil {
 					t.Error("expected error but got nil")
@@ -71,10 +78,20 @@ func TestPull(t *testing.T) {
 	}
 }
 
+// isolateCredentials hides every GitHub token source from the tender.
+func isolateCredentials(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"PIPHOS_GITHUB_TOKEN", "PIPHOS_GITHUB_TOKEN_FILE", "GITHUB_TOKEN", "GH_TOKEN", "AppData", "PIPHOS_GITHUB_APP_ID", "PIPHOS_GITHUB_APP_INSTALLATION_ID", "PIPHOS_GITHUB_APP_PRIVATE_KEY_FILE"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("GH_CONFIG_DIR", t.TempDir())
+	t.Setenv("PATH", "")
+}
+
 func TestPullMissingToken(t *testing.T) {
-	os.Unsetenv("PIPHOS_GITHUB_TOKEN")
+	isolateCredentials(t)
 	ctx := context.Background()
-	_, err := Pull(ctx, []string{})
+	_, err := Pull(ctx, []string{}, io.Discard)
 	if err == nil {
 		t.Error("expected error for missing token but got nil")
 	}
@@ -121,7 +138,7 @@ func TestPush(t *testing.T) {
 }
 
 func TestPushMissingToken(t *testing.T) {
-	os.Unsetenv("PIPHOS_GITHUB_TOKEN")
+	isolateCredentials(t)
 	ctx := context.Background()
 	err := Push(ctx, []string{})
 	if err == nil {
@@ -131,3 +148,122 @@ func TestPushMissingToken(t *testing.T) {
 		t.Errorf("expected token error but got: %v", err)
 	}
 }
+
+func TestHostsResult(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		record      tender.Record
+		stale       time.Duration
+		expectedRow []string
+	}{
+		{
+			name:        "legacy record",
+			record:      tender.NewRecord("203.0.113.1"),
+			expectedRow: []string{"host", "203.0.113.1", "", "", "", "", ""},
+		},
+		{
+			name:        "recently updated",
+			record:      tender.Record{IPv4: "203.0.113.1", UpdatedAt: now.Add(-5 * time.Minute), SeenAt: now.Add(-5 * time.Minute)},
+			expectedRow: []string{"host", "203.0.113.1", "", "", "5m ago", "5m ago", ""},
+		},
+		{
+			name:        "heartbeat newer than update",
+			record:      tender.Record{IPv4: "203.0.113.1", IPv6: "2001:db8::1", UpdatedAt: now.Add(-50 * time.Hour), SeenAt: now.Add(-time.Hour)},
+			stale:       24 * time.Hour,
+			expectedRow: []string{"host", "203.0.113.1", "2001:db8::1", "", "2d2h ago", "1h ago", ""},
+		},
+		{
+			name:        "stale host",
+			record:      tender.Record{IPv4: "203.0.113.1", UpdatedAt: now.Add(-48 * time.Hour), SeenAt: now.Add(-48 * time.Hour)},
+			stale:       24 * time.Hour,
+			expectedRow: []string{"host", "203.0.113.1", "", "", "2d ago", "2d ago", "stale"},
+		},
+		{
+			name:        "aliases",
+			record:      tender.Record{IPv4: "203.0.113.1", Aliases: []string{"nas", "backup"}},
+			expectedRow: []string{"host", "203.0.113.1", "", "nas,backup", "", "", ""},
+		},
+		{
+			name:        "manual host",
+			record:      tender.Record{IPv4: "203.0.113.1", UpdatedAt: now.Add(-time.Hour), Manual: true},
+			stale:       24 * time.Hour,
+			expectedRow: []string{"host", "203.0.113.1", "", "", "1h ago", "", "manual"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts := hostRecords(map[string]tender.Record{"host": tt.record}, now, tt.stale, nil)
+			result := hostsResult(hosts, now)
+			if len(result.Rows) != 1 || !slices.Equal(result.Rows[0], tt.expectedRow) {
+				t.Errorf("expected row %q but got %q", tt.expectedRow, result.Rows)
+			}
+		})
+	}
+}
+
+func TestHostsResult_Sorted(t *testing.T) {
+	records := map[string]tender.Record{
+		"web:8080": tender.NewRecord("203.0.113.3"),
+		"alpha":    tender.NewRecord("203.0.113.1"),
+		"nas":      tender.NewRecord("203.0.113.2"),
+	}
+	var buf bytes.Buffer
+	hosts := hostRecords(records, time.Now(), 0, nil)
+	if err := output.Write(&buf, output.Format{Name: output.CSV}, hostsResult(hosts, time.Now())); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	expected := "HOST,IPV4,IPV6,ALIASES,UPDATED,SEEN,STATUS\n" +
+		"alpha,203.0.113.1,,,,,\n" +
+		"nas,203.0.113.2,,,,,\n" +
+		"web:8080,203.0.113.3,,,,,\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q but got %q", expected, buf.String())
+	}
+}
+
+func TestParseAliases(t *testing.T) {
+	tests := []struct {
+		name            string
+		list            string
+		expectedAliases []string
+		expectedError   bool
+	}{
+		{
+			name: "no aliases",
+			list: "",
+		},
+		{
+			name:            "aliases",
+			list:            "pihole, dns",
+			expectedAliases: []string{"pihole", "dns"},
+		},
+		{
+			name:            "empty entries and duplicates",
+			list:            "pihole,,dns,pihole",
+			expectedAliases: []string{"pihole", "dns"},
+		},
+		{
+			name:          "own name",
+			list:          "pihole,raspberrypi",
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aliases, err := parseAliases(tt.list, "raspberrypi")
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if !slices.Equal(aliases, tt.expectedAliases) {
+				t.Errorf("expected %v but got %v", tt.expectedAliases, aliases)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package exec
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/kappapee/piphos/internal/config"
+)
+
+// setting is a default for a flag that can be given in the configuration files.
+type setting struct {
+	name string
+	// flag is the name of the flag, if it differs from name.
+	flag string
+	// env is the environment variable overriding the configuration files, if any.
+	env string
+	// repeatable settings take a list, like their flag may be repeated.
+	repeatable bool
+	// secret settings may contain credentials, which config show redacts.
+	secret bool
+}
+
+// settings lists the settings of the configuration files, in the order config show prints them.
+var settings = []setting{
+	{name: "tender"},
+	{name: "beacon"},
+	{name: "namespace", env: "PIPHOS_NAMESPACE"},
+	{name: "name", env: "PIPHOS_HOSTNAME"},
+	{name: "keep-domain"},
+	{name: "alias"},
+	{name: "allow-untrusted"},
+	{name: "heartbeat"},
+	{name: "ttl"},
+	{name: "interval"},
+	{name: "stale"},
+	{name: "family"},
+	{name: "output", flag: "o"},
+	{name: "hook", repeatable: true, secret: true},
+	{name: "hook-timeout"},
+	{name: "notify", env: "PIPHOS_NOTIFY", repeatable: true, secret: true},
+	{name: "notify-interval"},
+	{name: "verbose"},
+}
+
+func (s setting) flagName() string {
+	if s.flag != "" {
+		return s.flag
+	}
+	return s.name
+}
+
+// resolved is the value of a setting together with where it came from.
+type resolved struct {
+	values []string
+	source string
+}
+
+// parseFlags registers -profile on fs and parses args. Every flag that args do not set
+// takes its value from the environment, the selected profile or the defaults of the
+// configuration files, in this order, see config.Load.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	profile := fs.String("profile", "", "configuration profile to use (default: PIPHOS_PROFILE, or the profile setting)")
+	fs.Parse(args)
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	values, _, _, err := resolveSettings(*profile)
+	if err != nil {
+		return err
+	}
+	for _, s := range settings {
+		name := s.flagName()
+		v, ok := values[s.name]
+		if !ok || explicit[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		for _, value := range v.values {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("invalid %s setting from %s: %w", s.name, v.source, err)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveSettings returns the settings of the configuration files, overridden by those of
+// profile and of the environment, as well as the files read and the selected profile.
+// Without profile, PIPHOS_PROFILE or else the profile setting selects one.
+func resolveSettings(profile string) (map[string]resolved, []string, string, error) {
+	f, files, err := config.Load()
+	if err != nil {
+		return nil, nil, "", err
+	}
+	values := map[string]resolved{}
+	if err := addSettings(values, f.Defaults, "config file"); err != nil {
+		return nil, nil, "", err
+	}
+	if profile == "" {
+		profile = os.Getenv("PIPHOS_PROFILE")
+	}
+	if profile == "" && len(f.Defaults["profile"]) == 1 {
+		profile = f.Defaults["profile"][0]
+	}
+	if profile != "" {
+		overrides, err := f.Profile(profile)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		if err := addSettings(values, overrides, "profile "+profile); err != nil {
+			return nil, nil, "", err
+		}
+	}
+	for _, s := range settings {
+		value := os.Getenv(s.env)
+		if s.env == "" || value == "" {
+			continue
+		}
+		if s.repeatable {
+			values[s.name] = resolved{values: strings.Fields(value), source: s.env}
+		} else {
+			values[s.name] = resolved{values: []string{value}, source: s.env}
+		}
+	}
+	return values, files, profile, nil
+}
+
+// addSettings adds the settings from source to values, rejecting unknown settings and
+// lists for settings that take a single value.
+func addSettings(values map[string]resolved, from config.Settings, source string) error {
+	for name, v := range from {
+		if name == "profile" && source == "config file" {
+			continue
+		}
+		i := settingIndex(name)
+		if i < 0 {
+			return fmt.Errorf("unknown setting %q in %s", name, source)
+		}
+		if !settings[i].repeatable && len(v) != 1 {
+			return fmt.Errorf("setting %q in %s takes a single value", name, source)
+		}
+		values[name] = resolved{values: v, source: source}
+	}
+	return nil
+}
+
+// settingIndex returns the index of the setting called name in settings, or -1.
+func settingIndex(name string) int {
+	for i, s := range settings {
+		if s.name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// ConfigShow writes the effective configuration to w in the format of the configuration
+// file, each setting commented with where it came from. Settings that no file, profile or
+// environment variable set are listed with their built-in default, if they have one.
+// Hook commands are redacted, and hook and notifier URLs are reduced to their scheme and host.
+// The -profile flag selects the profile to show (default: PIPHOS_PROFILE, or the profile setting).
+func ConfigShow(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("config show", flag.ExitOnError)
+	profile := fs.String("profile", "", "configuration profile to show (default: PIPHOS_PROFILE, or the profile setting)")
+	fs.Parse(args)
+	values, files, selected, err := resolveSettings(*profile)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(w, "# no config file found, looked for %s\n", strings.Join(config.Files(), ", "))
+	}
+	for _, path := range files {
+		fmt.Fprintf(w, "# read %s\n", path)
+	}
+	if selected != "" {
+		fmt.Fprintf(w, "# profile %s\n", selected)
+	}
+	// The flags shared by the commands know the built-in defaults
+	defaults := flag.NewFlagSet("defaults", flag.ContinueOnError)
+	addTenderFlags(defaults)
+	addPushFlags(defaults)
+	addHookFlags(defaults)
+	outputVar(defaults)
+	for _, s := range settings {
+		v, ok := values[s.name]
+		if !ok {
+			f := defaults.Lookup(s.flagName())
+			if f == nil || f.DefValue == "" || f.DefValue == "0s" || f.DefValue == "false" {
+				continue
+			}
+			v = resolved{values: []string{f.DefValue}, source: "default"}
+		}
+		fmt.Fprintf(w, "%s = %s # %s\n", s.name, formatSetting(s, v.values), v.source)
+	}
+	return nil
+}
+
+// formatSetting returns values as a TOML value, redacting credentials of secret settings.
+func formatSetting(s setting, values []string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		if s.secret {
+			value = redact(value)
+		}
+		if value == "true" || value == "false" {
+			quoted[i] = value
+		} else {
+			quoted[i] = strconv.Quote(value)
+		}
+	}
+	if !s.repeatable {
+		return quoted[0]
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// redact hides a value of a secret setting. Of a URL only the scheme and host are kept,
+// as notifiers and webhooks keep their tokens in the user info, path or query, and any
+// other value, such as a hook command passing a token in its arguments, is hidden entirely.
+func redact(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "REDACTED"
+	}
+	if kept := u.Scheme + "://" + u.Host; kept != value {
+		return kept + "/REDACTED"
+	}
+	return value
+}
@@ -0,0 +1,107 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kappapee/piphos/internal/dns"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// ServeDNS runs a DNS server answering A and AAAA queries for the stored hosts below a zone,
+// e.g. "home-server.piphos." with the default zone, over UDP and TCP on the -listen address.
+// The hosts are pulled again every -refresh interval; if a pull fails, the last hosts are
+// served on. Only usable records are answered, see usableRecords and -allow-untrusted.
+// The -ttl flag sets the TTL of the answers. Messages are logged to w.
+// It runs until ctx is done or the process receives SIGINT or SIGTERM.
+func ServeDNS(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("serve-dns", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	listen := fs.String("listen", "127.0.0.1:5353", "address to listen on for UDP and TCP queries")
+	zone := fs.String("zone", "piphos.", "zone below which hosts are answered")
+	ttl := durationVar(fs, "ttl", "TTL of the answers, e.g. 30s (default: 1m)")
+	refresh := durationVar(fs, "refresh", "how often to pull the hosts again, e.g. 1h (default: 5m)")
+	allowUntrusted := addTrustFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return err
+	}
+	if *ttl == 0 {
+		*ttl = time.Minute
+	}
+	if *refresh == 0 {
+		*refresh = 5 * time.Minute
+	}
+	if *refresh < 0 {
+		return fmt.Errorf("invalid refresh interval: %s", *refresh)
+	}
+	server, err := dns.NewServer(*zone, *ttl)
+	if err != nil {
+		return err
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return err
+	}
+	records, err := pullRecords(ctx, t, w)
+	if err != nil {
+		return err
+	}
+	records, err = usableRecords(records, *allowUntrusted, w)
+	if err != nil {
+		return err
+	}
+	server.SetRecords(records)
+	conn, err := net.ListenPacket("udp", *listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	defer conn.Close()
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	defer l.Close()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	errs := make(chan error, 2)
+	go func() { errs <- server.ServeUDP(conn) }()
+	go func() { errs <- server.ServeTCP(l) }()
+	fmt.Fprintf(w, "serving %d hosts in zone %s on %s\n", len(records), server.Zone(), *listen)
+	return refreshHosts(ctx, t, server, *refresh, *allowUntrusted, errs, w)
+}
+
+// refreshHosts pulls the usable hosts, see usableRecords, into server every interval until
+// ctx is done or serving fails with an error received from errs.
+func refreshHosts(ctx context.Context, t tender.Tender, server *dns.Server, interval time.Duration, allowUntrusted bool, errs <-chan error, w io.Writer) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			return err
+		case <-ticker.C:
+			records, err := pullRecords(ctx, t, w)
+			if err == nil {
+				records, err = usableRecords(records, allowUntrusted, w)
+			}
+			if err != nil {
+				fmt.Fprintf(w, "failed to refresh hosts, serving the previous ones: %v\n", err)
+				continue
+			}
+			server.SetRecords(records)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/dns"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestServeDNS(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "extra arguments", args: []string{"extra"}},
+		{name: "invalid zone", args: []string{"-zone", "home..example"}},
+		{name: "invalid refresh", args: []string{"-refresh", "-1m"}},
+		{name: "invalid ttl", args: []string{"-ttl", "-1s"}},
+		{name: "unknown tender", args: []string{"-tender", "unknown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ServeDNS(context.Background(), tt.args, io.Discard); err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestRefreshHosts(t *testing.T) {
+	server, err := dns.NewServer("piphos.", time.Minute)
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	ft := &fakeTender{pulls: []map[string]tender.Record{{"server": tender.NewRecord("203.0.113.1")}}}
+	serveErr := errors.New("serving failed")
+	errs := make(chan error, 1)
+	errs <- serveErr
+	if err := refreshHosts(context.Background(), ft, server, time.Hour, false, errs, io.Discard); !errors.Is(err, serveErr) {
+		t.Errorf("expected %v but got: %v", serveErr, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := refreshHosts(ctx, ft, server, time.Hour, false, make(chan error), io.Discard); err != nil {
+		t.Errorf("expected no error after cancellation but got: %v", err)
+	}
+}
@@ -0,0 +1,340 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kappapee/piphos/internal/fileutil"
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// Formats of export and import.
+const (
+	exportJSON = "json"
+	exportCSV  = "csv"
+)
+
+// csvColumns are the columns export writes and import reads, in order. Import also
+// accepts an ip column holding either address, and ignores columns it does not know.
+var csvColumns = []string{"host", "ipv4", "ipv6", "aliases", "updated_at", "seen_at", "ttl", "manual", "beacon", "version", "os", "machine_id", "public_key", "signature"}
+
+// Export writes all host records of the namespace to w, or to the file given by -file,
+// which is replaced atomically. The -format flag selects json (default), the format the
+// tenders store, or csv with a header row and a column per field, see csvColumns.
+// Encrypted records are exported decrypted.
+func Export(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	format := fs.String("format", exportJSON, "format to export: json or csv")
+	file := fs.String("file", "", "write to this file instead of stdout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return err
+	}
+	if *format != exportJSON && *format != exportCSV {
+		return fmt.Errorf("unknown export format %q, use json or csv", *format)
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return err
+	}
+	// A backup missing the hosts of a failed tender would look complete, so fail instead
+	records, err := t.Pull(ctx)
+	if err != nil {
+		return err
+	}
+	if records == nil {
+		records = map[string]tender.Record{}
+	}
+	var content []byte
+	if *format == exportCSV {
+		content, err = encodeCSV(records)
+	} else {
+		content, err = tender.EncodeHosts(records)
+		content = append(content, '\n')
+	}
+	if err != nil {
+		return err
+	}
+	if *file != "" {
+		return fileutil.WriteAtomic(*file, content, 0o600)
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// encodeCSV writes records as CSV sorted by hostname, see csvColumns.
+func encodeCSV(records map[string]tender.Record) ([]byte, error) {
+	var b strings.Builder
+	cw := csv.NewWriter(&b)
+	cw.Write(csvColumns)
+	for _, hostname := range slices.Sorted(maps.Keys(records)) {
+		r := records[hostname]
+		ttl := ""
+		if r.TTL > 0 {
+			ttl = time.Duration(r.TTL).String()
+		}
+		cw.Write([]string{
+			hostname, r.IPv4, r.IPv6, strings.Join(r.Aliases, ","),
+			formatTime(r.UpdatedAt), formatTime(r.SeenAt), ttl, strconv.FormatBool(r.Manual),
+			r.Beacon, r.Version, r.OS, r.MachineID, r.PublicKey, r.Signature,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return nil, fmt.Errorf("failed to write CSV: %w", err)
+	}
+	return []byte(b.String()), nil
+}
+
+// formatTime returns t in RFC 3339 with the precision it is stored with, or "" if it is zero.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// Import reads host records from the file given as argument, or stdin for "-", and
+// stores them in the tender, writing what it did with each host to w as migrate does.
+// The -format flag selects json or csv (default: by the file extension, json otherwise).
+// Every hostname, alias and address is validated first, and if any is invalid all
+// problems are reported and nothing is written. The valid records are stored in a
+// single write, with -conflict deciding about hosts already stored (default: keep-newer).
+// The conflicts are decided against the records that write replaces, see copyInto.
+// With -dry-run, nothing is written. The -o flag selects the output format, see output.Format.
+func Import(ctx context.Context, args []string, w io.Writer) ([]MigrateStep, error) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	format := fs.String("format", "", "format to import: json or csv (default: by file extension)")
+	conflict := fs.String("conflict", conflictKeepNewer, "what to do with hosts already stored: overwrite, keep-newer or skip")
+	dryRun := fs.Bool("dry-run", false, "validate and show what would be imported without writing anything")
+	outFormat := outputVar(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if err := validate.CommandArgs(fs.NArg(), 1, 1); err != nil {
+		return nil, err
+	}
+	if !slices.Contains([]string{conflictOverwrite, conflictKeepNewer, conflictSkip}, *conflict) {
+		return nil, fmt.Errorf("unknown conflict strategy %q, use overwrite, keep-newer or skip", *conflict)
+	}
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = exportJSON
+		if strings.EqualFold(filepath.Ext(path), ".csv") {
+			*format = exportCSV
+		}
+	}
+	if *format != exportJSON && *format != exportCSV {
+		return nil, fmt.Errorf("unknown import format %q, use json or csv", *format)
+	}
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var records map[string]tender.Record
+	var problems []error
+	if *format == exportCSV {
+		records, problems = decodeCSV(content)
+	} else {
+		records, problems = decodeJSON(content)
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("found %d problem(s), nothing was imported:\n%w", len(problems), errors.Join(problems...))
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return nil, err
+	}
+	steps, err := copyInto(ctx, t, records, *conflict, *dryRun)
+	if err != nil {
+		return nil, err
+	}
+	table := output.Result{Value: steps, Header: []string{"HOST", "ACTION"}}
+	for _, s := range steps {
+		table.Rows = append(table.Rows, []string{s.Host, s.Action})
+	}
+	return steps, output.Write(w, *outFormat, table)
+}
+
+// decodeJSON parses an export in the stored document format and validates every record.
+func decodeJSON(content []byte) (map[string]tender.Record, []error) {
+	records, err := tender.DecodeHosts(content)
+	if err != nil {
+		return nil, []error{err}
+	}
+	var problems []error
+	for _, hostname := range slices.Sorted(maps.Keys(records)) {
+		for _, err := range validateRecord(hostname, records[hostname]) {
+			problems = append(problems, fmt.Errorf("host %s: %w", hostname, err))
+		}
+	}
+	return records, problems
+}
+
+// decodeCSV parses CSV with a header row naming the columns, see csvColumns, and
+// validates every row. Problems are reported with the line they were found on.
+func decodeCSV(content []byte) (map[string]tender.Record, []error) {
+	// Spreadsheets often start their CSV exports with a byte order mark
+	content = bytes.TrimPrefix(content, []byte("\ufeff"))
+	cr := csv.NewReader(bytes.NewReader(content))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, []error{fmt.Errorf("no header row")}
+	}
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to read CSV: %w", err)}
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["host"]; !ok {
+		return nil, []error{fmt.Errorf("no host column in header row")}
+	}
+	records := map[string]tender.Record{}
+	lines := map[string]int{}
+	var problems []error
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// The rest of the file cannot be split into rows reliably
+			return nil, append(problems, fmt.Errorf("failed to read CSV: %w", err))
+		}
+		line, _ := cr.FieldPos(0)
+		field := func(name string) string {
+			if j, ok := columns[name]; ok && j < len(row) {
+				return strings.TrimSpace(row[j])
+			}
+			return ""
+		}
+		hostname := field("host")
+		record, errs := parseCSVRecord(hostname, field)
+		errs = append(errs, validateRecord(hostname, record)...)
+		if first, ok := lines[hostname]; ok && hostname != "" {
+			errs = append(errs, fmt.Errorf("host %s already appears on line %d", hostname, first))
+		}
+		for _, err := range errs {
+			problems = append(problems, fmt.Errorf("line %d: %w", line, err))
+		}
+		lines[hostname] = line
+		records[hostname] = record
+	}
+	return records, problems
+}
+
+// parseCSVRecord builds the record of hostname from the fields of a CSV row.
+func parseCSVRecord(hostname string, field func(name string) string) (tender.Record, []error) {
+	var problems []error
+	record := tender.Record{
+		IPv4:      field("ipv4"),
+		IPv6:      field("ipv6"),
+		Beacon:    field("beacon"),
+		Version:   field("version"),
+		OS:        field("os"),
+		MachineID: field("machine_id"),
+		PublicKey: field("public_key"),
+		Signature: field("signature"),
+	}
+	// An invalid address is kept as IPv4 so validateRecord reports it
+	if ip := field("ip"); ip != "" {
+		record.SetIP(ip)
+	}
+	if list := field("aliases"); list != "" {
+		aliases, err := parseAliases(list, hostname)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		record.Aliases = aliases
+	}
+	for _, column := range []struct {
+		name string
+		t    *time.Time
+	}{{"updated_at", &record.UpdatedAt}, {"seen_at", &record.SeenAt}} {
+		value := field(column.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("invalid %s %q: expected a time such as 2025-06-01T12:00:00Z", column.name, value))
+			continue
+		}
+		*column.t = t
+	}
+	if value := field("ttl"); value != "" {
+		ttl, err := parseDuration(value)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("invalid ttl: %w", err))
+		}
+		record.TTL = tender.Duration(ttl)
+	}
+	if value := field("manual"); value != "" {
+		manual, err := strconv.ParseBool(value)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("invalid manual %q: expected true or false", value))
+		}
+		record.Manual = manual
+	}
+	return record, problems
+}
+
+// validateRecord checks the hostname, aliases and addresses of an imported record.
+func validateRecord(hostname string, r tender.Record) []error {
+	var problems []error
+	if err := validate.Hostname(hostname); err != nil {
+		problems = append(problems, err)
+	}
+	for _, alias := range r.Aliases {
+		if err := validate.Hostname(alias); err != nil {
+			problems = append(problems, fmt.Errorf("alias: %w", err))
+		}
+	}
+	if r.IPv4 == "" && r.IPv6 == "" {
+		problems = append(problems, fmt.Errorf("no IP address"))
+	}
+	if r.IPv4 != "" {
+		if err := validate.IP(r.IPv4); err != nil {
+			problems = append(problems, err)
+		} else if net.ParseIP(r.IPv4).To4() == nil {
+			problems = append(problems, fmt.Errorf("%s is not an IPv4 address", r.IPv4))
+		}
+	}
+	if r.IPv6 != "" {
+		if err := validate.IP(r.IPv6); err != nil {
+			problems = append(problems, err)
+		} else if net.ParseIP(r.IPv6).To4() != nil {
+			problems = append(problems, fmt.Errorf("%s is not an IPv6 address", r.IPv6))
+		}
+	}
+	return problems
+}
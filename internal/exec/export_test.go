@@ -0,0 +1,183 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestDecodeCSV(t *testing.T) {
+	tests := []struct {
+		name             string
+		content          string
+		expectedHosts    int
+		expectedProblems []string
+	}{
+		{
+			name:          "inventory with ip column",
+			content:       "Host,IP,Owner\nprinter,203.0.113.9,office\nnas,2001:db8::5,it\n",
+			expectedHosts: 2,
+		},
+		{
+			name:          "byte order mark",
+			content:       "\ufeffhost,ipv4\nprinter,203.0.113.9\n",
+			expectedHosts: 1,
+		},
+		{
+			name:          "all fields",
+			content:       "host,ipv4,ipv6,aliases,updated_at,seen_at,ttl,manual\nserver,203.0.113.2,2001:db8::2,\"nas,backup\",2025-06-01T12:00:00Z,2025-06-02T12:00:00.5Z,30d,true\n",
+			expectedHosts: 1,
+		},
+		{
+			name:    "invalid rows",
+			content: "host,ip,ttl\nlap_top,203.0.113.1,\nserver,300.0.0.1,\nnas,,forever\nserver,203.0.113.3,\n",
+			expectedProblems: []string{
+				`line 2: invalid hostname "lap_top"`,
+				"line 3: invalid IP address format for IP 300.0.0.1",
+				"line 4: invalid ttl",
+				"line 4: no IP address",
+				"line 5: host server already appears on line 3",
+			},
+		},
+		{
+			name:             "address of the wrong family",
+			content:          "host,ipv4,ipv6\nserver,2001:db8::2,203.0.113.2\n",
+			expectedProblems: []string{"line 2: 2001:db8::2 is not an IPv4 address", "line 2: 203.0.113.2 is not an IPv6 address"},
+		},
+		{
+			name:             "invalid alias",
+			content:          "host,ip,aliases\nserver,203.0.113.2,\"nas,my backup\"\n",
+			expectedProblems: []string{`line 2: alias: invalid hostname "my backup"`},
+		},
+		{
+			name:             "missing host column",
+			content:          "name,ip\nserver,203.0.113.2\n",
+			expectedProblems: []string{"no host column"},
+		},
+		{
+			name:             "empty",
+			content:          "",
+			expectedProblems: []string{"no header row"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, problems := decodeCSV([]byte(tt.content))
+			if len(problems) != len(tt.expectedProblems) {
+				t.Fatalf("expected %d problems but got %v", len(tt.expectedProblems), problems)
+			}
+			for i, expected := range tt.expectedProblems {
+				if !strings.Contains(problems[i].Error(), expected) {
+					t.Errorf("expected problem %q but got %q", expected, problems[i])
+				}
+			}
+			if len(problems) == 0 && len(records) != tt.expectedHosts {
+				t.Errorf("expected %d hosts but got %+v", tt.expectedHosts, records)
+			}
+		})
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	records := map[string]tender.Record{
+		"server": {
+			IPv4:      "203.0.113.2",
+			IPv6:      "2001:db8::2",
+			Aliases:   []string{"nas", "backup"},
+			UpdatedAt: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC),
+			SeenAt:    time.Date(2025, 6, 2, 12, 0, 0, 500, time.UTC),
+			TTL:       tender.Duration(30 * 24 * time.Hour),
+			Beacon:    "aws",
+			Version:   "1.2.3",
+			OS:        "linux/arm64",
+			MachineID: "abc",
+			PublicKey: "key",
+			Signature: "sig",
+		},
+		"printer": {IPv4: "203.0.113.9", Manual: true},
+	}
+	content, err := encodeCSV(records)
+	if err != nil {
+		t.Fatalf("failed to encode: %v", err)
+	}
+	decoded, problems := decodeCSV(content)
+	if len(problems) > 0 {
+		t.Fatalf("expected no problems but got %v", problems)
+	}
+	for hostname, expected := range records {
+		if !sameRecord(decoded[hostname], expected) {
+			t.Errorf("expected %+v for %s but got %+v", expected, hostname, decoded[hostname])
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	_, problems := decodeJSON([]byte(`{"schema": 2, "hosts": {"Bad Name": {"ipv4": "203.0.113.1"}, "server": {"ipv4": "2001:db8::1"}}}`))
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems but got %v", problems)
+	}
+	if !strings.HasPrefix(problems[0].Error(), "host Bad Name: ") || !strings.HasPrefix(problems[1].Error(), "host server: ") {
+		t.Errorf("expected problems to name their host but got %v", problems)
+	}
+	records, problems := decodeJSON([]byte(`{"laptop": "203.0.113.1"}`))
+	if len(problems) > 0 || records["laptop"].IPv4 != "203.0.113.1" {
+		t.Errorf("expected the legacy format to be accepted but got %+v, %v", records, problems)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	withoutConfig(t)
+	t.Setenv("PIPHOS_KEY_FILE", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PIPHOS_FILE_DIR", t.TempDir())
+	ctx := context.Background()
+	dir := t.TempDir()
+	inventory := filepath.Join(dir, "inventory.csv")
+	if err := os.WriteFile(inventory, []byte("host,ip\nprinter,203.0.113.9\nnas,203.0.113.5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	steps, err := Import(ctx, []string{"-tender", "file", "-namespace", "office", inventory}, io.Discard)
+	if err != nil {
+		t.Fatalf("failed to import: %v", err)
+	}
+	if len(steps) != 2 || steps[0].Action != "copy" {
+		t.Errorf("expected both hosts to be copied but got %+v", steps)
+	}
+	backup := filepath.Join(dir, "backup.json")
+	if err := Export(ctx, []string{"-tender", "file", "-namespace", "office", "-file", backup}, io.Discard); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	content, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatalf("expected backup file: %v", err)
+	}
+	records, err := tender.DecodeHosts(content)
+	if err != nil || records["printer"].IPv4 != "203.0.113.9" || len(records) != 2 {
+		t.Errorf("unexpected backup %s: %v", content, err)
+	}
+	broken := filepath.Join(dir, "broken.csv")
+	if err := os.WriteFile(broken, []byte("host,ip\nscanner,203.0.113.7\nbad host,203.0.113.8\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Import(ctx, []string{"-tender", "file", "-namespace", "office", broken}, io.Discard); err == nil || !strings.Contains(err.Error(), "nothing was imported") {
+		t.Errorf("expected the import to be refused but got: %v", err)
+	}
+	var out bytes.Buffer
+	if err := Export(ctx, []string{"-tender", "file", "-namespace", "office", "-format", "csv"}, &out); err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	if strings.Contains(out.String(), "scanner") {
+		t.Errorf("expected no host of a refused import to be written but got:\n%s", out.String())
+	}
+	if !strings.HasPrefix(out.String(), "host,ipv4,ipv6,") || !strings.Contains(out.String(), "printer,203.0.113.9,") {
+		t.Errorf("unexpected CSV export:\n%s", out.String())
+	}
+}
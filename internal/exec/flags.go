@@ -0,0 +1,393 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kappapee/piphos/internal/config"
+	"github.com/kappapee/piphos/internal/crypt"
+	"github.com/kappapee/piphos/internal/hook"
+	"github.com/kappapee/piphos/internal/identity"
+	"github.com/kappapee/piphos/internal/machine"
+	"github.com/kappapee/piphos/internal/notify"
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// tenderFlags holds the flags shared by all commands using a tender.
+type tenderFlags struct {
+	name      *string
+	namespace *string
+	verbose   *bool
+	// heartbeat is set by commands that write host records.
+	heartbeat time.Duration
+	// plaintext is set by commands encrypting hosts stored before the key file existed,
+	// see crypt.Keyring.AllowPlaintext.
+	plaintext bool
+}
+
+// addTenderFlags registers -tender, -namespace and -verbose on fs.
+// The namespace defaults to the PIPHOS_NAMESPACE environment variable, see parseFlags.
+func addTenderFlags(fs *flag.FlagSet) *tenderFlags {
+	return &tenderFlags{
+		name:      fs.String("tender", "gh", "which tender provider to use"),
+		namespace: fs.String("namespace", "", "which namespace of hosts to use"),
+		verbose:   fs.Bool("verbose", false, "print diagnostics to stderr"),
+	}
+}
+
+// newTender creates the tender selected by the flags.
+func (tf *tenderFlags) newTender() (tender.Tender, error) {
+	opts := tender.Options{Namespace: *tf.namespace, Heartbeat: tf.heartbeat, Warnings: os.Stderr, Report: os.Stderr}
+	if *tf.verbose {
+		opts.Verbose = os.Stderr
+	}
+	keyring, err := crypt.Load()
+	if err != nil {
+		return nil, err
+	}
+	if keyring != nil && tf.plaintext {
+		keyring = keyring.AllowPlaintext()
+	}
+	if keyring != nil {
+		opts.Codec = keyring
+		if *tf.verbose {
+			fmt.Fprintf(os.Stderr, "encrypting with key %s\n", keyring.Keys()[0].ID())
+		}
+	}
+	t, err := tender.New(*tf.name, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tender %s: %w", *tf.name, err)
+	}
+	return t, nil
+}
+
+// pullRecords pulls the records of t. If t combines several tenders and only some of
+// them failed, the failure is reported as a warning on w and the records of the others
+// are used.
+func pullRecords(ctx context.Context, t tender.Tender, w io.Writer) (map[string]tender.Record, error) {
+	records, err := t.Pull(ctx)
+	if tender.Partial(err) {
+		fmt.Fprintf(w, "warning: %v\n", err)
+		return records, nil
+	}
+	return records, err
+}
+
+// addTrustFlag registers -allow-untrusted on fs, see usableRecords.
+func addTrustFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("allow-untrusted", false, "also use records not signed by a trusted key (default: skip them once any key is trusted)")
+}
+
+// usableRecords returns the records a command may act on: once any key is trusted, records
+// whose signature does not verify, see identity.TrustStore.Verify, are dropped unless
+// allowUntrusted is set, and the rest is checked with checkRecords.
+// Everything dropped is reported on w.
+func usableRecords(records map[string]tender.Record, allowUntrusted bool, w io.Writer) (map[string]tender.Record, error) {
+	trusted, err := trustedRecords(records, allowUntrusted, w)
+	if err != nil {
+		return nil, err
+	}
+	return checkRecords(trusted, records, w), nil
+}
+
+// trustedRecords returns the records signed by a key trusted for their hostname, or all of
+// them if no key is trusted or allowUntrusted is set. The others are reported on w.
+func trustedRecords(records map[string]tender.Record, allowUntrusted bool, w io.Writer) (map[string]tender.Record, error) {
+	if allowUntrusted {
+		return records, nil
+	}
+	trust, err := identity.LoadTrustStore()
+	if err != nil {
+		return nil, err
+	}
+	if trust.Empty() {
+		return records, nil
+	}
+	trusted := make(map[string]tender.Record, len(records))
+	for _, hostname := range slices.Sorted(maps.Keys(records)) {
+		if status := trust.Verify(hostname, records[hostname]); status != identity.Verified {
+			fmt.Fprintf(w, "warning: skipping host %s: %s (hint: trust its key or use -allow-untrusted)\n", hostname, status)
+			continue
+		}
+		trusted[hostname] = records[hostname]
+	}
+	return trusted, nil
+}
+
+// checkRecords returns the records that are safe to write into ssh_config, hosts files
+// and DNS answers: hosts whose name is invalid, see validate.Hostname, are dropped, as are
+// invalid aliases and addresses that do not parse as an address of their field's family,
+// so a record written by anyone holding the token cannot inject lines into those files.
+// Aliases equal to the hostname of another record of pulled, or claimed by several of its
+// hosts, are dropped as well: a key is only trusted for a hostname, so a record must not
+// take over another host's name through its aliases, and ssh_config and hosts files use
+// the first match. Pulled holds all records, including those dropped as untrusted.
+// Hosts left without an address are dropped too. Everything dropped is reported on w.
+func checkRecords(records, pulled map[string]tender.Record, w io.Writer) map[string]tender.Record {
+	claims := map[string]int{}
+	for _, r := range pulled {
+		for _, alias := range slices.Compact(slices.Sorted(slices.Values(r.Aliases))) {
+			claims[alias]++
+		}
+	}
+	checked := make(map[string]tender.Record, len(records))
+	for _, hostname := range slices.Sorted(maps.Keys(records)) {
+		r := records[hostname]
+		if err := validate.Hostname(hostname); err != nil {
+			fmt.Fprintf(w, "warning: skipping host: %v\n", err)
+			continue
+		}
+		r.Aliases = slices.DeleteFunc(slices.Clone(r.Aliases), func(alias string) bool {
+			if err := validate.Hostname(alias); err != nil {
+				fmt.Fprintf(w, "warning: skipping alias of %s: %v\n", hostname, err)
+				return true
+			}
+			if _, ok := pulled[alias]; ok && alias != hostname {
+				fmt.Fprintf(w, "warning: skipping alias %s of %s: it is the name of another host\n", alias, hostname)
+				return true
+			}
+			if claims[alias] > 1 {
+				fmt.Fprintf(w, "warning: skipping alias %s of %s: it is claimed by several hosts\n", alias, hostname)
+				return true
+			}
+			return false
+		})
+		if r.IPv4 != "" && !validAddress(r.IPv4, true) {
+			fmt.Fprintf(w, "warning: skipping IPv4 address of %s: invalid address %q\n", hostname, r.IPv4)
+			r.IPv4 = ""
+		}
+		if r.IPv6 != "" && !validAddress(r.IPv6, false) {
+			fmt.Fprintf(w, "warning: skipping IPv6 address of %s: invalid address %q\n", hostname, r.IPv6)
+			r.IPv6 = ""
+		}
+		if r.IPv4 == "" && r.IPv6 == "" {
+			continue
+		}
+		checked[hostname] = r
+	}
+	return checked
+}
+
+// validAddress reports whether ip is a plain IPv4 address, or IPv6 address without a zone if v4 is false.
+func validAddress(ip string, v4 bool) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || addr.Zone() != "" {
+		return false
+	}
+	if v4 {
+		return addr.Is4()
+	}
+	return addr.Is6() && !addr.Is4In6()
+}
+
+// pushFlags holds the flags shared by the commands pushing this host's record.
+type pushFlags struct {
+	beacon     *string
+	heartbeat  *time.Duration
+	ttl        *time.Duration
+	name       *string
+	keepDomain *bool
+	aliases    *string
+	fs         *flag.FlagSet
+}
+
+// addPushFlags registers -beacon, -heartbeat, -ttl, -name, -keep-domain and -alias on fs.
+// The name defaults to the PIPHOS_HOSTNAME environment variable, see parseFlags.
+func addPushFlags(fs *flag.FlagSet) *pushFlags {
+	return &pushFlags{
+		beacon:     fs.String("beacon", "aws", "which beacon provider to use"),
+		heartbeat:  durationVar(fs, "heartbeat", "refresh the last-seen time at most this often while the IP is unchanged, e.g. 6h (default: off)"),
+		ttl:        durationVar(fs, "ttl", "let prune remove this host after it has not reported in for this long, e.g. 30d (default: never)"),
+		name:       fs.String("name", "", "name to push under (default: the system's hostname)"),
+		keepDomain: fs.Bool("keep-domain", false, "keep the domain of the system's hostname, e.g. laptop.local instead of laptop"),
+		aliases:    fs.String("alias", "", "comma-separated additional names for this host (default: keep the stored ones)"),
+		fs:         fs,
+	}
+}
+
+// aliasesSet reports whether -alias was given, on the command line or in the configuration.
+func (pf *pushFlags) aliasesSet() bool {
+	set := false
+	pf.fs.Visit(func(f *flag.Flag) { set = set || f.Name == "alias" })
+	return set
+}
+
+// systemHostname returns the system's hostname; tests replace it.
+var systemHostname = os.Hostname
+
+// host returns the name to push under and the aliases. A name given with -name is used as
+// is and must be valid, see validate.Hostname. Otherwise the system's hostname is
+// normalized, see validate.NormalizeHostname, dropping its domain unless -keep-domain is set,
+// so "Laptop.local" and "laptop" push to the same entry.
+func (pf *pushFlags) host() (string, []string, error) {
+	hostname := strings.TrimSpace(*pf.name)
+	if hostname != "" {
+		if err := validate.Hostname(hostname); err != nil {
+			return "", nil, err
+		}
+	} else {
+		system, err := systemHostname()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to get system's hostname: %w", err)
+		}
+		hostname, err = validate.NormalizeHostname(system, !*pf.keepDomain)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to normalize system's hostname %q, set a name with -name: %w", system, err)
+		}
+	}
+	aliases, err := parseAliases(*pf.aliases, hostname)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, alias := range aliases {
+		if err := validate.Hostname(alias); err != nil {
+			return "", nil, fmt.Errorf("invalid alias: %w", err)
+		}
+	}
+	return hostname, aliases, nil
+}
+
+// record returns the signed record of hostname detected at ip at the given time, carrying
+// the beacon, piphos version, OS, TTL and aliases as well as this machine's identifier.
+// The address of the other family is kept from previous, the record stored so far, and so
+// are its aliases unless -alias is given.
+func (pf *pushFlags) record(hostname, ip string, aliases []string, now time.Time, previous tender.Record) (tender.Record, error) {
+	record := tender.NewRecord(ip)
+	record.KeepOtherFamily(previous)
+	record.UpdatedAt = now
+	record.SeenAt = now
+	record.Beacon = *pf.beacon
+	record.Version = config.Version
+	record.OS = runtime.GOOS + "/" + runtime.GOARCH
+	record.TTL = tender.Duration(*pf.ttl)
+	record.Aliases = aliases
+	if !pf.aliasesSet() {
+		record.Aliases = previous.Aliases
+	}
+	if machineID, err := machine.ID(); err == nil {
+		record.MachineID = machineID
+	}
+	if err := sign(hostname, &record); err != nil {
+		return tender.Record{}, err
+	}
+	return record, nil
+}
+
+// hookFlags holds the flags configuring the hooks run and the notifications sent when a
+// host's IP changes.
+type hookFlags struct {
+	hooks          []string
+	timeout        *time.Duration
+	notifiers      []string
+	notifyInterval *time.Duration
+}
+
+// addHookFlags registers the repeatable -hook and -notify flags, -hook-timeout and
+// -notify-interval on fs. The notifiers default to the whitespace-separated URLs in the
+// PIPHOS_NOTIFY environment variable, see parseFlags.
+func addHookFlags(fs *flag.FlagSet) *hookFlags {
+	hf := &hookFlags{}
+	fs.Func("hook", "command or webhook URL to run when a host's IP changes, may be repeated", func(value string) error {
+		hf.hooks = append(hf.hooks, value)
+		return nil
+	})
+	hf.timeout = durationVar(fs, "hook-timeout", "how long each hook may run, e.g. 30s (default: 10s)")
+	fs.Func("notify", "notifier URL to message when a host's IP changes, may be repeated: "+notify.Usage, func(value string) error {
+		hf.notifiers = append(hf.notifiers, value)
+		return nil
+	})
+	hf.notifyInterval = durationVar(fs, "notify-interval", "minimum time between notifications about a host, e.g. 1h (default: 10m)")
+	return hf
+}
+
+// reactions returns the hooks and notifiers configured for namespace, logging to log,
+// or nil if there are none.
+func (hf *hookFlags) reactions(namespace string, log io.Writer) (*reactions, error) {
+	if len(hf.hooks) == 0 && len(hf.notifiers) == 0 {
+		return nil, nil
+	}
+	r := &reactions{}
+	if len(hf.hooks) > 0 {
+		r.hooks = &hook.Runner{Hooks: hf.hooks, Timeout: *hf.timeout, Log: log}
+	}
+	if len(hf.notifiers) > 0 {
+		r.notifier = &notify.Dispatcher{Interval: *hf.notifyInterval, Log: log}
+		for _, u := range hf.notifiers {
+			n, err := notify.New(u)
+			if err != nil {
+				return nil, err
+			}
+			r.notifier.Notifiers = append(r.notifier.Notifiers, n)
+		}
+		// Without a state directory, the limit only holds within this process
+		if dir, err := hook.StateDir(); err == nil {
+			r.notifier.StatePath = filepath.Join(dir, "notified-"+namespaceName(namespace)+".json")
+		}
+	}
+	return r, nil
+}
+
+// outputVar registers the -o flag selecting the output format on fs.
+func outputVar(fs *flag.FlagSet) *output.Format {
+	var f output.Format
+	fs.Var(&f, "o", output.Usage)
+	return &f
+}
+
+// durationFlag is a flag.Value accepting time.ParseDuration syntax plus a leading
+// number of days, e.g. "30d" or "1d12h".
+type durationFlag time.Duration
+
+// durationVar registers a duration flag with day support on fs.
+func durationVar(fs *flag.FlagSet, name string, usage string) *time.Duration {
+	var d time.Duration
+	fs.Var((*durationFlag)(&d), name, usage)
+	return &d
+}
+
+func (d *durationFlag) String() string {
+	return time.Duration(*d).String()
+}
+
+func (d *durationFlag) Set(value string) error {
+	parsed, err := parseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = durationFlag(parsed)
+	return nil
+}
+
+// parseDuration parses a duration such as "90m", "30d" or "1d12h".
+func parseDuration(value string) (time.Duration, error) {
+	days, rest, ok := strings.Cut(value, "d")
+	if !ok {
+		return time.ParseDuration(value)
+	}
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration: %q", value)
+	}
+	d := time.Duration(n) * 24 * time.Hour
+	if rest == "" {
+		return d, nil
+	}
+	extra, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %q", value)
+	}
+	return d + extra, nil
+}
@@ -0,0 +1,383 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestTenderFlags(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name          string
+		args          []string
+		envNamespace  string
+		expectedError bool
+	}{
+		{
+			name:          "default namespace",
+			args:          []string{},
+			expectedError: false,
+		},
+		{
+			name:          "namespace flag",
+			args:          []string{"-namespace", "homelab"},
+			expectedError: false,
+		},
+		{
+			name:          "invalid namespace flag",
+			args:          []string{"-namespace", "Home Lab"},
+			expectedError: true,
+		},
+		{
+			name:          "invalid namespace from environment",
+			args:          []string{},
+			envNamespace:  "Home Lab",
+			expectedError: true,
+		},
+		{
+			name:          "flag overrides environment",
+			args:          []string{"-namespace", "homelab"},
+			envNamespace:  "Home Lab",
+			expectedError: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PIPHOS_NAMESPACE", tt.envNamespace)
+			withoutConfig(t)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			tf := addTenderFlags(fs)
+			if err := parseFlags(fs, tt.args); err != nil {
+				t.Fatalf("failed to parse flags: %v", err)
+			}
+			_, err := tf.newTender()
+			if tt.expectedError && err == nil {
+				t.Error("expected error but got nil")
+			}
+			if !tt.expectedError && err != nil {
+				t.Errorf("expected no error but got: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value            string
+		expectedDuration time.Duration
+		expectedError    bool
+	}{
+		{value: "90m", expectedDuration: 90 * time.Minute},
+		{value: "30d", expectedDuration: 30 * 24 * time.Hour},
+		{value: "1d12h", expectedDuration: 36 * time.Hour},
+		{value: "0d", expectedDuration: 0},
+		{value: "d", expectedError: true},
+		{value: "-1d", expectedError: true},
+		{value: "1dx", expectedError: true},
+		{value: "forever", expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			d, err := parseDuration(tt.value)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if d != tt.expectedDuration {
+				t.Errorf("expected %v but got %v", tt.expectedDuration, d)
+			}
+		})
+	}
+}
+
+func TestHookFlags(t *testing.T) {
+	tests := []struct {
+		name              string
+		args              []string
+		envNotify         string
+		expectedHooks     bool
+		expectedNotifiers int
+		expectedError     bool
+	}{
+		{name: "nothing configured"},
+		{name: "hook", args: []string{"-hook", "true"}, expectedHooks: true},
+		{name: "notifiers", args: []string{"-notify", "ntfy://ntfy.sh/a", "-notify", "slack://hooks.slack.com/services/x"}, expectedNotifiers: 2},
+		{name: "notifiers from environment", envNotify: "ntfy://ntfy.sh/a  discord://discord.com/api/webhooks/1/x", expectedNotifiers: 2},
+		{name: "flag overrides environment", args: []string{"-notify", "ntfy://ntfy.sh/a"}, envNotify: "invalid://", expectedNotifiers: 1},
+		{name: "invalid notifier", args: []string{"-notify", "pager://example.com"}, expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PIPHOS_NOTIFY", tt.envNotify)
+			t.Setenv("PIPHOS_STATE_DIR", t.TempDir())
+			withoutConfig(t)
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			hf := addHookFlags(fs)
+			if err := parseFlags(fs, tt.args); err != nil {
+				t.Fatalf("failed to parse flags: %v", err)
+			}
+			r, err := hf.reactions("", nil)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if !tt.expectedHooks && tt.expectedNotifiers == 0 {
+				if r != nil {
+					t.Errorf("expected no reactions but got %+v", r)
+				}
+				return
+			}
+			if (r.hooks != nil) != tt.expectedHooks {
+				t.Errorf("expected hooks %v but got %+v", tt.expectedHooks, r.hooks)
+			}
+			notifiers := 0
+			if r.notifier != nil {
+				notifiers = len(r.notifier.Notifiers)
+				if filepath.Base(r.notifier.StatePath) != "notified-default.json" {
+					t.Errorf("unexpected notification state path: %s", r.notifier.StatePath)
+				}
+			}
+			if notifiers != tt.expectedNotifiers {
+				t.Errorf("expected %d notifiers but got %d", tt.expectedNotifiers, notifiers)
+			}
+		})
+	}
+}
+
+func TestPushFlagsHost(t *testing.T) {
+	tests := []struct {
+		name             string
+		args             []string
+		system           string
+		expectedHostname string
+		expectedAliases  []string
+		expectedError    string
+	}{
+		{name: "system hostname", system: "laptop", expectedHostname: "laptop"},
+		{name: "system hostname normalized", system: "Laptop.local", expectedHostname: "laptop"},
+		{name: "keep domain", args: []string{"-keep-domain"}, system: "Laptop.local", expectedHostname: "laptop.local"},
+		{name: "system hostname cannot be normalized", system: "-laptop", expectedError: "set a name with -name"},
+		{name: "name", args: []string{"-name", "server"}, system: "laptop", expectedHostname: "server"},
+		{name: "invalid name", args: []string{"-name", "Server.local"}, expectedError: `use "server.local" instead`},
+		{name: "aliases", args: []string{"-alias", "nas,backup"}, system: "server", expectedHostname: "server", expectedAliases: []string{"nas", "backup"}},
+		{name: "invalid alias", args: []string{"-alias", "my_nas"}, system: "server", expectedError: `invalid alias: invalid hostname "my_nas"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutConfig(t)
+			t.Setenv("PIPHOS_HOSTNAME", "")
+			original := systemHostname
+			systemHostname = func() (string, error) { return tt.system, nil }
+			defer func() { systemHostname = original }()
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			pf := addPushFlags(fs)
+			if err := parseFlags(fs, tt.args); err != nil {
+				t.Fatalf("failed to parse flags: %v", err)
+			}
+			hostname, aliases, err := pf.host()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("expected error containing %q but got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if hostname != tt.expectedHostname {
+				t.Errorf("expected hostname %q but got %q", tt.expectedHostname, hostname)
+			}
+			if !slices.Equal(aliases, tt.expectedAliases) {
+				t.Errorf("expected aliases %v but got %v", tt.expectedAliases, aliases)
+			}
+		})
+	}
+}
+
+func TestPushFlagsRecord(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		ip              string
+		previous        tender.Record
+		expectedIPv4    string
+		expectedIPv6    string
+		expectedAliases []string
+	}{
+		{name: "new host", ip: "203.0.113.1", expectedIPv4: "203.0.113.1"},
+		{name: "keeps the other family", ip: "203.0.113.1", previous: tender.Record{IPv6: "2001:db8::1"}, expectedIPv4: "203.0.113.1", expectedIPv6: "2001:db8::1"},
+		{name: "replaces the same family", ip: "2001:db8::2", previous: tender.Record{IPv4: "203.0.113.1", IPv6: "2001:db8::1"}, expectedIPv4: "203.0.113.1", expectedIPv6: "2001:db8::2"},
+		{name: "keeps the aliases", ip: "203.0.113.1", previous: tender.Record{IPv4: "203.0.113.1", Aliases: []string{"nas"}}, expectedIPv4: "203.0.113.1", expectedAliases: []string{"nas"}},
+		{name: "replaces the aliases", args: []string{"-alias", "dns"}, ip: "203.0.113.1", previous: tender.Record{IPv4: "203.0.113.1", Aliases: []string{"nas"}}, expectedIPv4: "203.0.113.1", expectedAliases: []string{"dns"}},
+		{name: "removes the aliases", args: []string{"-alias", ""}, ip: "203.0.113.1", previous: tender.Record{IPv4: "203.0.113.1", Aliases: []string{"nas"}}, expectedIPv4: "203.0.113.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutConfig(t)
+			t.Setenv("PIPHOS_KEY_FILE", "")
+			t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv("PIPHOS_HOSTNAME", "server")
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			pf := addPushFlags(fs)
+			if err := parseFlags(fs, tt.args); err != nil {
+				t.Fatalf("failed to parse flags: %v", err)
+			}
+			_, aliases, err := pf.host()
+			if err != nil {
+				t.Fatalf("failed to get host: %v", err)
+			}
+			record, err := pf.record("server", tt.ip, aliases, time.Now().UTC(), tt.previous)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if record.IPv4 != tt.expectedIPv4 || record.IPv6 != tt.expectedIPv6 {
+				t.Errorf("expected addresses %q and %q but got %q and %q", tt.expectedIPv4, tt.expectedIPv6, record.IPv4, record.IPv6)
+			}
+			if !slices.Equal(record.Aliases, tt.expectedAliases) {
+				t.Errorf("expected aliases %v but got %v", tt.expectedAliases, record.Aliases)
+			}
+			if record.Signature == "" {
+				t.Error("expected the record to be signed")
+			}
+		})
+	}
+}
+
+func TestCheckRecords(t *testing.T) {
+	records := map[string]tender.Record{
+		"server":                 {IPv4: "203.0.113.1", IPv6: "2001:db8::1", Aliases: []string{"nas", "bad\nalias"}},
+		"laptop\nProxyCommand x": {IPv4: "203.0.113.2"},
+		"Desktop":                {IPv4: "203.0.113.3"},
+		"printer":                {IPv4: "203.0.113.4\nProxyCommand x", IPv6: "2001:db8::4"},
+		"scanner":                {IPv4: "2001:db8::5"},
+		"router":                 {IPv4: "10.0.0.1 github.com", IPv6: "fe80::1%eth0"},
+	}
+	var warnings strings.Builder
+	checked := checkRecords(records, records, &warnings)
+	expected := map[string]tender.Record{
+		"server":  {IPv4: "203.0.113.1", IPv6: "2001:db8::1", Aliases: []string{"nas"}},
+		"printer": {IPv6: "2001:db8::4"},
+	}
+	if len(checked) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, checked)
+	}
+	for hostname, r := range expected {
+		if !sameRecord(checked[hostname], r) {
+			t.Errorf("expected %+v for %s but got %+v", r, hostname, checked[hostname])
+		}
+	}
+	if n := strings.Count(warnings.String(), "warning: "); n != 7 {
+		t.Errorf("expected 7 warnings but got %d:\n%s", n, warnings.String())
+	}
+	if records["server"].Aliases[1] != "bad\nalias" {
+		t.Error("expected the pulled records to be left unchanged")
+	}
+}
+
+func TestCheckRecords_AliasTakeover(t *testing.T) {
+	laptop := tender.Record{IPv4: "198.51.100.66", Aliases: []string{"server"}}
+	server := tender.Record{IPv4: "203.0.113.10"}
+	tests := []struct {
+		name             string
+		records          map[string]tender.Record
+		pulled           map[string]tender.Record
+		expectedAliases  map[string][]string
+		expectedWarnings int
+	}{
+		{
+			name:             "alias naming another host",
+			records:          map[string]tender.Record{"laptop": laptop, "server": server},
+			expectedAliases:  map[string][]string{"laptop": nil, "server": nil},
+			expectedWarnings: 1,
+		},
+		{
+			name:             "alias naming a host dropped as untrusted",
+			records:          map[string]tender.Record{"laptop": laptop},
+			pulled:           map[string]tender.Record{"laptop": laptop, "server": server},
+			expectedAliases:  map[string][]string{"laptop": nil},
+			expectedWarnings: 1,
+		},
+		{
+			name: "alias claimed by several hosts",
+			records: map[string]tender.Record{
+				"laptop":  {IPv4: "198.51.100.66", Aliases: []string{"nas", "dns"}},
+				"desktop": {IPv4: "203.0.113.10", Aliases: []string{"nas"}},
+			},
+			expectedAliases:  map[string][]string{"laptop": {"dns"}, "desktop": nil},
+			expectedWarnings: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pulled := tt.pulled
+			if pulled == nil {
+				pulled = tt.records
+			}
+			var warnings strings.Builder
+			checked := checkRecords(tt.records, pulled, &warnings)
+			for hostname, expected := range tt.expectedAliases {
+				r, ok := checked[hostname]
+				if !ok {
+					t.Fatalf("expected host %s to be kept", hostname)
+				}
+				if !slices.Equal(r.Aliases, expected) {
+					t.Errorf("expected aliases %v for %s but got %v", expected, hostname, r.Aliases)
+				}
+			}
+			if n := strings.Count(warnings.String(), "warning: "); n != tt.expectedWarnings {
+				t.Errorf("expected %d warnings but got %d:\n%s", tt.expectedWarnings, n, warnings.String())
+			}
+		})
+	}
+}
+
+func TestPullRecords(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	tests := []struct {
+		name            string
+		err             error
+		expectedError   bool
+		expectedWarning bool
+	}{
+		{name: "success"},
+		{name: "partial failure", err: &tender.FanOutError{Failed: map[string]error{"s3": unavailable}}, expectedWarning: true},
+		{name: "complete failure", err: &tender.FanOutError{Failed: map[string]error{"gh": unavailable, "s3": unavailable}, All: true}, expectedError: true},
+		{name: "single tender failure", err: unavailable, expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ft := &fakeTender{pulls: []map[string]tender.Record{{"server": tender.NewRecord("203.0.113.1")}}, err: tt.err}
+			var w bytes.Buffer
+			records, err := pullRecords(context.Background(), ft, &w)
+			if (err != nil) != tt.expectedError {
+				t.Errorf("expected error %v but got: %v", tt.expectedError, err)
+			}
+			if !tt.expectedError && records["server"].IPv4 != "203.0.113.1" {
+				t.Errorf("unexpected records: %+v", records)
+			}
+			if strings.Contains(w.String(), "warning: failed on s3") != tt.expectedWarning {
+				t.Errorf("expected warning %v but got %q", tt.expectedWarning, w.String())
+			}
+		})
+	}
+}
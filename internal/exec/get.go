@@ -0,0 +1,104 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// getPollInterval is how often get -wait pulls while waiting for a host. It is a variable so tests can shorten it.
+var getPollInterval = 10 * time.Second
+
+// Get looks up a single host, or one of its aliases, and writes just its IP address to w.
+// The -family flag selects the address family, 4 or 6 (default: IPv4 if known, otherwise IPv6).
+// The -wait flag keeps polling for up to the given duration until the host appears,
+// retrying failed pulls.
+// Only usable records are considered, see usableRecords; -allow-untrusted also considers
+// records not signed by a trusted key.
+// Returns an error wrapping tender.ErrHostNotFound if the host or an address of the family is unknown.
+func Get(ctx context.Context, args []string, w io.Writer) (string, error) {
+	fs := flag.NewFlagSet("get", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	family := fs.String("family", "", "address family to print: 4 or 6 (default: IPv4 if known)")
+	wait := durationVar(fs, "wait", "poll for up to this duration until the host appears, e.g. 5m (default: don't wait)")
+	allowUntrusted := addTrustFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return "", err
+	}
+	if err := validate.CommandArgs(fs.NArg(), 1, 1); err != nil {
+		return "", err
+	}
+	if *family != "" && *family != "4" && *family != "6" {
+		return "", fmt.Errorf("unknown address family: %s", *family)
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return "", err
+	}
+	if *wait > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *wait)
+		defer cancel()
+	}
+	ip, err := lookup(ctx, t, fs.Arg(0), *family, *wait > 0, *allowUntrusted)
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(w, ip)
+	return ip, nil
+}
+
+// lookup returns the address of host in the given family, only considering usable records,
+// see usableRecords. If wait is set, it pulls every getPollInterval until the address is
+// known or ctx is done, retrying failed pulls, and returns the last error.
+func lookup(ctx context.Context, t tender.Tender, host, family string, wait, allowUntrusted bool) (string, error) {
+	for {
+		records, err := pullRecords(ctx, t, os.Stderr)
+		if err != nil && !wait {
+			return "", err
+		}
+		if err == nil {
+			records, err = usableRecords(records, allowUntrusted, os.Stderr)
+			if err != nil {
+				return "", err
+			}
+			var ip string
+			ip, err = address(records, host, family)
+			if err == nil || !wait {
+				return ip, err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("gave up waiting: %w", err)
+		case <-time.After(getPollInterval):
+		}
+	}
+}
+
+// address returns the address of host, or of the host it is an alias of, in the given family.
+func address(records map[string]tender.Record, host, family string) (string, error) {
+	_, r, ok := tender.Resolve(records, host)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", tender.ErrHostNotFound, host)
+	}
+	var ip string
+	switch family {
+	case "4":
+		ip = r.IPv4
+	case "6":
+		ip = r.IPv6
+	default:
+		ip = r.IP()
+	}
+	if ip == "" {
+		return "", fmt.Errorf("%w: %s has no IPv%s address", tender.ErrHostNotFound, host, family)
+	}
+	return ip, nil
+}
@@ -0,0 +1,131 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+// fakeTender serves a sequence of pulls, repeating the last one. Pulls fail with err, or
+// with errPull for the first failures ones.
+type fakeTender struct {
+	pulls    []map[string]tender.Record
+	calls    int
+	err      error
+	failures int
+}
+
+var errPull = errors.New("pull failed")
+
+func (f *fakeTender) Pull(context.Context) (map[string]tender.Record, error) {
+	records := f.pulls[min(f.calls, len(f.pulls)-1)]
+	f.calls++
+	if f.calls <= f.failures {
+		return nil, errPull
+	}
+	return records, f.err
+}
+
+func (f *fakeTender) Push(context.Context, string, tender.Record) error { return nil }
+
+func (f *fakeTender) Delete(context.Context, string) error { return nil }
+
+func (f *fakeTender) Rename(context.Context, string, string) error { return nil }
+
+func (f *fakeTender) Replace(context.Context, map[string]tender.Record) error { return nil }
+
+func TestGet(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "missing host", args: []string{}},
+		{name: "too many arguments", args: []string{"server", "laptop"}},
+		{name: "unknown family", args: []string{"-family", "5", "server"}},
+		{name: "unknown tender", args: []string{"-tender", "unknown", "server"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Get(context.Background(), tt.args, &bytes.Buffer{}); err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestAddress(t *testing.T) {
+	records := map[string]tender.Record{
+		"server": {IPv4: "203.0.113.1", IPv6: "2001:db8::1", Aliases: []string{"nas"}},
+		"laptop": {IPv6: "2001:db8::2"},
+	}
+	tests := []struct {
+		name          string
+		host          string
+		family        string
+		expectedIP    string
+		expectedError bool
+	}{
+		{name: "preferred address", host: "server", expectedIP: "203.0.113.1"},
+		{name: "IPv6", host: "server", family: "6", expectedIP: "2001:db8::1"},
+		{name: "alias", host: "nas", family: "4", expectedIP: "203.0.113.1"},
+		{name: "IPv6 only host", host: "laptop", expectedIP: "2001:db8::2"},
+		{name: "missing family", host: "laptop", family: "4", expectedError: true},
+		{name: "unknown host", host: "desktop", expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, err := address(records, tt.host, tt.family)
+			if tt.expectedError {
+				if !errors.Is(err, tender.ErrHostNotFound) {
+					t.Errorf("expected host not found error but got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if ip != tt.expectedIP {
+				t.Errorf("expected %s but got %s", tt.expectedIP, ip)
+			}
+		})
+	}
+}
+
+func TestLookup_Wait(t *testing.T) {
+	interval := getPollInterval
+	getPollInterval = time.Millisecond
+	defer func() { getPollInterval = interval }()
+	appearing := &fakeTender{pulls: []map[string]tender.Record{{}, {}, {"server": tender.NewRecord("203.0.113.1")}}}
+	ip, err := lookup(context.Background(), appearing, "server", "", true, false)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if ip != "203.0.113.1" || appearing.calls != 3 {
+		t.Errorf("expected 203.0.113.1 after 3 pulls but got %q after %d", ip, appearing.calls)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	never := &fakeTender{pulls: []map[string]tender.Record{{}}}
+	if _, err := lookup(ctx, never, "server", "", true, false); !errors.Is(err, tender.ErrHostNotFound) {
+		t.Errorf("expected host not found error after waiting but got: %v", err)
+	}
+	recovering := &fakeTender{pulls: []map[string]tender.Record{{"server": tender.NewRecord("203.0.113.1")}}, failures: 2}
+	if ip, err := lookup(context.Background(), recovering, "server", "", true, false); err != nil || ip != "203.0.113.1" {
+		t.Errorf("expected 203.0.113.1 once pulls succeed but got %q and %v", ip, err)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	failing := &fakeTender{pulls: []map[string]tender.Record{{}}, failures: 1000}
+	if _, err := lookup(ctx, failing, "server", "", true, false); !errors.Is(err, errPull) || failing.calls < 2 {
+		t.Errorf("expected the pull error after %d retries but got: %v", failing.calls, err)
+	}
+	once := &fakeTender{pulls: []map[string]tender.Record{{}}}
+	if _, err := lookup(context.Background(), once, "server", "", false, false); err == nil || once.calls != 1 {
+		t.Errorf("expected a single failed pull without waiting but got %v after %d pulls", err, once.calls)
+	}
+}
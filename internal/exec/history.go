@@ -0,0 +1,106 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// Change is a single change of a host's IPv4 or IPv6 address, reconstructed from the
+// tender's history. OldIP is empty when the host first gained an address of the family
+// and NewIP is empty when it lost it, e.g. because it was removed.
+type Change struct {
+	Time    time.Time `json:"time"`
+	Host    string    `json:"host"`
+	OldIP   string    `json:"old_ip"`
+	NewIP   string    `json:"new_ip"`
+	Version string    `json:"version"`
+}
+
+// History reconstructs when hosts' IP addresses changed from the revisions kept by the tender
+// and writes them to w, oldest first. An optional host argument limits the output to one host.
+// The -since flag limits the output to recent changes (e.g. -since=1h) and -o selects
+// the output format, see output.Format (default: table).
+func History(ctx context.Context, args []string, w io.Writer) ([]Change, error) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	since := durationVar(fs, "since", "only show changes newer than this duration, e.g. 1h (default: all)")
+	format := outputVar(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if err := validate.CommandArgs(fs.NArg(), 0, 1); err != nil {
+		return nil, err
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return nil, err
+	}
+	historian, ok := t.(tender.Historian)
+	if !ok {
+		return nil, fmt.Errorf("tender %s does not keep history", *tf.name)
+	}
+	var cutoff time.Time
+	if *since > 0 {
+		cutoff = time.Now().Add(-*since)
+	}
+	revisions, err := historian.History(ctx, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	result := changes(revisions, cutoff, fs.Arg(0))
+	return result, output.Write(w, *format, changesResult(result))
+}
+
+// changes compares consecutive revisions and returns a change per address family in which
+// a host's address differs, IPv4 first, skipping changes committed before cutoff. If host is not empty, only its changes are returned.
+func changes(revisions []tender.Revision, cutoff time.Time, host string) []Change {
+	result := []Change{}
+	var previous map[string]tender.Record
+	for i, r := range revisions {
+		// The first revision only serves as the baseline when it predates the window.
+		if i == 0 && r.CommittedAt.Before(cutoff) {
+			previous = r.Hosts
+			continue
+		}
+		var hosts []string
+		for h := range r.Hosts {
+			hosts = append(hosts, h)
+		}
+		for h := range previous {
+			if _, ok := r.Hosts[h]; !ok {
+				hosts = append(hosts, h)
+			}
+		}
+		slices.Sort(hosts)
+		for _, h := range hosts {
+			if host != "" && h != host {
+				continue
+			}
+			before, after := previous[h], r.Hosts[h]
+			for _, family := range [][2]string{{before.IPv4, after.IPv4}, {before.IPv6, after.IPv6}} {
+				if family[0] != family[1] {
+					result = append(result, Change{Time: r.CommittedAt, Host: h, OldIP: family[0], NewIP: family[1], Version: r.Version})
+				}
+			}
+		}
+		previous = r.Hosts
+	}
+	return result
+}
+
+// changesResult lays out changes as a table with local times.
+func changesResult(result []Change) output.Result {
+	r := output.Result{Value: result, Header: []string{"TIME", "HOST", "OLD", "NEW"}}
+	for _, c := range result {
+		r.Rows = append(r.Rows, []string{c.Time.Local().Format(time.DateTime), c.Host, c.OldIP, c.NewIP})
+	}
+	return r
+}
@@ -0,0 +1,134 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestHistory(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError bool
+	}{
+		{
+			name:          "unknown tender",
+			args:          []string{"-tender", "unknown"},
+			expectedError: true,
+		},
+		{
+			name:          "too many arguments",
+			args:          []string{"host1", "host2"},
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := History(ctx, tt.args, &bytes.Buffer{})
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestChanges(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	revisions := []tender.Revision{
+		{Version: "v1", CommittedAt: now.Add(-48 * time.Hour), Hosts: map[string]tender.Record{"server": tender.NewRecord("203.0.113.1"), "old": tender.NewRecord("192.0.2.1")}},
+		{Version: "v2", CommittedAt: now.Add(-2 * time.Hour), Hosts: map[string]tender.Record{"server": tender.NewRecord("203.0.113.1"), "laptop": tender.NewRecord("198.51.100.7")}},
+		{Version: "v3", CommittedAt: now.Add(-10 * time.Minute), Hosts: map[string]tender.Record{"server": tender.NewRecord("203.0.113.2"), "laptop": tender.NewRecord("198.51.100.7")}},
+	}
+	tests := []struct {
+		name            string
+		cutoff          time.Time
+		host            string
+		expectedChanges []Change
+	}{
+		{
+			name:   "complete history",
+			cutoff: time.Time{},
+			expectedChanges: []Change{
+				{Time: now.Add(-48 * time.Hour), Host: "old", NewIP: "192.0.2.1", Version: "v1"},
+				{Time: now.Add(-48 * time.Hour), Host: "server", NewIP: "203.0.113.1", Version: "v1"},
+				{Time: now.Add(-2 * time.Hour), Host: "laptop", NewIP: "198.51.100.7", Version: "v2"},
+				{Time: now.Add(-2 * time.Hour), Host: "old", OldIP: "192.0.2.1", Version: "v2"},
+				{Time: now.Add(-10 * time.Minute), Host: "server", OldIP: "203.0.113.1", NewIP: "203.0.113.2", Version: "v3"},
+			},
+		},
+		{
+			name:   "baseline before cutoff is not a change",
+			cutoff: now.Add(-3 * time.Hour),
+			expectedChanges: []Change{
+				{Time: now.Add(-2 * time.Hour), Host: "laptop", NewIP: "198.51.100.7", Version: "v2"},
+				{Time: now.Add(-2 * time.Hour), Host: "old", OldIP: "192.0.2.1", Version: "v2"},
+				{Time: now.Add(-10 * time.Minute), Host: "server", OldIP: "203.0.113.1", NewIP: "203.0.113.2", Version: "v3"},
+			},
+		},
+		{
+			name:   "single host",
+			cutoff: now.Add(-3 * time.Hour),
+			host:   "server",
+			expectedChanges: []Change{
+				{Time: now.Add(-10 * time.Minute), Host: "server", OldIP: "203.0.113.1", NewIP: "203.0.113.2", Version: "v3"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := changes(revisions, tt.cutoff, tt.host)
+			if len(result) != len(tt.expectedChanges) {
+				t.Fatalf("expected %d changes but got %d: %v", len(tt.expectedChanges), len(result), result)
+			}
+			for i, expected := range tt.expectedChanges {
+				if result[i] != expected {
+					t.Errorf("expected change %d to be %+v but got %+v", i, expected, result[i])
+				}
+			}
+		})
+	}
+}
+
+func TestChanges_PerFamily(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	revisions := []tender.Revision{
+		{Version: "v1", CommittedAt: now.Add(-3 * time.Hour), Hosts: map[string]tender.Record{"server": {IPv4: "203.0.113.1", IPv6: "2001:db8::1"}}},
+		{Version: "v2", CommittedAt: now.Add(-2 * time.Hour), Hosts: map[string]tender.Record{"server": {IPv4: "203.0.113.1", IPv6: "2001:db8::2"}}},
+		{Version: "v3", CommittedAt: now.Add(-time.Hour), Hosts: map[string]tender.Record{"server": {IPv4: "203.0.113.2"}}},
+	}
+	expectedChanges := []Change{
+		{Time: now.Add(-2 * time.Hour), Host: "server", OldIP: "2001:db8::1", NewIP: "2001:db8::2", Version: "v2"},
+		{Time: now.Add(-time.Hour), Host: "server", OldIP: "203.0.113.1", NewIP: "203.0.113.2", Version: "v3"},
+		{Time: now.Add(-time.Hour), Host: "server", OldIP: "2001:db8::2", Version: "v3"},
+	}
+	result := changes(revisions, now.Add(-150*time.Minute), "")
+	if !slices.Equal(result, expectedChanges) {
+		t.Errorf("expected changes %+v but got %+v", expectedChanges, result)
+	}
+}
+
+func TestChangesResult(t *testing.T) {
+	var buf bytes.Buffer
+	result := []Change{{Time: time.Now(), Host: "server", NewIP: "203.0.113.1"}}
+	if err := output.Write(&buf, output.Format{}, changesResult(result)); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one row but got: %q", buf.String())
+	}
+	if !strings.HasPrefix(lines[0], "TIME") || !strings.Contains(lines[1], "server") || !strings.Contains(lines[1], " - ") {
+		t.Errorf("unexpected table output: %q", buf.String())
+	}
+}
@@ -0,0 +1,86 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kappapee/piphos/internal/config"
+	"github.com/kappapee/piphos/internal/hook"
+	"github.com/kappapee/piphos/internal/notify"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+// reactions runs the hooks and sends the notifications for IP changes.
+type reactions struct {
+	hooks    *hook.Runner
+	notifier *notify.Dispatcher
+}
+
+// Run reacts to events, logging rather than returning failures.
+func (r *reactions) Run(ctx context.Context, events []hook.Event) {
+	if r.hooks != nil {
+		r.hooks.Run(ctx, events)
+	}
+	if r.notifier != nil {
+		r.notifier.Run(ctx, events)
+	}
+}
+
+// observe reacts to every host whose IPv4 or IPv6 address changed since the last
+// observation in namespace and remembers the new addresses. The first observation only remembers them.
+// Failures are reported to w and never fail the calling command.
+func observe(ctx context.Context, r *reactions, records map[string]tender.Record, namespace, source string, w io.Writer) {
+	namespace = namespaceName(namespace)
+	path, err := hook.StatePath(namespace)
+	if err != nil {
+		fmt.Fprintf(w, "warning: skipping hooks: %v\n", err)
+		return
+	}
+	previous, err := hook.LoadState(path)
+	if err != nil {
+		fmt.Fprintf(w, "warning: skipping hooks: %v\n", err)
+		return
+	}
+	events, state := hook.Observe(previous, records, namespace, source, time.Now().UTC())
+	r.Run(ctx, events)
+	if err := hook.SaveState(path, state); err != nil {
+		fmt.Fprintf(w, "warning: failed to remember observed addresses: %v\n", err)
+	}
+}
+
+// pushed reacts if a push changed the IPv4 or IPv6 address of hostname from those of
+// previous, and updates the addresses remembered for it if they are observed in namespace,
+// so pull does not report them again. Unchanged addresses only send the notifications
+// held back, see notify.Dispatcher.
+func pushed(ctx context.Context, r *reactions, hostname string, previous, record tender.Record, namespace string, w io.Writer) {
+	if previous.SameAddress(record) {
+		// Still sends the notifications held back by the rate limit once it has passed
+		r.Run(ctx, nil)
+		return
+	}
+	namespace = namespaceName(namespace)
+	addresses := hook.AddressesOf(record)
+	r.Run(ctx, []hook.Event{hook.NewEvent(hostname, hook.AddressesOf(previous), addresses, namespace, "push", time.Now().UTC())})
+	path, err := hook.StatePath(namespace)
+	if err != nil {
+		return
+	}
+	state, err := hook.LoadState(path)
+	if err != nil || state == nil {
+		return
+	}
+	state[hostname] = addresses
+	if err := hook.SaveState(path, state); err != nil {
+		fmt.Fprintf(w, "warning: failed to remember pushed addresses: %v\n", err)
+	}
+}
+
+// namespaceName returns the name of the namespace selected by namespace, which is empty for the default one.
+func namespaceName(namespace string) string {
+	if namespace == "" {
+		return config.DefaultNamespace
+	}
+	return namespace
+}
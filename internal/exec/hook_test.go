@@ -0,0 +1,60 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/kappapee/piphos/internal/hook"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestObserve(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands are run with /bin/sh in this test")
+	}
+	t.Setenv("PIPHOS_STATE_DIR", t.TempDir())
+	out := filepath.Join(t.TempDir(), "events")
+	runner := &reactions{hooks: &hook.Runner{Hooks: []string{`echo "$PIPHOS_SOURCE $PIPHOS_HOST $PIPHOS_OLD_IP $PIPHOS_NEW_IP ipv6:$PIPHOS_OLD_IPV6,$PIPHOS_NEW_IPV6" >> ` + out}}}
+	steps := []struct {
+		records map[string]tender.Record
+		push    [2]tender.Record
+	}{
+		{records: map[string]tender.Record{"server": tender.NewRecord("203.0.113.1")}},
+		{records: map[string]tender.Record{"server": tender.NewRecord("203.0.113.2")}},
+		{push: [2]tender.Record{tender.NewRecord("203.0.113.2"), tender.NewRecord("203.0.113.3")}},
+		{records: map[string]tender.Record{"server": tender.NewRecord("203.0.113.3")}},
+		{push: [2]tender.Record{tender.NewRecord("203.0.113.3"), tender.NewRecord("203.0.113.3")}},
+		{push: [2]tender.Record{tender.NewRecord("203.0.113.3"), {IPv4: "203.0.113.3", IPv6: "2001:db8::1"}}},
+		{records: map[string]tender.Record{"server": {IPv4: "203.0.113.3", IPv6: "2001:db8::1"}}},
+		{records: map[string]tender.Record{"server": {IPv4: "203.0.113.3", IPv6: "2001:db8::2"}}},
+	}
+	var warnings bytes.Buffer
+	for _, step := range steps {
+		if step.records != nil {
+			observe(context.Background(), runner, step.records, "", "pull", &warnings)
+		} else {
+			pushed(context.Background(), runner, "server", step.push[0], step.push[1], "", &warnings)
+		}
+	}
+	if warnings.Len() > 0 {
+		t.Errorf("expected no warnings but got: %q", warnings.String())
+	}
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("expected hooks to run but got: %v", err)
+	}
+	expected := []string{
+		"pull server 203.0.113.1 203.0.113.2 ipv6:,",
+		"push server 203.0.113.2 203.0.113.3 ipv6:,",
+		"push server 203.0.113.3 203.0.113.3 ipv6:,2001:db8::1",
+		"pull server 203.0.113.3 203.0.113.3 ipv6:2001:db8::1,2001:db8::2",
+	}
+	if lines := strings.Split(strings.TrimSpace(string(content)), "\n"); strings.Join(lines, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected hook runs %q but got %q", expected, lines)
+	}
+}
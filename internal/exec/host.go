@@ -0,0 +1,88 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"os"
+	"time"
+
+	"github.com/kappapee/piphos/internal/config"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// Remove deletes a host's record from the specified tender provider.
+func Remove(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rm", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.CommandArgs(fs.NArg(), 1, 1); err != nil {
+		return err
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return err
+	}
+	return t.Delete(ctx, fs.Arg(0))
+}
+
+// Move renames a host's record in the specified tender provider, keeping its addresses and history.
+func Move(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("mv", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.CommandArgs(fs.NArg(), 2, 2); err != nil {
+		return err
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return err
+	}
+	return t.Rename(ctx, fs.Arg(0), fs.Arg(1))
+}
+
+// Set stores an IP address for a host by hand, e.g. for a machine that cannot run piphos.
+// The host may also be named by one of its aliases. The record is marked as manual, so it
+// is told apart from detected ones and never pruned, keeps the address of the other family,
+// the aliases and the machine identifier stored so far and is signed with this host's key.
+func Set(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("set", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.CommandArgs(fs.NArg(), 2, 2); err != nil {
+		return err
+	}
+	ip := fs.Arg(1)
+	if err := validate.IP(ip); err != nil {
+		return err
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return err
+	}
+	records, err := pullRecords(ctx, t, os.Stderr)
+	if err != nil {
+		return err
+	}
+	hostname, previous, ok := tender.Resolve(records, fs.Arg(0))
+	if !ok {
+		hostname = fs.Arg(0)
+	}
+	record := tender.NewRecord(ip)
+	record.KeepOtherFamily(previous)
+	record.UpdatedAt = time.Now().UTC()
+	record.Version = config.Version
+	record.Manual = true
+	record.Aliases = previous.Aliases
+	record.MachineID = previous.MachineID
+	if err := sign(hostname, &record); err != nil {
+		return err
+	}
+	return t.Push(ctx, hostname, record)
+}
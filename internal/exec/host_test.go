@@ -0,0 +1,182 @@
+package exec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestRemove(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError bool
+	}{
+		{
+			name:          "missing host",
+			args:          []string{},
+			expectedError: true,
+		},
+		{
+			name:          "too many arguments",
+			args:          []string{"laptop", "desktop"},
+			expectedError: true,
+		},
+		{
+			name:          "unknown tender",
+			args:          []string{"-tender", "unknown", "laptop"},
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Remove(context.Background(), tt.args)
+			if tt.expectedError && err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestMove(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError bool
+	}{
+		{
+			name:          "missing new name",
+			args:          []string{"laptpo"},
+			expectedError: true,
+		},
+		{
+			name:          "unknown tender",
+			args:          []string{"-tender", "unknown", "laptpo", "laptop"},
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Move(context.Background(), tt.args)
+			if tt.expectedError && err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError bool
+	}{
+		{
+			name:          "missing IP",
+			args:          []string{"printer"},
+			expectedError: true,
+		},
+		{
+			name:          "invalid IP",
+			args:          []string{"printer", "203.0.113"},
+			expectedError: true,
+		},
+		{
+			name:          "unknown tender",
+			args:          []string{"-tender", "unknown", "printer", "203.0.113.9"},
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Set(context.Background(), tt.args)
+			if tt.expectedError && err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestSet_KeepsAliasesAndMachineID(t *testing.T) {
+	storeHosts(t, map[string]tender.Record{
+		"printer": {IPv4: "203.0.113.1", Aliases: []string{"scanner"}, MachineID: "machine"},
+	})
+	if err := Set(context.Background(), []string{"-tender", "file", "printer", "203.0.113.9"}); err != nil {
+		t.Fatalf("failed to set: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(os.Getenv("PIPHOS_FILE_DIR"), "hosts.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts, err := tender.DecodeHosts(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := hosts["printer"]
+	if record.IPv4 != "203.0.113.9" || !record.Manual {
+		t.Errorf("expected a manual record for 203.0.113.9 but got %+v", record)
+	}
+	if !slices.Equal(record.Aliases, []string{"scanner"}) || record.MachineID != "machine" {
+		t.Errorf("expected the aliases and machine identifier to be kept but got %+v", record)
+	}
+}
+
+func TestSet_ResolvesAliasAndKeepsOtherFamily(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		expectedIPv4 string
+		expectedIPv6 string
+	}{
+		{
+			name:         "IPv4 keeps IPv6",
+			args:         []string{"printer", "203.0.113.9"},
+			expectedIPv4: "203.0.113.9",
+			expectedIPv6: "2001:db8::1",
+		},
+		{
+			name:         "IPv6 keeps IPv4",
+			args:         []string{"printer", "2001:db8::9"},
+			expectedIPv4: "203.0.113.1",
+			expectedIPv6: "2001:db8::9",
+		},
+		{
+			name:         "alias updates its host",
+			args:         []string{"scanner", "203.0.113.9"},
+			expectedIPv4: "203.0.113.9",
+			expectedIPv6: "2001:db8::1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storeHosts(t, map[string]tender.Record{
+				"printer": {IPv4: "203.0.113.1", IPv6: "2001:db8::1", Aliases: []string{"scanner"}},
+			})
+			if err := Set(context.Background(), append([]string{"-tender", "file"}, tt.args...)); err != nil {
+				t.Fatalf("failed to set: %v", err)
+			}
+			content, err := os.ReadFile(filepath.Join(os.Getenv("PIPHOS_FILE_DIR"), "hosts.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			hosts, err := tender.DecodeHosts(content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hosts) != 1 {
+				t.Errorf("expected only printer to be stored but got %+v", hosts)
+			}
+			record := hosts["printer"]
+			if record.IPv4 != tt.expectedIPv4 || record.IPv6 != tt.expectedIPv6 || !record.Manual {
+				t.Errorf("expected a manual record for %s and %s but got %+v", tt.expectedIPv4, tt.expectedIPv6, record)
+			}
+		})
+	}
+}
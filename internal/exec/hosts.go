@@ -0,0 +1,120 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/kappapee/piphos/internal/diff"
+	"github.com/kappapee/piphos/internal/fileutil"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// HostsSync writes every host into the piphos section of the system's hosts file, so the
+// names resolve in any program. Everything outside the section's marker comments is kept
+// and the file is written atomically. The -file flag selects another file, -domain appends
+// a suffix to every name (e.g. -domain=piphos for home-server.piphos), and -family limits
+// the addresses to IPv4 or IPv6 (default: both). With -dry-run, the change is written to w
+// as a unified diff instead. Only usable records are written, see usableRecords and -allow-untrusted.
+func HostsSync(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("hosts sync", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	file := fs.String("file", hostsFile(), "hosts file to update")
+	domain := fs.String("domain", "", "domain appended to every name, e.g. piphos (default: none)")
+	family := fs.String("family", "", "address family to write: 4 or 6 (default: both)")
+	dryRun := fs.Bool("dry-run", false, "print the change as a unified diff without writing the file")
+	allowUntrusted := addTrustFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return err
+	}
+	if *family != "" && *family != "4" && *family != "6" {
+		return fmt.Errorf("unknown address family: %s", *family)
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return err
+	}
+	records, err := pullRecords(ctx, t, os.Stderr)
+	if err != nil {
+		return err
+	}
+	records, err = usableRecords(records, *allowUntrusted, os.Stderr)
+	if err != nil {
+		return err
+	}
+	block := hostsBlock(records, strings.Trim(*domain, "."), *family)
+	begin, end := markers("#", *tf.namespace)
+	if *dryRun {
+		return hostsDiff(w, *file, begin, end, block)
+	}
+	if _, err := fileutil.UpdateBlock(*file, begin, end, []byte(block), 0o644); err != nil {
+		if errors.Is(err, os.ErrPermission) {
+			return fmt.Errorf("%w (hint: run as root or select another file with -file)", err)
+		}
+		return err
+	}
+	return nil
+}
+
+// hostsDiff writes the change updating the piphos section of file would make as a unified diff.
+func hostsDiff(w io.Writer, file, begin, end, block string) error {
+	content, err := os.ReadFile(file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	updated, err := fileutil.ReplaceBlock(content, begin, end, []byte(block))
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", file, err)
+	}
+	_, err = io.WriteString(w, diff.Unified(file, file, content, updated))
+	return err
+}
+
+// hostsBlock returns a hosts file line per address of every host sorted by hostname,
+// naming the host and its aliases. Names that cannot appear in a hosts file are skipped.
+func hostsBlock(records map[string]tender.Record, domain, family string) string {
+	var b strings.Builder
+	for _, hostname := range slices.Sorted(maps.Keys(records)) {
+		r := records[hostname]
+		var names []string
+		for _, name := range append([]string{hostname}, r.Aliases...) {
+			if name == "" || strings.ContainsAny(name, " \t#") {
+				continue
+			}
+			if domain != "" {
+				name += "." + domain
+			}
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			continue
+		}
+		for _, ip := range []string{r.IPv4, r.IPv6} {
+			if ip == "" || family == "4" && ip == r.IPv6 || family == "6" && ip == r.IPv4 {
+				continue
+			}
+			fmt.Fprintf(&b, "%s\t%s\n", ip, strings.Join(names, " "))
+		}
+	}
+	return b.String()
+}
+
+// hostsFile returns the path of the system's hosts file.
+func hostsFile() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("SystemRoot"), "System32", "drivers", "etc", "hosts")
+	}
+	return "/etc/hosts"
+}
@@ -0,0 +1,112 @@
+package exec
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestHostsSync(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "extra arguments", args: []string{"extra"}},
+		{name: "unknown family", args: []string{"-family", "5"}},
+		{name: "unknown tender", args: []string{"-tender", "unknown"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := HostsSync(context.Background(), tt.args, &strings.Builder{}); err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestHostsBlock(t *testing.T) {
+	records := map[string]tender.Record{
+		"server": {IPv4: "203.0.113.1", IPv6: "2001:db8::1", Aliases: []string{"nas", "bad#alias"}},
+		"laptop": {IPv6: "2001:db8::2"},
+	}
+	tests := []struct {
+		name          string
+		domain        string
+		family        string
+		expectedBlock string
+	}{
+		{
+			name:          "all addresses",
+			expectedBlock: "2001:db8::2\tlaptop\n203.0.113.1\tserver nas\n2001:db8::1\tserver nas\n",
+		},
+		{
+			name:          "domain and family",
+			domain:        "piphos",
+			family:        "4",
+			expectedBlock: "203.0.113.1\tserver.piphos nas.piphos\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if block := hostsBlock(records, tt.domain, tt.family); block != tt.expectedBlock {
+				t.Errorf("expected %q but got %q", tt.expectedBlock, block)
+			}
+		})
+	}
+}
+
+func TestHostsDiff(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hosts")
+	var out strings.Builder
+	if err := hostsDiff(&out, file, "# BEGIN", "# END", "203.0.113.1\tserver\n"); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	expected := "--- " + file + "\n+++ " + file + "\n@@ -0,0 +1,3 @@\n+# BEGIN\n+203.0.113.1\tserver\n+# END\n"
+	if out.String() != expected {
+		t.Errorf("expected %q but got %q", expected, out.String())
+	}
+}
+
+func TestHostsSync_Injection(t *testing.T) {
+	storeHosts(t, map[string]tender.Record{
+		"srv":           {IPv4: "10.0.0.1 github.com"},
+		"web\n10.0.0.1": {IPv4: "203.0.113.10"},
+		"nas":           {IPv4: "203.0.113.11", IPv6: "fe80::1%eth0\n10.0.0.1 github.com", Aliases: []string{"backup\n10.0.0.1"}},
+	})
+	file := filepath.Join(t.TempDir(), "hosts")
+	if err := HostsSync(context.Background(), []string{"-tender", "file", "-file", file}, &strings.Builder{}); err != nil {
+		t.Fatalf("failed to sync hosts: %v", err)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# BEGIN piphos default\n203.0.113.11\tnas\n# END piphos default\n"
+	if string(content) != expected {
+		t.Errorf("expected %q but got %q", expected, content)
+	}
+}
+
+func TestHostsSync_AliasTakeover(t *testing.T) {
+	storeHosts(t, map[string]tender.Record{
+		"laptop": {IPv4: "198.51.100.66", Aliases: []string{"server"}},
+		"server": {IPv4: "203.0.113.10"},
+	})
+	file := filepath.Join(t.TempDir(), "hosts")
+	if err := HostsSync(context.Background(), []string{"-tender", "file", "-file", file}, &strings.Builder{}); err != nil {
+		t.Fatalf("failed to sync hosts: %v", err)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# BEGIN piphos default\n198.51.100.66\tlaptop\n203.0.113.10\tserver\n# END piphos default\n"
+	if string(content) != expected {
+		t.Errorf("expected %q but got %q", expected, content)
+	}
+}
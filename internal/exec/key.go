@@ -0,0 +1,106 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kappapee/piphos/internal/crypt"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// KeyGenerate creates the key file enabling end-to-end encryption and encrypts the hosts
+// already stored in the selected namespace. The key file is PIPHOS_KEY_FILE or the default
+// location, see crypt.KeyFilePath. Refuses to overwrite an existing key file.
+func KeyGenerate(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("key generate", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return err
+	}
+	path, err := crypt.KeyFilePath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("key file %s already exists, use piphos key rotate to replace the key", path)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to check key file: %w", err)
+	}
+	key, err := crypt.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := crypt.Save(path, crypt.NewKeyring(key)); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "wrote key %s to %s\n", key.ID(), path)
+	if err := reseal(ctx, tf); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "copy the key file to every host sharing this namespace")
+	return nil
+}
+
+// KeyRotate generates a new key, makes it the key used for encryption and re-encrypts the
+// hosts stored in the selected namespace with it. Older keys stay in the key file, so
+// revisions written before the rotation remain readable.
+func KeyRotate(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("key rotate", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return err
+	}
+	keyring, err := crypt.Load()
+	if err != nil {
+		return err
+	}
+	if keyring == nil {
+		return errors.New("no key file found, run piphos key generate first")
+	}
+	path, err := crypt.KeyFilePath()
+	if err != nil {
+		return err
+	}
+	key, err := crypt.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := crypt.Save(path, crypt.NewKeyring(append([]crypt.Key{key}, keyring.Keys()...)...)); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "rotated %s to key %s\n", path, key.ID())
+	if err := reseal(ctx, tf); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "copy the key file to every host sharing this namespace")
+	return nil
+}
+
+// reseal rewrites the hosts stored in the tender selected by tf with the current key file.
+// Hosts stored unencrypted, before the key file existed, are read and encrypted too.
+func reseal(ctx context.Context, tf *tenderFlags) error {
+	tf.plaintext = true
+	t, err := tf.newTender()
+	if err != nil {
+		return err
+	}
+	resealer, ok := t.(tender.Resealer)
+	if !ok {
+		return fmt.Errorf("tender %s does not support re-encrypting stored hosts", *tf.name)
+	}
+	if err := resealer.Reseal(ctx); err != nil {
+		return fmt.Errorf("failed to re-encrypt stored hosts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kappapee/piphos/internal/crypt"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestKeyGenerate_Existing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("existing"), 0o600); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	t.Setenv("PIPHOS_KEY_FILE", path)
+	err := KeyGenerate(context.Background(), []string{}, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected error for existing key file but got: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || string(content) != "existing" {
+		t.Error("expected existing key file to be left alone")
+	}
+}
+
+func TestKeyRotate_NoKeyFile(t *testing.T) {
+	t.Setenv("PIPHOS_KEY_FILE", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	err := KeyRotate(context.Background(), []string{}, &bytes.Buffer{})
+	if err == nil {
+		t.Error("expected error without key file but got nil")
+	}
+}
+
+func TestKeyRotate_KeepsOldKeys(t *testing.T) {
+	isolateCredentials(t)
+	path := filepath.Join(t.TempDir(), "key")
+	t.Setenv("PIPHOS_KEY_FILE", path)
+	// Generating fails at re-encrypting since there is no token, but the key file is written
+	if err := KeyGenerate(context.Background(), []string{}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected error without token but got nil")
+	}
+	if err := KeyRotate(context.Background(), []string{}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected error without token but got nil")
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read key file: %v", err)
+	}
+	if keys := strings.Count(string(content), "piphos-key-"); keys != 2 {
+		t.Errorf("expected 2 keys after rotation but got %d", keys)
+	}
+}
+
+func TestKeyRotate_EncryptsPlaintext(t *testing.T) {
+	storeHosts(t, map[string]tender.Record{"laptop": {IPv4: "203.0.113.1"}})
+	key, err := crypt.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key")
+	if err := crypt.Save(path, crypt.NewKeyring(key)); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PIPHOS_KEY_FILE", path)
+	ctx := context.Background()
+	if _, err := Pull(ctx, []string{"-tender", "file"}, io.Discard); !errors.Is(err, crypt.ErrNotEncrypted) {
+		t.Fatalf("expected unencrypted hosts to be rejected but got: %v", err)
+	}
+	if err := KeyRotate(ctx, []string{"-tender", "file"}, io.Discard); err != nil {
+		t.Fatalf("failed to rotate: %v", err)
+	}
+	records, err := Pull(ctx, []string{"-tender", "file"}, io.Discard)
+	if err != nil {
+		t.Fatalf("expected the encrypted hosts to be readable but got: %v", err)
+	}
+	if records["laptop"].IPv4 != "203.0.113.1" {
+		t.Errorf("expected laptop to be kept but got %v", records)
+	}
+}
@@ -0,0 +1,198 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// Strategies of migrate -conflict for hosts stored in both tenders.
+const (
+	conflictOverwrite = "overwrite"
+	conflictKeepNewer = "keep-newer"
+	conflictSkip      = "skip"
+)
+
+// MigrateStep is what migrate does with a host of the source tender: "copy" it to the
+// destination, "overwrite" the destination's record, "keep" the destination's newer
+// record, "skip" it as it exists in the destination, or nothing as it is "unchanged".
+type MigrateStep struct {
+	Host   string `json:"host"`
+	Action string `json:"action"`
+}
+
+// Migrate copies all host records of the namespace from the tender given by -from to the
+// one given by -to, e.g. -from=gh -to=s3, and writes what it did with each host to w.
+// The -conflict flag decides what happens to hosts stored in both: overwrite them,
+// keep-newer (the default) to keep whichever record was updated last, or skip them.
+// Conflicts are decided against the records the write replaces, see copyInto.
+// If the source keeps a history and the destination is empty and keeps one too, the
+// source's revisions are replayed into the destination first, unless -history=false.
+// With -dry-run, nothing is written. The -o flag selects the output format, see output.Format.
+func Migrate(ctx context.Context, args []string, w io.Writer) ([]MigrateStep, error) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := &tenderFlags{
+		name:      fs.String("from", "", "tender provider to copy hosts from"),
+		namespace: fs.String("namespace", "", "which namespace of hosts to migrate"),
+		verbose:   fs.Bool("verbose", false, "print diagnostics to stderr"),
+	}
+	to := &tenderFlags{name: fs.String("to", "", "tender provider to copy hosts to"), namespace: from.namespace, verbose: from.verbose}
+	conflict := fs.String("conflict", conflictKeepNewer, "what to do with hosts stored in both tenders: overwrite, keep-newer or skip")
+	history := fs.Bool("history", true, "replay the source's history into an empty destination keeping one")
+	dryRun := fs.Bool("dry-run", false, "show what would be copied without writing anything")
+	format := outputVar(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return nil, err
+	}
+	if *from.name == "" || *to.name == "" {
+		return nil, fmt.Errorf("both -from and -to are required")
+	}
+	if *from.name == *to.name {
+		return nil, fmt.Errorf("-from and -to are the same tender: %s", *from.name)
+	}
+	if !slices.Contains([]string{conflictOverwrite, conflictKeepNewer, conflictSkip}, *conflict) {
+		return nil, fmt.Errorf("unknown conflict strategy %q, use overwrite, keep-newer or skip", *conflict)
+	}
+	source, err := from.newTender()
+	if err != nil {
+		return nil, err
+	}
+	destination, err := to.newTender()
+	if err != nil {
+		return nil, err
+	}
+	// Unlike other commands, a partial pull is an error here, as it would copy too little
+	records, err := source.Pull(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to pull from %s: %w", *from.name, err)
+	}
+	if *history {
+		existing, err := destination.Pull(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to pull from %s: %w", *to.name, err)
+		}
+		if err := replayHistory(ctx, source, destination, len(existing) == 0, *dryRun, os.Stderr); err != nil {
+			return nil, err
+		}
+	}
+	steps, err := copyInto(ctx, destination, records, *conflict, *dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write to %s: %w", *to.name, err)
+	}
+	table := output.Result{Value: steps, Header: []string{"HOST", "ACTION"}}
+	for _, s := range steps {
+		table.Rows = append(table.Rows, []string{s.Host, s.Action})
+	}
+	return steps, output.Write(w, *format, table)
+}
+
+// migration returns the records the destination holds after copying records into
+// existing using the conflict strategy, and the step taken for each host of records.
+func migration(records, existing map[string]tender.Record, conflict string) (map[string]tender.Record, []MigrateStep) {
+	result := maps.Clone(existing)
+	if result == nil {
+		result = map[string]tender.Record{}
+	}
+	steps := []MigrateStep{}
+	for _, hostname := range slices.Sorted(maps.Keys(records)) {
+		record := records[hostname]
+		current, ok := existing[hostname]
+		action := "copy"
+		switch {
+		case !ok:
+		case sameRecord(record, current):
+			action = "unchanged"
+		case conflict == conflictSkip:
+			action = "skip"
+		case conflict == conflictKeepNewer && !record.Newer(current):
+			action = "keep"
+		default:
+			action = "overwrite"
+		}
+		if action == "copy" || action == "overwrite" {
+			result[hostname] = record
+		}
+		steps = append(steps, MigrateStep{Host: hostname, Action: action})
+	}
+	return result, steps
+}
+
+// copyInto stores records in destination using the conflict strategy and returns the step
+// taken for each host. The records are merged within the destination's update, so a host
+// pushed between reading and writing the destination is kept rather than overwritten.
+// With dryRun, nothing is written. If destination combines several tenders, each is
+// merged on its own and the steps are those of the last one.
+func copyInto(ctx context.Context, destination tender.Tender, records map[string]tender.Record, conflict string, dryRun bool) ([]MigrateStep, error) {
+	updater, ok := destination.(tender.Updater)
+	if !ok {
+		return nil, errors.New("the tender cannot update its hosts atomically")
+	}
+	var steps []MigrateStep
+	err := updater.Update(ctx, func(hosts map[string]tender.Record) (map[string]tender.Record, bool, error) {
+		var result map[string]tender.Record
+		result, steps = migration(records, hosts, conflict)
+		changed := slices.ContainsFunc(steps, func(s MigrateStep) bool { return s.Action == "copy" || s.Action == "overwrite" })
+		return result, changed && !dryRun, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// sameRecord reports whether a and b are stored identically.
+// Comparing their encoding treats equal times in different locations as the same.
+func sameRecord(a, b tender.Record) bool {
+	encodedA, errA := json.Marshal(a)
+	encodedB, errB := json.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(encodedA, encodedB)
+}
+
+// replayHistory writes every revision of the source's history to the destination, oldest
+// first, so the destination's history shows the same sequence of changes. Only the order
+// is kept: the revisions are committed at the time of the migration. Nothing is replayed
+// unless both tenders keep a history and the destination is empty, which log explains.
+func replayHistory(ctx context.Context, source, destination tender.Tender, empty, dryRun bool, log io.Writer) error {
+	historian, ok := source.(tender.Historian)
+	if !ok {
+		return nil
+	}
+	if _, ok := destination.(tender.Historian); !ok {
+		fmt.Fprintln(log, "not copying history, the destination does not keep one")
+		return nil
+	}
+	if !empty {
+		fmt.Fprintln(log, "not copying history, the destination already holds hosts")
+		return nil
+	}
+	revisions, err := historian.History(ctx, time.Time{})
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	if dryRun {
+		fmt.Fprintf(log, "would replay %d revisions of history\n", len(revisions))
+		return nil
+	}
+	for i, r := range revisions {
+		if err := destination.Replace(ctx, r.Hosts); err != nil {
+			return fmt.Errorf("failed to replay revision %d of %d: %w", i+1, len(revisions), err)
+		}
+	}
+	fmt.Fprintf(log, "replayed %d revisions of history\n", len(revisions))
+	return nil
+}
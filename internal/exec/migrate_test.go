@@ -0,0 +1,209 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestMigrateArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "missing destination", args: []string{"-from", "gh"}},
+		{name: "same tender", args: []string{"-from", "gh", "-to", "gh"}},
+		{name: "unknown conflict strategy", args: []string{"-from", "gh", "-to", "file", "-conflict", "merge"}},
+		{name: "unexpected argument", args: []string{"-from", "gh", "-to", "file", "laptop"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withoutConfig(t)
+			if _, err := Migrate(context.Background(), tt.args, io.Discard); err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestMigration(t *testing.T) {
+	older := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	records := map[string]tender.Record{
+		"laptop":  {IPv4: "203.0.113.1", UpdatedAt: newer},
+		"server":  {IPv4: "203.0.113.2", UpdatedAt: older},
+		"nas":     {IPv4: "203.0.113.3", UpdatedAt: older},
+		"printer": {IPv4: "203.0.113.4", UpdatedAt: older},
+	}
+	existing := map[string]tender.Record{
+		"laptop":  {IPv4: "198.51.100.1", UpdatedAt: older},
+		"server":  {IPv4: "198.51.100.2", UpdatedAt: newer},
+		"nas":     {IPv4: "203.0.113.3", UpdatedAt: older},
+		"desktop": {IPv4: "198.51.100.5", UpdatedAt: older},
+	}
+	tests := []struct {
+		conflict        string
+		expectedActions []string
+		expectedLaptop  string
+		expectedServer  string
+	}{
+		{
+			conflict:        conflictKeepNewer,
+			expectedActions: []string{"laptop overwrite", "nas unchanged", "printer copy", "server keep"},
+			expectedLaptop:  "203.0.113.1",
+			expectedServer:  "198.51.100.2",
+		},
+		{
+			conflict:        conflictOverwrite,
+			expectedActions: []string{"laptop overwrite", "nas unchanged", "printer copy", "server overwrite"},
+			expectedLaptop:  "203.0.113.1",
+			expectedServer:  "203.0.113.2",
+		},
+		{
+			conflict:        conflictSkip,
+			expectedActions: []string{"laptop skip", "nas unchanged", "printer copy", "server skip"},
+			expectedLaptop:  "198.51.100.1",
+			expectedServer:  "198.51.100.2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conflict, func(t *testing.T) {
+			result, steps := migration(records, existing, tt.conflict)
+			var actions []string
+			for _, s := range steps {
+				actions = append(actions, s.Host+" "+s.Action)
+			}
+			if !slices.Equal(actions, tt.expectedActions) {
+				t.Errorf("expected actions %v but got %v", tt.expectedActions, actions)
+			}
+			if result["laptop"].IPv4 != tt.expectedLaptop || result["server"].IPv4 != tt.expectedServer {
+				t.Errorf("expected laptop %s and server %s but got %+v", tt.expectedLaptop, tt.expectedServer, result)
+			}
+			if _, ok := result["desktop"]; !ok {
+				t.Error("expected hosts only in the destination to be kept")
+			}
+			if _, ok := result["printer"]; !ok {
+				t.Error("expected hosts only in the source to be copied")
+			}
+		})
+	}
+}
+
+// historyTender is a tender keeping every replaced set of records as a revision.
+type historyTender struct {
+	fakeTender
+	revisions []tender.Revision
+}
+
+func (h *historyTender) Replace(_ context.Context, hosts map[string]tender.Record) error {
+	h.revisions = append(h.revisions, tender.Revision{Version: "v", Hosts: hosts})
+	return nil
+}
+
+func (h *historyTender) History(context.Context, time.Time) ([]tender.Revision, error) {
+	return h.revisions, nil
+}
+
+func TestReplayHistory(t *testing.T) {
+	source := &historyTender{revisions: []tender.Revision{
+		{Hosts: map[string]tender.Record{"laptop": tender.NewRecord("203.0.113.1")}},
+		{Hosts: map[string]tender.Record{"laptop": tender.NewRecord("203.0.113.2")}},
+	}}
+	tests := []struct {
+		name              string
+		destination       tender.Tender
+		empty             bool
+		dryRun            bool
+		expectedRevisions int
+		expectedLog       string
+	}{
+		{name: "replayed", destination: &historyTender{}, empty: true, expectedRevisions: 2, expectedLog: "replayed 2 revisions"},
+		{name: "dry run", destination: &historyTender{}, empty: true, dryRun: true, expectedLog: "would replay 2 revisions"},
+		{name: "destination not empty", destination: &historyTender{}, expectedLog: "already holds hosts"},
+		{name: "destination without history", destination: &fakeTender{}, empty: true, expectedLog: "does not keep one"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log bytes.Buffer
+			if err := replayHistory(context.Background(), source, tt.destination, tt.empty, tt.dryRun, &log); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if !strings.Contains(log.String(), tt.expectedLog) {
+				t.Errorf("expected log to contain %q but got %q", tt.expectedLog, log.String())
+			}
+			if h, ok := tt.destination.(*historyTender); ok && len(h.revisions) != tt.expectedRevisions {
+				t.Errorf("expected %d revisions but got %d", tt.expectedRevisions, len(h.revisions))
+			}
+		})
+	}
+}
+
+// racingTender pushes concurrent to its tender the first time Update reads the stored
+// records, as if another host reported in while the update was running.
+type racingTender struct {
+	tender.Tender
+	concurrent map[string]tender.Record
+}
+
+func (r *racingTender) Update(ctx context.Context, change func(hosts map[string]tender.Record) (map[string]tender.Record, bool, error)) error {
+	return r.Tender.(tender.Updater).Update(ctx, func(hosts map[string]tender.Record) (map[string]tender.Record, bool, error) {
+		for hostname, record := range r.concurrent {
+			if err := r.Tender.Push(ctx, hostname, record); err != nil {
+				return nil, false, err
+			}
+		}
+		r.concurrent = nil
+		return change(hosts)
+	})
+}
+
+func TestCopyInto_ConcurrentPush(t *testing.T) {
+	older := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	storeHosts(t, map[string]tender.Record{
+		"nas": {IPv4: "203.0.113.3", UpdatedAt: older},
+	})
+	ctx := context.Background()
+	file, err := tender.New("file", tender.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := map[string]tender.Record{
+		"printer": {IPv4: "203.0.113.4", UpdatedAt: older},
+		"laptop":  {IPv4: "203.0.113.1", UpdatedAt: older},
+	}
+	destination := &racingTender{Tender: file, concurrent: map[string]tender.Record{
+		"desktop": {IPv4: "198.51.100.5", UpdatedAt: older},
+		"laptop":  {IPv4: "198.51.100.1", UpdatedAt: older.Add(time.Hour)},
+	}}
+	steps, err := copyInto(ctx, destination, records, conflictKeepNewer, false)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	var actions []string
+	for _, s := range steps {
+		actions = append(actions, s.Host+" "+s.Action)
+	}
+	expectedActions := []string{"laptop keep", "printer copy"}
+	if !slices.Equal(actions, expectedActions) {
+		t.Errorf("expected actions %v but got %v", expectedActions, actions)
+	}
+	stored, err := file.Pull(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"nas": "203.0.113.3", "desktop": "198.51.100.5", "laptop": "198.51.100.1", "printer": "203.0.113.4"}
+	if len(stored) != len(expected) {
+		t.Errorf("expected hosts %v but got %+v", expected, stored)
+	}
+	for hostname, ip := range expected {
+		if stored[hostname].IPv4 != ip {
+			t.Errorf("expected %s at %s but got %q", hostname, ip, stored[hostname].IPv4)
+		}
+	}
+}
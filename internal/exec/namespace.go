@@ -0,0 +1,48 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// Namespaces lists the namespaces stored in the specified tender provider and writes them to w.
+// The tender provider can be specified with the -tender flag (default: "gh")
+// and -o selects the output format, see output.Format (default: table).
+// Returns an error if the tender cannot list namespaces.
+func Namespaces(ctx context.Context, args []string, w io.Writer) ([]string, error) {
+	fs := flag.NewFlagSet("namespaces", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	format := outputVar(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return nil, err
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return nil, err
+	}
+	namespacer, ok := t.(tender.Namespacer)
+	if !ok {
+		return nil, fmt.Errorf("tender %s does not support listing namespaces", *tf.name)
+	}
+	namespaces, err := namespacer.Namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if namespaces == nil {
+		namespaces = []string{}
+	}
+	result := output.Result{Value: namespaces, Header: []string{"NAMESPACE"}}
+	for _, namespace := range namespaces {
+		result.Rows = append(result.Rows, []string{namespace})
+	}
+	return namespaces, output.Write(w, *format, result)
+}
@@ -0,0 +1,38 @@
+package exec
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestNamespaces(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError bool
+	}{
+		{
+			name:          "unknown tender",
+			args:          []string{"-tender", "unknown"},
+			expectedError: true,
+		},
+		{
+			name:          "extra arguments",
+			args:          []string{"extra"},
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := Namespaces(ctx, tt.args, io.Discard)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+			}
+		})
+	}
+}
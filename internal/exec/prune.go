@@ -0,0 +1,84 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// PruneStep is what prune does with a host: "pruned", "would prune" with -dry-run, or
+// "kept" for a host stored without any timestamp, whose age is unknown.
+type PruneStep struct {
+	Host   string `json:"host"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Prune removes hosts that have not reported in for longer than the -older-than flag
+// (e.g. -older-than=30d) or their own TTL set by push -ttl, and writes them to w.
+// With -dry-run, the hosts are only listed. Hosts stored without any timestamp, such as
+// entries of the legacy format, are listed as kept since their age is unknown, unless
+// -undated removes them too. Manual hosts are always kept.
+// The -o flag selects the output format, see output.Format. Returns the removed hosts.
+func Prune(ctx context.Context, args []string, w io.Writer) ([]string, error) {
+	fs := flag.NewFlagSet("prune", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	olderThan := durationVar(fs, "older-than", "remove hosts not seen for longer than this duration, e.g. 30d (default: only hosts past their TTL)")
+	undated := fs.Bool("undated", false, "also remove hosts stored without any timestamp, e.g. in the legacy format")
+	dryRun := fs.Bool("dry-run", false, "list the hosts that would be removed without removing them")
+	format := outputVar(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return nil, err
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return nil, err
+	}
+	pruner, ok := t.(tender.Pruner)
+	if !ok {
+		return nil, fmt.Errorf("tender %s does not support pruning", *tf.name)
+	}
+	now := time.Now()
+	// Prune may evaluate the hosts again if a push got in between, so collect them as a set
+	kept := map[string]bool{}
+	expired := func(hostname string, r tender.Record) bool {
+		if r.Manual || !r.Undated() {
+			return r.Expired(now, *olderThan)
+		}
+		kept[hostname] = !*undated
+		return *undated
+	}
+	removed, err := pruner.Prune(ctx, expired, *dryRun)
+	if err != nil {
+		return nil, err
+	}
+	action := "pruned"
+	if *dryRun {
+		action = "would prune"
+	}
+	steps := []PruneStep{}
+	for _, hostname := range removed {
+		steps = append(steps, PruneStep{Host: hostname, Action: action})
+	}
+	for _, hostname := range slices.Sorted(maps.Keys(kept)) {
+		if kept[hostname] {
+			steps = append(steps, PruneStep{Host: hostname, Action: "kept", Reason: "no timestamp, use -undated to prune it"})
+		}
+	}
+	table := output.Result{Value: steps, Header: []string{"HOST", "ACTION", "REASON"}}
+	for _, s := range steps {
+		table.Rows = append(table.Rows, []string{s.Host, s.Action, s.Reason})
+	}
+	return removed, output.Write(w, *format, table)
+}
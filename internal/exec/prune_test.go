@@ -0,0 +1,75 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestPrune(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError bool
+	}{
+		{
+			name:          "unknown tender",
+			args:          []string{"-tender", "unknown", "-older-than", "30d"},
+			expectedError: true,
+		},
+		{
+			name:          "extra arguments",
+			args:          []string{"-older-than", "30d", "laptop"},
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, err := Prune(ctx, tt.args, &bytes.Buffer{})
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+			}
+		})
+	}
+}
+
+func TestPrune_Undated(t *testing.T) {
+	now := time.Now().UTC()
+	storeHosts(t, map[string]tender.Record{
+		"old-laptop": {IPv4: "203.0.113.1"},
+		"printer":    {IPv4: "203.0.113.2", Manual: true},
+		"server":     {IPv4: "203.0.113.3", UpdatedAt: now, SeenAt: now},
+	})
+	ctx := context.Background()
+	var out bytes.Buffer
+	removed, err := Prune(ctx, []string{"-tender", "file", "-older-than", "30d", "-dry-run"}, &out)
+	if err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if len(removed) != 0 || !strings.Contains(out.String(), "old-laptop  kept    no timestamp") || strings.Contains(out.String(), "printer") {
+		t.Errorf("expected the undated host to be reported as kept but got %v:\n%s", removed, out.String())
+	}
+	out.Reset()
+	removed, err = Prune(ctx, []string{"-tender", "file", "-older-than", "30d", "-undated", "-o", "json"}, &out)
+	if err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	var steps []PruneStep
+	if err := json.Unmarshal(out.Bytes(), &steps); err != nil {
+		t.Fatalf("failed to parse output: %v", err)
+	}
+	expected := []PruneStep{{Host: "old-laptop", Action: "pruned"}}
+	if !slices.Equal(removed, []string{"old-laptop"}) || !slices.Equal(steps, expected) {
+		t.Errorf("expected -undated to prune old-laptop only but got %v and %+v", removed, steps)
+	}
+}
@@ -0,0 +1,166 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	osexec "os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/kappapee/piphos/internal/config"
+	"github.com/kappapee/piphos/internal/fileutil"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// sshCommand is the ssh client run by the ssh command. It is a variable so tests can replace it.
+var sshCommand = "ssh"
+
+// SSH connects to a host, or one of its aliases, by running the system's ssh client with
+// the host's stored IP. The host may be given as user@host, and any further arguments,
+// such as a remote command, are passed on to ssh. The hostname is used as HostKeyAlias,
+// so the host key stays known when the IP changes.
+// The -family flag selects the address family, 4 or 6 (default: IPv4 if known, otherwise IPv6).
+// As for get, unusable records are ignored unless -allow-untrusted admits unverified ones.
+// Returns the error of ssh, an *exec.ExitError if it exited with a non-zero status.
+func SSH(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("ssh", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	family := fs.String("family", "", "address family to connect to: 4 or 6 (default: IPv4 if known)")
+	allowUntrusted := addTrustFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.CommandArgs(fs.NArg(), 1, fs.NArg()); err != nil {
+		return err
+	}
+	if *family != "" && *family != "4" && *family != "6" {
+		return fmt.Errorf("unknown address family: %s", *family)
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return err
+	}
+	records, err := pullRecords(ctx, t, os.Stderr)
+	if err != nil {
+		return err
+	}
+	records, err = usableRecords(records, *allowUntrusted, os.Stderr)
+	if err != nil {
+		return err
+	}
+	sshArgs, err := sshArguments(records, fs.Arg(0), *family, fs.Args()[1:])
+	if err != nil {
+		return err
+	}
+	// ssh handles interrupts itself; keep piphos alive until it exits
+	signal.Notify(make(chan os.Signal, 1), os.Interrupt)
+	defer signal.Reset(os.Interrupt)
+	cmd := osexec.CommandContext(ctx, sshCommand, sshArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// sshArguments returns the arguments for ssh connecting to target, which is a host or
+// user@host, followed by extra.
+func sshArguments(records map[string]tender.Record, target, family string, extra []string) ([]string, error) {
+	user, host := "", target
+	if i := strings.LastIndex(target, "@"); i >= 0 {
+		user, host = target[:i+1], target[i+1:]
+	}
+	hostname, _, _ := tender.Resolve(records, host)
+	ip, err := address(records, host, family)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{"-o", "HostKeyAlias=" + hostname, user + ip}, extra...), nil
+}
+
+// SSHConfig writes an ssh_config Host block for every host, with the stored IP as HostName,
+// for an Include in ~/.ssh/config. The blocks are enclosed in marker comments. With -file,
+// they replace the marked section of that file, which is written atomically and otherwise
+// kept as is, so it can be refreshed from cron; nothing is written to w then.
+// The -family flag selects the address family and -user sets the User of every host.
+// Only usable records are written, see usableRecords and -allow-untrusted.
+func SSHConfig(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("ssh-config", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	family := fs.String("family", "", "address family to use: 4 or 6 (default: IPv4 if known)")
+	user := fs.String("user", "", "user to log in as on every host (default: ssh's default)")
+	file := fs.String("file", "", "update the piphos section of this file instead of writing to stdout")
+	allowUntrusted := addTrustFlag(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return err
+	}
+	if *family != "" && *family != "4" && *family != "6" {
+		return fmt.Errorf("unknown address family: %s", *family)
+	}
+	t, err := tf.newTender()
+	if err != nil {
+		return err
+	}
+	records, err := pullRecords(ctx, t, os.Stderr)
+	if err != nil {
+		return err
+	}
+	records, err = usableRecords(records, *allowUntrusted, os.Stderr)
+	if err != nil {
+		return err
+	}
+	block := sshConfigBlock(records, *family, *user)
+	begin, end := markers("#", *tf.namespace)
+	if *file == "" {
+		_, err := fmt.Fprintf(w, "%s\n%s%s\n", begin, block, end)
+		return err
+	}
+	_, err = fileutil.UpdateBlock(*file, begin, end, []byte(block), 0o600)
+	return err
+}
+
+// sshConfigBlock returns a Host block per host sorted by hostname, listing the aliases as
+// further patterns. Hosts without an address in family, and names ssh would read as a
+// pattern, are skipped.
+func sshConfigBlock(records map[string]tender.Record, family, user string) string {
+	var b strings.Builder
+	for _, hostname := range slices.Sorted(maps.Keys(records)) {
+		ip, err := address(records, hostname, family)
+		if err != nil || !sshName(hostname) {
+			continue
+		}
+		names := []string{hostname}
+		for _, alias := range records[hostname].Aliases {
+			if sshName(alias) {
+				names = append(names, alias)
+			}
+		}
+		fmt.Fprintf(&b, "Host %s\n", strings.Join(names, " "))
+		fmt.Fprintf(&b, "    HostName %s\n", ip)
+		fmt.Fprintf(&b, "    HostKeyAlias %s\n", hostname)
+		if user != "" {
+			fmt.Fprintf(&b, "    User %s\n", user)
+		}
+	}
+	return b.String()
+}
+
+// sshName reports whether name can be used literally in an ssh_config Host line.
+func sshName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\"*?!,") && name[0] != '-'
+}
+
+// markers returns the comment lines enclosing the section piphos manages in a file,
+// one section per namespace.
+func markers(comment, namespace string) (string, string) {
+	if namespace == "" {
+		namespace = config.DefaultNamespace
+	}
+	return fmt.Sprintf("%s BEGIN piphos %s", comment, namespace), fmt.Sprintf("%s END piphos %s", comment, namespace)
+}
@@ -0,0 +1,175 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestSSH(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "missing host", args: []string{}},
+		{name: "unknown family", args: []string{"-family", "5", "server"}},
+		{name: "unknown tender", args: []string{"-tender", "unknown", "server"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SSH(context.Background(), tt.args); err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestSSHArguments(t *testing.T) {
+	records := map[string]tender.Record{
+		"server": {IPv4: "203.0.113.1", IPv6: "2001:db8::1", Aliases: []string{"nas"}},
+	}
+	tests := []struct {
+		name          string
+		target        string
+		family        string
+		extra         []string
+		expectedArgs  []string
+		expectedError error
+	}{
+		{
+			name:         "host",
+			target:       "server",
+			expectedArgs: []string{"-o", "HostKeyAlias=server", "203.0.113.1"},
+		},
+		{
+			name:         "user and alias",
+			target:       "admin@nas",
+			extra:        []string{"uptime"},
+			expectedArgs: []string{"-o", "HostKeyAlias=server", "admin@203.0.113.1", "uptime"},
+		},
+		{
+			name:         "ipv6",
+			target:       "server",
+			family:       "6",
+			expectedArgs: []string{"-o", "HostKeyAlias=server", "2001:db8::1"},
+		},
+		{
+			name:          "unknown host",
+			target:        "admin@laptop",
+			expectedError: tender.ErrHostNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := sshArguments(records, tt.target, tt.family, tt.extra)
+			if tt.expectedError != nil {
+				if !errors.Is(err, tt.expectedError) {
+					t.Errorf("expected error %v but got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if !slices.Equal(args, tt.expectedArgs) {
+				t.Errorf("expected %q but got %q", tt.expectedArgs, args)
+			}
+		})
+	}
+}
+
+func TestSSHConfigBlock(t *testing.T) {
+	records := map[string]tender.Record{
+		"server":  {IPv4: "203.0.113.1", Aliases: []string{"nas", "bad*alias"}},
+		"laptop":  {IPv6: "2001:db8::2"},
+		"web*":    {IPv4: "203.0.113.3"},
+		"printer": {IPv4: "203.0.113.9"},
+	}
+	tests := []struct {
+		name          string
+		family        string
+		user          string
+		expectedBlock string
+	}{
+		{
+			name:   "all hosts",
+			family: "",
+			expectedBlock: "Host laptop\n    HostName 2001:db8::2\n    HostKeyAlias laptop\n" +
+				"Host printer\n    HostName 203.0.113.9\n    HostKeyAlias printer\n" +
+				"Host server nas\n    HostName 203.0.113.1\n    HostKeyAlias server\n",
+		},
+		{
+			name:          "ipv6 with user",
+			family:        "6",
+			user:          "admin",
+			expectedBlock: "Host laptop\n    HostName 2001:db8::2\n    HostKeyAlias laptop\n    User admin\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if block := sshConfigBlock(records, tt.family, tt.user); block != tt.expectedBlock {
+				t.Errorf("expected %q but got %q", tt.expectedBlock, block)
+			}
+		})
+	}
+}
+
+// storeHosts writes records as the document of the file tender's default namespace.
+func storeHosts(t *testing.T, records map[string]tender.Record) {
+	t.Helper()
+	withoutConfig(t)
+	t.Setenv("PIPHOS_KEY_FILE", "")
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Setenv("PIPHOS_FILE_DIR", dir)
+	content, err := tender.EncodeHosts(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hosts.json"), content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSSHConfig_Injection(t *testing.T) {
+	storeHosts(t, map[string]tender.Record{
+		"srv":                                    {IPv4: "203.0.113.9\n    ProxyCommand touch /tmp/pwned"},
+		"web\n    ProxyCommand touch /tmp/pwned": {IPv4: "203.0.113.10"},
+		"nas":                                    {IPv4: "203.0.113.11", Aliases: []string{"backup\n    ProxyCommand touch /tmp/pwned"}},
+	})
+	var out strings.Builder
+	if err := SSHConfig(context.Background(), []string{"-tender", "file"}, &out); err != nil {
+		t.Fatalf("failed to write ssh config: %v", err)
+	}
+	if strings.Contains(out.String(), "ProxyCommand") {
+		t.Errorf("expected injected lines to be skipped but got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Host nas\n    HostName 203.0.113.11\n") {
+		t.Errorf("expected the valid host to be kept but got:\n%s", out.String())
+	}
+}
+
+func TestSSHConfig_AliasTakeover(t *testing.T) {
+	storeHosts(t, map[string]tender.Record{
+		"laptop": {IPv4: "198.51.100.66", Aliases: []string{"server"}},
+		"server": {IPv4: "203.0.113.10"},
+	})
+	var out strings.Builder
+	if err := SSHConfig(context.Background(), []string{"-tender", "file"}, &out); err != nil {
+		t.Fatalf("failed to write ssh config: %v", err)
+	}
+	if strings.Contains(out.String(), "Host laptop server") {
+		t.Errorf("expected the alias naming another host to be skipped but got:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Host server\n    HostName 203.0.113.10\n") {
+		t.Errorf("expected server to keep its own address but got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,161 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kappapee/piphos/internal/identity"
+	"github.com/kappapee/piphos/internal/output"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// Identity writes this host's public signing key to w, generating the key on first use.
+// The key is what other hosts add with trust add.
+func Identity(args []string, w io.Writer) (string, error) {
+	fs := flag.NewFlagSet("identity", flag.ExitOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return "", err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return "", err
+	}
+	signer, _, err := identity.LoadOrCreateSigner()
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintln(w, signer.PublicKey())
+	return signer.PublicKey(), nil
+}
+
+// TrustAdd trusts a key to sign the records of a host. Without a key argument, the key the
+// host's stored record is currently signed with is trusted, after verifying its signature.
+func TrustAdd(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("trust add", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.CommandArgs(fs.NArg(), 1, 2); err != nil {
+		return err
+	}
+	hostname, publicKey := fs.Arg(0), fs.Arg(1)
+	ts, err := identity.LoadTrustStore()
+	if err != nil {
+		return err
+	}
+	if publicKey == "" {
+		publicKey, err = storedKey(ctx, tf, hostname, ts)
+		if err != nil {
+			return err
+		}
+	}
+	if err := ts.Add(hostname, publicKey); err != nil {
+		return err
+	}
+	path, err := identity.TrustStorePath()
+	if err != nil {
+		return err
+	}
+	if err := ts.Save(path); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "trusted key %s for %s\n", identity.Fingerprint(publicKey), hostname)
+	return nil
+}
+
+// storedKey returns the key the stored record of hostname is signed with.
+func storedKey(ctx context.Context, tf *tenderFlags, hostname string, ts *identity.TrustStore) (string, error) {
+	t, err := tf.newTender()
+	if err != nil {
+		return "", err
+	}
+	records, err := pullRecords(ctx, t, os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	record, ok := records[hostname]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", tender.ErrHostNotFound, hostname)
+	}
+	if status := ts.Verify(hostname, record); status != identity.Verified && status != identity.Untrusted {
+		return "", fmt.Errorf("cannot trust the stored key of %s: %s", hostname, status)
+	}
+	return record.PublicKey, nil
+}
+
+// TrustRevoke revokes a single key, or every key trusted for a host.
+// Records signed with a revoked key are marked by pull, even for other hostnames.
+func TrustRevoke(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("trust revoke", flag.ExitOnError)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.CommandArgs(fs.NArg(), 1, 1); err != nil {
+		return err
+	}
+	ts, err := identity.LoadTrustStore()
+	if err != nil {
+		return err
+	}
+	keys, err := ts.Revoke(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	path, err := identity.TrustStorePath()
+	if err != nil {
+		return err
+	}
+	if err := ts.Save(path); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		fmt.Fprintf(w, "revoked key %s\n", identity.Fingerprint(key))
+	}
+	return nil
+}
+
+// TrustedKey is an entry of the trusted keys file as written by trust list.
+type TrustedKey struct {
+	// Host is empty for revoked keys, which are revoked for every host.
+	Host        string `json:"host,omitempty"`
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+	Revoked     bool   `json:"revoked"`
+}
+
+// TrustList writes the trusted and revoked keys to w.
+// The -o flag selects the output format, see output.Format (default: table).
+func TrustList(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("trust list", flag.ExitOnError)
+	format := outputVar(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return err
+	}
+	ts, err := identity.LoadTrustStore()
+	if err != nil {
+		return err
+	}
+	keys := []TrustedKey{}
+	for _, trust := range ts.Trusted() {
+		keys = append(keys, TrustedKey{Host: trust.Hostname, PublicKey: trust.PublicKey, Fingerprint: identity.Fingerprint(trust.PublicKey)})
+	}
+	for _, key := range ts.Revoked() {
+		keys = append(keys, TrustedKey{PublicKey: key, Fingerprint: identity.Fingerprint(key), Revoked: true})
+	}
+	result := output.Result{Value: keys, Header: []string{"HOST", "FINGERPRINT", "STATUS", "KEY"}}
+	for _, k := range keys {
+		status := "trusted"
+		if k.Revoked {
+			status = "revoked"
+		}
+		result.Rows = append(result.Rows, []string{k.Host, k.Fingerprint, status, k.PublicKey})
+	}
+	return output.Write(w, *format, result)
+}
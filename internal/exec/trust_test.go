@@ -0,0 +1,140 @@
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/identity"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestTrustCommands(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PIPHOS_SIGNING_KEY_FILE", filepath.Join(dir, "signing_key"))
+	t.Setenv("PIPHOS_TRUSTED_KEYS_FILE", filepath.Join(dir, "trusted_keys"))
+	publicKey, err := Identity([]string{}, &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if err := TrustAdd(context.Background(), []string{"server", publicKey}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	var list bytes.Buffer
+	if err := TrustList([]string{"-o", "csv"}, &list); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !strings.Contains(list.String(), "\nserver,"+identity.Fingerprint(publicKey)+",trusted,"+publicKey) {
+		t.Errorf("expected server to be trusted but got: %q", list.String())
+	}
+	if err := TrustRevoke([]string{"laptop"}, &bytes.Buffer{}); err == nil {
+		t.Error("expected error revoking a host without keys but got nil")
+	}
+	if err := TrustRevoke([]string{"server"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	list.Reset()
+	if err := TrustList([]string{"-o", "csv"}, &list); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !strings.Contains(list.String(), "\n,"+identity.Fingerprint(publicKey)+",revoked,"+publicKey) {
+		t.Errorf("expected key to be revoked but got: %q", list.String())
+	}
+	if err := TrustAdd(context.Background(), []string{"server", "not-a-key"}, &bytes.Buffer{}); err == nil {
+		t.Error("expected error for malformed key but got nil")
+	}
+}
+
+func TestHostRecords_Signatures(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PIPHOS_SIGNING_KEY_FILE", filepath.Join(dir, "signing_key"))
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	signed := tender.Record{IPv4: "203.0.113.1"}
+	if err := sign("server", &signed); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	forged := signed
+	forged.IPv4 = "198.51.100.66"
+	trust := &identity.TrustStore{}
+	if err := trust.Add("server", signed.PublicKey); err != nil {
+		t.Fatalf("failed to trust key: %v", err)
+	}
+	tests := []struct {
+		name           string
+		hostname       string
+		record         tender.Record
+		trust          *identity.TrustStore
+		expectedStatus string
+	}{
+		{name: "verified", hostname: "server", record: signed, trust: trust, expectedStatus: ""},
+		{name: "forged", hostname: "server", record: forged, trust: trust, expectedStatus: "invalid signature"},
+		{name: "unsigned", hostname: "laptop", record: tender.NewRecord("203.0.113.2"), trust: trust, expectedStatus: "unsigned"},
+		{name: "signing not set up", hostname: "laptop", record: tender.NewRecord("203.0.113.2"), trust: &identity.TrustStore{}, expectedStatus: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts := hostRecords(map[string]tender.Record{tt.hostname: tt.record}, now, 0, tt.trust)
+			result := hostsResult(hosts, now)
+			if status := result.Rows[0][6]; status != tt.expectedStatus {
+				t.Errorf("expected status %q but got %q", tt.expectedStatus, status)
+			}
+		})
+	}
+}
+
+func TestUntrustedRecords(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PIPHOS_SIGNING_KEY_FILE", filepath.Join(dir, "signing_key"))
+	server := tender.Record{IPv4: "203.0.113.1"}
+	if err := sign("server", &server); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	// Signed with a valid key, but the key is only trusted for server
+	laptop := tender.Record{IPv4: "198.51.100.66"}
+	if err := sign("laptop", &laptop); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	storeHosts(t, map[string]tender.Record{"server": server, "laptop": laptop})
+	t.Setenv("PIPHOS_TRUSTED_KEYS_FILE", filepath.Join(dir, "trusted_keys"))
+	if err := TrustAdd(context.Background(), []string{"server", server.PublicKey}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("failed to trust key: %v", err)
+	}
+	original := sshCommand
+	sshCommand = "true"
+	defer func() { sshCommand = original }()
+	ctx := context.Background()
+
+	if _, err := Get(ctx, []string{"-tender", "file", "laptop"}, &bytes.Buffer{}); !errors.Is(err, tender.ErrHostNotFound) {
+		t.Errorf("expected get to skip the untrusted record but got: %v", err)
+	}
+	if ip, err := Get(ctx, []string{"-tender", "file", "-allow-untrusted", "laptop"}, &bytes.Buffer{}); err != nil || ip != laptop.IPv4 {
+		t.Errorf("expected -allow-untrusted to use the record but got %q, %v", ip, err)
+	}
+	if ip, err := Get(ctx, []string{"-tender", "file", "server"}, &bytes.Buffer{}); err != nil || ip != server.IPv4 {
+		t.Errorf("expected the trusted record to be used but got %q, %v", ip, err)
+	}
+
+	if err := SSH(ctx, []string{"-tender", "file", "laptop"}); !errors.Is(err, tender.ErrHostNotFound) {
+		t.Errorf("expected ssh to skip the untrusted record but got: %v", err)
+	}
+	if err := SSH(ctx, []string{"-tender", "file", "-allow-untrusted", "laptop"}); err != nil {
+		t.Errorf("expected -allow-untrusted to use the record but got: %v", err)
+	}
+
+	hosts := filepath.Join(dir, "hosts")
+	if err := HostsSync(ctx, []string{"-tender", "file", "-file", hosts}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("failed to sync hosts: %v", err)
+	}
+	content, err := os.ReadFile(hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "# BEGIN piphos default\n203.0.113.1\tserver\n# END piphos default\n"; string(content) != expected {
+		t.Errorf("expected only the trusted host but got %q", content)
+	}
+}
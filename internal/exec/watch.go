@@ -0,0 +1,92 @@
+package exec
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kappapee/piphos/internal/beacon"
+	"github.com/kappapee/piphos/internal/tender"
+	"github.com/kappapee/piphos/internal/validate"
+	"github.com/kappapee/piphos/internal/watch"
+)
+
+// Watch keeps pushing this host's record from a long-running process instead of cron.
+// It detects the public IP every -interval (default: 5m, varied by up to 10%) and pushes
+// only when the IP changed or, with -heartbeat, to refresh the last-seen time.
+// Failures are retried with exponential backoff. Every decision is logged to w.
+// With -hook or -notify, the hosts are also pulled after every check, and the hooks run
+// and notifications are sent for every host whose IP changed, including this one, among
+// the usable records, see usableRecords and -allow-untrusted.
+// It takes the flags of push and runs until the process receives SIGINT or SIGTERM.
+func Watch(ctx context.Context, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	tf := addTenderFlags(fs)
+	pf := addPushFlags(fs)
+	hf := addHookFlags(fs)
+	allowUntrusted := addTrustFlag(fs)
+	interval := durationVar(fs, "interval", "how often to detect the public IP, e.g. 5m (default: 5m)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := validate.Command(fs.NArg()); err != nil {
+		return err
+	}
+	if *interval == 0 {
+		*interval = 5 * time.Minute
+	}
+	hostname, aliases, err := pf.host()
+	if err != nil {
+		return err
+	}
+	b, err := beacon.New(*pf.beacon)
+	if err != nil {
+		return fmt.Errorf("failed to create beacon %s: %w", *pf.beacon, err)
+	}
+	r, err := hf.reactions(*tf.namespace, w)
+	if err != nil {
+		return err
+	}
+	tf.heartbeat = *pf.heartbeat
+	t, err := tf.newTender()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	watcher := &watch.Watcher{
+		Beacon:   b,
+		Tender:   t,
+		Hostname: hostname,
+		NewRecord: func(ctx context.Context, ip string, now time.Time) (tender.Record, error) {
+			records, err := pullRecords(ctx, t, w)
+			if err != nil {
+				return tender.Record{}, err
+			}
+			return pf.record(hostname, ip, aliases, now.UTC(), records[hostname])
+		},
+		Interval:  *interval,
+		Heartbeat: *pf.heartbeat,
+		Log:       w,
+	}
+	if r != nil {
+		watcher.Observe = func(ctx context.Context) {
+			records, err := pullRecords(ctx, t, w)
+			if err == nil {
+				records, err = usableRecords(records, *allowUntrusted, w)
+			}
+			if err != nil {
+				fmt.Fprintf(w, "warning: skipping hooks: %v\n", err)
+				return
+			}
+			observe(ctx, r, records, *tf.namespace, "watch", w)
+		}
+	}
+	fmt.Fprintf(w, "watching the public IP of %s every %s\n", hostname, *interval)
+	return watcher.Run(ctx)
+}
@@ -0,0 +1,27 @@
+package exec
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestWatch(t *testing.T) {
+	t.Setenv("PIPHOS_GITHUB_TOKEN", "test-token")
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "extra arguments", args: []string{"extra"}},
+		{name: "unknown beacon", args: []string{"-beacon", "unknown"}},
+		{name: "unknown tender", args: []string{"-tender", "unknown"}},
+		{name: "own name as alias", args: []string{"-name", "server", "-alias", "server"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Watch(context.Background(), tt.args, io.Discard); err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
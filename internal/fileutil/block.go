@@ -0,0 +1,81 @@
+package fileutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReplaceBlock returns content with the lines between the marker lines begin and end
+// replaced by block, keeping everything outside the markers. If the markers are missing,
+// they are appended together with block. Returns an error if only one marker is present.
+func ReplaceBlock(content []byte, begin, end string, block []byte) ([]byte, error) {
+	lines := strings.SplitAfter(string(content), "\n")
+	start, stop := -1, -1
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		if start < 0 && line == begin {
+			start = i
+		} else if line == end {
+			stop = i
+			if start >= 0 {
+				break
+			}
+		}
+	}
+	if start < 0 && stop >= 0 || start >= 0 && stop < start {
+		return nil, fmt.Errorf("found only one of the markers %q and %q", begin, end)
+	}
+	var section bytes.Buffer
+	section.WriteString(begin + "\n")
+	section.Write(block)
+	if len(block) > 0 && !bytes.HasSuffix(block, []byte("\n")) {
+		section.WriteString("\n")
+	}
+	section.WriteString(end + "\n")
+	var b bytes.Buffer
+	if start < 0 {
+		b.Write(content)
+		if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+			b.WriteString("\n")
+		}
+		b.Write(section.Bytes())
+		return b.Bytes(), nil
+	}
+	b.WriteString(strings.Join(lines[:start], ""))
+	b.Write(section.Bytes())
+	b.WriteString(strings.Join(lines[stop+1:], ""))
+	return b.Bytes(), nil
+}
+
+// UpdateBlock replaces the block between the marker lines begin and end in the file at path,
+// see ReplaceBlock, and writes the file atomically. A missing file is created with perm,
+// an existing file keeps its mode, and a symbolic link is followed rather than replaced.
+// The file is not written if the block is unchanged. Returns whether the file changed.
+func UpdateBlock(path, begin, end string, block []byte, perm os.FileMode) (bool, error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	updated, err := ReplaceBlock(content, begin, end, block)
+	if err != nil {
+		return false, fmt.Errorf("failed to update %s: %w", path, err)
+	}
+	if content != nil && bytes.Equal(content, updated) {
+		return false, nil
+	}
+	if err := WriteAtomic(path, updated, perm); err != nil {
+		return false, err
+	}
+	return true, nil
+}
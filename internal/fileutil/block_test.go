@@ -0,0 +1,114 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceBlock(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         string
+		block           string
+		expectedContent string
+		expectedError   bool
+	}{
+		{
+			name:            "empty file",
+			content:         "",
+			block:           "new\n",
+			expectedContent: "# BEGIN\nnew\n# END\n",
+		},
+		{
+			name:            "append",
+			content:         "keep",
+			block:           "new",
+			expectedContent: "keep\n# BEGIN\nnew\n# END\n",
+		},
+		{
+			name:            "replace",
+			content:         "before\n# BEGIN\nold\nolder\n# END\nafter\n",
+			block:           "new\n",
+			expectedContent: "before\n# BEGIN\nnew\n# END\nafter\n",
+		},
+		{
+			name:            "empty block",
+			content:         "before\n# BEGIN\nold\n# END\n",
+			block:           "",
+			expectedContent: "before\n# BEGIN\n# END\n",
+		},
+		{
+			name:            "windows line endings",
+			content:         "before\r\n# BEGIN\r\nold\r\n# END\r\nafter\r\n",
+			block:           "new\n",
+			expectedContent: "before\r\n# BEGIN\nnew\n# END\nafter\r\n",
+		},
+		{
+			name:          "missing end marker",
+			content:       "# BEGIN\nold\n",
+			expectedError: true,
+		},
+		{
+			name:          "missing begin marker",
+			content:       "old\n# END\n",
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, err := ReplaceBlock([]byte(tt.content), "# BEGIN", "# END", []byte(tt.block))
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if string(content) != tt.expectedContent {
+				t.Errorf("expected %q but got %q", tt.expectedContent, content)
+			}
+		})
+	}
+}
+
+func TestUpdateBlock(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "config")
+	if err := os.WriteFile(target, []byte("Host *\n"), 0o640); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symbolic links not supported: %v", err)
+	}
+	changed, err := UpdateBlock(link, "# BEGIN", "# END", []byte("new\n"), 0o600)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !changed {
+		t.Error("expected the file to change")
+	}
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if expected := "Host *\n# BEGIN\nnew\n# END\n"; string(content) != expected {
+		t.Errorf("expected %q but got %q", expected, content)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Error("expected the symbolic link to be kept")
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0o640 {
+		t.Errorf("expected the file mode to be kept")
+	}
+	changed, err = UpdateBlock(link, "# BEGIN", "# END", []byte("new\n"), 0o600)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if changed {
+		t.Error("expected an unchanged block not to be written")
+	}
+}
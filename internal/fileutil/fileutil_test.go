@@ -0,0 +1,39 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "file")
+	if err := WriteAtomic(path, []byte("first"), 0o600); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if err := WriteAtomic(path, []byte("second"), 0o644); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	if string(content) != "second" {
+		t.Errorf("expected %q but got %q", "second", content)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o644 {
+		t.Errorf("expected mode 0644 but got %o", perm)
+	}
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("failed to read directory: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected no temporary files to remain but found %d entries", len(entries))
+	}
+}
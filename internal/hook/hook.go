@@ -0,0 +1,170 @@
+// Package hook runs user-configured commands and webhooks when a host's IP changes,
+// e.g. to update firewall allowlists or WireGuard peers.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	osexec "os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds how long a single hook may run.
+const DefaultTimeout = 10 * time.Second
+
+// Event describes a change of a host's IPv4 or IPv6 address, or both.
+type Event struct {
+	Host string `json:"host"`
+	// OldIP and NewIP are the host's preferred addresses, IPv4 if it has one, before and
+	// after the change. OldIP is empty when the host was not known before.
+	OldIP string `json:"old_ip"`
+	NewIP string `json:"new_ip"`
+	// OldIPv4, NewIPv4, OldIPv6 and NewIPv6 are the host's addresses of each family before
+	// and after the change, empty if it had none of the family.
+	OldIPv4   string `json:"old_ipv4"`
+	NewIPv4   string `json:"new_ipv4"`
+	OldIPv6   string `json:"old_ipv6"`
+	NewIPv6   string `json:"new_ipv6"`
+	Namespace string `json:"namespace"`
+	// Source is the command that noticed the change: push, pull or watch.
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+}
+
+// NewEvent returns the event of host's addresses changing from old to new.
+func NewEvent(host string, old, new Addresses, namespace, source string, now time.Time) Event {
+	return Event{
+		Host:      host,
+		OldIP:     old.IP(),
+		NewIP:     new.IP(),
+		OldIPv4:   old.IPv4,
+		NewIPv4:   new.IPv4,
+		OldIPv6:   old.IPv6,
+		NewIPv6:   new.IPv6,
+		Namespace: namespace,
+		Source:    source,
+		Time:      now,
+	}
+}
+
+// Changed reports whether any of the host's addresses differ before and after e.
+func (e Event) Changed() bool {
+	return e.OldIP != e.NewIP || e.OldIPv4 != e.NewIPv4 || e.OldIPv6 != e.NewIPv6
+}
+
+// Runner runs hooks for events. A hook starting with http:// or https:// is a webhook
+// receiving the event as a JSON POST; any other hook is a shell command receiving the
+// event as JSON on stdin and in the PIPHOS_HOST, PIPHOS_OLD_IP, PIPHOS_NEW_IP,
+// PIPHOS_OLD_IPV4, PIPHOS_NEW_IPV4, PIPHOS_OLD_IPV6, PIPHOS_NEW_IPV6, PIPHOS_NAMESPACE
+// and PIPHOS_SOURCE environment variables.
+type Runner struct {
+	Hooks []string
+	// Timeout bounds each hook, DefaultTimeout if zero.
+	Timeout time.Duration
+	// Log receives the output of commands and hook failures. Nil discards them.
+	Log io.Writer
+	// Client sends webhooks, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Run runs every hook for every event, in order. Failures are logged rather than
+// returned, so hooks never stop the command that triggered them.
+func (r *Runner) Run(ctx context.Context, events []Event) {
+	for _, e := range events {
+		for _, h := range r.Hooks {
+			if err := r.run(ctx, h, e); err != nil {
+				r.logf("hook %q for %s failed: %v\n", h, e.Host, err)
+			}
+		}
+	}
+}
+
+// run runs a single hook for e within the timeout.
+func (r *Runner) run(ctx context.Context, h string, e Event) error {
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	if strings.HasPrefix(h, "http://") || strings.HasPrefix(h, "https://") {
+		return r.post(ctx, h, payload)
+	}
+	cmd := shell(ctx, h)
+	cmd.Env = append(os.Environ(),
+		"PIPHOS_HOST="+e.Host,
+		"PIPHOS_OLD_IP="+e.OldIP,
+		"PIPHOS_NEW_IP="+e.NewIP,
+		"PIPHOS_OLD_IPV4="+e.OldIPv4,
+		"PIPHOS_NEW_IPV4="+e.NewIPv4,
+		"PIPHOS_OLD_IPV6="+e.OldIPv6,
+		"PIPHOS_NEW_IPV6="+e.NewIPv6,
+		"PIPHOS_NAMESPACE="+e.Namespace,
+		"PIPHOS_SOURCE="+e.Source,
+	)
+	cmd.Stdin = bytes.NewReader(payload)
+	if r.Log != nil {
+		cmd.Stdout, cmd.Stderr = r.Log, r.Log
+	}
+	// Do not wait for background processes the hook left holding its output
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %s", timeout)
+		}
+		return err
+	}
+	return nil
+}
+
+// post sends payload to the webhook at url.
+func (r *Runner) post(ctx context.Context, url string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close response body: %v\n", err)
+		}
+	}()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// shell returns the command running line with the system's shell.
+func shell(ctx context.Context, line string) *osexec.Cmd {
+	if runtime.GOOS == "windows" {
+		return osexec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return osexec.CommandContext(ctx, "/bin/sh", "-c", line)
+}
+
+func (r *Runner) logf(format string, args ...any) {
+	if r.Log != nil {
+		fmt.Fprintf(r.Log, format, args...)
+	}
+}
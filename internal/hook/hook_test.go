@@ -0,0 +1,89 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunner_Command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands are run with /bin/sh in this test")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	r := &Runner{Hooks: []string{`echo "$PIPHOS_HOST $PIPHOS_OLD_IP $PIPHOS_NEW_IP $PIPHOS_SOURCE" > ` + out + ` && cat >> ` + out}}
+	e := Event{Host: "server", OldIP: "203.0.113.1", NewIP: "203.0.113.2", Namespace: "default", Source: "pull"}
+	r.Run(context.Background(), []Event{e})
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("expected the hook to run but got: %v", err)
+	}
+	env, stdin, _ := strings.Cut(string(content), "\n")
+	if env != "server 203.0.113.1 203.0.113.2 pull" {
+		t.Errorf("unexpected environment: %q", env)
+	}
+	var received Event
+	if err := json.Unmarshal([]byte(stdin), &received); err != nil || received != e {
+		t.Errorf("expected event %+v on stdin but got %q", e, stdin)
+	}
+}
+
+func TestRunner_Failures(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands are run with /bin/sh in this test")
+	}
+	var log bytes.Buffer
+	r := &Runner{Hooks: []string{"exit 3", "sleep 5"}, Timeout: 100 * time.Millisecond, Log: &log}
+	start := time.Now()
+	r.Run(context.Background(), []Event{{Host: "server"}})
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("expected the slow hook to be stopped but waited %s", elapsed)
+	}
+	if !strings.Contains(log.String(), `hook "exit 3" for server failed`) {
+		t.Errorf("expected the failing hook to be logged but got: %q", log.String())
+	}
+	if !strings.Contains(log.String(), `hook "sleep 5" for server failed: timed out`) {
+		t.Errorf("expected the timeout to be logged but got: %q", log.String())
+	}
+}
+
+func TestRunner_Webhook(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		expectedError bool
+	}{
+		{name: "accepted", status: http.StatusNoContent},
+		{name: "rejected", status: http.StatusInternalServerError, expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var received Event
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+				}
+				json.NewDecoder(r.Body).Decode(&received)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+			var log bytes.Buffer
+			r := &Runner{Hooks: []string{server.URL}, Log: &log}
+			r.Run(context.Background(), []Event{{Host: "server", NewIP: "203.0.113.2"}})
+			if received.Host != "server" || received.NewIP != "203.0.113.2" {
+				t.Errorf("unexpected event received: %+v", received)
+			}
+			if failed := log.Len() > 0; failed != tt.expectedError {
+				t.Errorf("expected failure %v but got log %q", tt.expectedError, log.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package hook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/kappapee/piphos/internal/config"
+	"github.com/kappapee/piphos/internal/fileutil"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+// StatePath returns the path of the file remembering the addresses last observed in namespace,
+// kept in StateDir.
+func StatePath(namespace string) (string, error) {
+	if namespace == "" {
+		namespace = config.DefaultNamespace
+	}
+	dir, err := StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ips-"+namespace+".json"), nil
+}
+
+// StateDir returns the directory named by PIPHOS_STATE_DIR, or piphos in the user cache directory.
+func StateDir() (string, error) {
+	if dir := os.Getenv("PIPHOS_STATE_DIR"); dir != "" {
+		return dir, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find cache directory: %w", err)
+	}
+	return filepath.Join(cache, "piphos"), nil
+}
+
+// Addresses are the IPv4 and IPv6 addresses of a host, empty if it has none of the family.
+type Addresses struct {
+	IPv4 string `json:"ipv4,omitempty"`
+	IPv6 string `json:"ipv6,omitempty"`
+	// legacy marks a single address remembered by earlier releases, which did not keep
+	// the other family; only the preferred addresses can be compared with it.
+	legacy bool
+}
+
+// AddressesOf returns the addresses of r.
+func AddressesOf(r tender.Record) Addresses {
+	return Addresses{IPv4: r.IPv4, IPv6: r.IPv6}
+}
+
+// IP returns the preferred address, IPv4 if there is one.
+func (a Addresses) IP() string {
+	if a.IPv4 != "" {
+		return a.IPv4
+	}
+	return a.IPv6
+}
+
+// same reports whether a and other hold the same addresses.
+func (a Addresses) same(other Addresses) bool {
+	if a.legacy || other.legacy {
+		return a.IP() == other.IP()
+	}
+	return a.IPv4 == other.IPv4 && a.IPv6 == other.IPv6
+}
+
+// UnmarshalJSON also accepts the single address remembered by earlier releases.
+func (a *Addresses) UnmarshalJSON(data []byte) error {
+	var ip string
+	if err := json.Unmarshal(data, &ip); err == nil {
+		r := tender.NewRecord(ip)
+		*a = Addresses{IPv4: r.IPv4, IPv6: r.IPv6, legacy: true}
+		return nil
+	}
+	type plain Addresses
+	return json.Unmarshal(data, (*plain)(a))
+}
+
+// LoadState reads the addresses by hostname last observed from path.
+// Returns nil without error if nothing was observed yet.
+func LoadState(path string) (map[string]Addresses, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+	var state map[string]Addresses
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+	if state == nil {
+		state = map[string]Addresses{}
+	}
+	return state, nil
+}
+
+// SaveState writes the addresses by hostname to path.
+func SaveState(path string, state map[string]Addresses) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	return fileutil.WriteAtomic(path, append(data, '\n'), 0o600)
+}
+
+// Observe compares records with the addresses previously observed and returns an event
+// for every host whose IPv4 or IPv6 address changed or that appeared, sorted by hostname,
+// together with the new state. Removed hosts produce no event. Without a previous state,
+// there are no events.
+func Observe(previous map[string]Addresses, records map[string]tender.Record, namespace, source string, now time.Time) ([]Event, map[string]Addresses) {
+	state := make(map[string]Addresses, len(records))
+	var events []Event
+	for _, hostname := range slices.Sorted(maps.Keys(records)) {
+		addresses := AddressesOf(records[hostname])
+		state[hostname] = addresses
+		old, known := previous[hostname]
+		if previous == nil || addresses.IP() == "" || known && old.same(addresses) {
+			continue
+		}
+		events = append(events, NewEvent(hostname, old, addresses, namespace, source, now))
+	}
+	return events, state
+}
@@ -0,0 +1,124 @@
+package hook
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func TestObserve(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	records := map[string]tender.Record{
+		"server": tender.NewRecord("203.0.113.2"),
+		"laptop": tender.NewRecord("198.51.100.7"),
+		"new":    tender.NewRecord("192.0.2.1"),
+		"nas":    {IPv4: "203.0.113.5", IPv6: "2001:db8::2"},
+	}
+	tests := []struct {
+		name          string
+		previous      map[string]Addresses
+		expectedHosts []string
+	}{
+		{
+			name:     "first observation",
+			previous: nil,
+		},
+		{
+			name: "changed and new hosts",
+			previous: map[string]Addresses{
+				"server": {IPv4: "203.0.113.1"},
+				"laptop": {IPv4: "198.51.100.7"},
+				"nas":    {IPv4: "203.0.113.5", IPv6: "2001:db8::2"},
+				"gone":   {IPv4: "192.0.2.9"},
+			},
+			expectedHosts: []string{"new", "server"},
+		},
+		{
+			name: "only IPv6 changed",
+			previous: map[string]Addresses{
+				"server": {IPv4: "203.0.113.2"},
+				"laptop": {IPv4: "198.51.100.7"},
+				"new":    {IPv4: "192.0.2.1"},
+				"nas":    {IPv4: "203.0.113.5", IPv6: "2001:db8::1"},
+			},
+			expectedHosts: []string{"nas"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, state := Observe(tt.previous, records, "default", "pull", now)
+			var hosts []string
+			for _, e := range events {
+				hosts = append(hosts, e.Host)
+				old, current := tt.previous[e.Host], records[e.Host]
+				if e.NewIPv4 != current.IPv4 || e.NewIPv6 != current.IPv6 || e.OldIPv4 != old.IPv4 || e.OldIPv6 != old.IPv6 {
+					t.Errorf("expected the addresses of both families in event %+v", e)
+				}
+				if e.NewIP != current.IP() || e.OldIP != old.IP() || e.Source != "pull" || !e.Time.Equal(now) {
+					t.Errorf("unexpected event: %+v", e)
+				}
+			}
+			if !slices.Equal(hosts, tt.expectedHosts) {
+				t.Errorf("expected events for %v but got %v", tt.expectedHosts, hosts)
+			}
+			expectedState := map[string]Addresses{
+				"server": {IPv4: "203.0.113.2"},
+				"laptop": {IPv4: "198.51.100.7"},
+				"new":    {IPv4: "192.0.2.1"},
+				"nas":    {IPv4: "203.0.113.5", IPv6: "2001:db8::2"},
+			}
+			if !maps.Equal(state, expectedState) {
+				t.Errorf("expected state %v but got %v", expectedState, state)
+			}
+		})
+	}
+}
+
+func TestState(t *testing.T) {
+	t.Setenv("PIPHOS_STATE_DIR", t.TempDir())
+	path, err := StatePath("")
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if filepath.Base(path) != "ips-default.json" {
+		t.Errorf("unexpected state path: %s", path)
+	}
+	state, err := LoadState(path)
+	if err != nil || state != nil {
+		t.Fatalf("expected no state but got %v, %v", state, err)
+	}
+	if err := SaveState(path, map[string]Addresses{"server": {IPv4: "203.0.113.1", IPv6: "2001:db8::1"}}); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	state, err = LoadState(path)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if state["server"] != (Addresses{IPv4: "203.0.113.1", IPv6: "2001:db8::1"}) {
+		t.Errorf("unexpected state: %v", state)
+	}
+}
+
+func TestState_Legacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ips-default.json")
+	if err := os.WriteFile(path, []byte(`{"server": "203.0.113.1", "nas": "2001:db8::1"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	previous, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	records := map[string]tender.Record{
+		"server": {IPv4: "203.0.113.1", IPv6: "2001:db8::5"},
+		"nas":    {IPv6: "2001:db8::2"},
+	}
+	events, _ := Observe(previous, records, "default", "pull", time.Now())
+	if len(events) != 1 || events[0].Host != "nas" || events[0].OldIPv6 != "2001:db8::1" || events[0].NewIPv6 != "2001:db8::2" {
+		t.Errorf("expected only the changed address remembered by an earlier release to be reported but got %+v", events)
+	}
+}
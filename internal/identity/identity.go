@@ -0,0 +1,144 @@
+// Package identity signs host records and verifies them against a list of trusted keys.
+//
+// Every host signs the records it pushes with its own Ed25519 key, generated on first use.
+// A trusted keys file lists which key may sign for which hostname, so a record written
+// by anyone else holding the storage token, e.g. a compromised laptop impersonating a
+// server, is flagged by pull instead of silently trusted.
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kappapee/piphos/internal/fileutil"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+// publicKeyPrefix marks an encoded public key.
+const publicKeyPrefix = "piphos-ed25519-"
+
+// EncodePublicKey encodes a public key for records, the trusted keys file and the terminal.
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return publicKeyPrefix + base64.RawURLEncoding.EncodeToString(key)
+}
+
+// ParsePublicKey decodes a public key encoded by EncodePublicKey.
+func ParsePublicKey(encoded string) (ed25519.PublicKey, error) {
+	body, ok := strings.CutPrefix(encoded, publicKeyPrefix)
+	if !ok {
+		return nil, fmt.Errorf("invalid public key: missing %s prefix", publicKeyPrefix)
+	}
+	key, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: expected %d bytes but got %d", ed25519.PublicKeySize, len(key))
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Fingerprint returns a short identifier of an encoded public key, safe to print.
+func Fingerprint(encoded string) string {
+	sum := sha256.Sum256([]byte(encoded))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Signer signs records with this host's private key.
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// SigningKeyPath returns the signing key location: PIPHOS_SIGNING_KEY_FILE if set,
+// otherwise "piphos/signing_key" in the user's configuration directory.
+func SigningKeyPath() (string, error) {
+	return configPath("PIPHOS_SIGNING_KEY_FILE", "signing_key")
+}
+
+// LoadOrCreateSigner reads this host's signing key, generating and saving a new one on
+// first use. The returned bool reports whether a new key was generated.
+func LoadOrCreateSigner() (*Signer, bool, error) {
+	path, err := SigningKeyPath()
+	if err != nil {
+		return nil, false, err
+	}
+	content, err := os.ReadFile(path)
+	if err == nil {
+		signer, err := parseSigner(content)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid signing key %s: %w", path, err)
+		}
+		return signer, false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, false, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to marshal signing key: %w", err)
+	}
+	if err := fileutil.WriteAtomic(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		return nil, false, fmt.Errorf("failed to save signing key: %w", err)
+	}
+	return &Signer{key: key}, true, nil
+}
+
+// parseSigner decodes a PEM encoded PKCS#8 Ed25519 private key.
+func parseSigner(content []byte) (*Signer, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an Ed25519 key")
+	}
+	return &Signer{key: key}, nil
+}
+
+// PublicKey returns the encoded public key of the signer.
+func (s *Signer) PublicKey() string {
+	return EncodePublicKey(s.key.Public().(ed25519.PublicKey))
+}
+
+// Sign sets the record's public key and signs it for storage under hostname.
+func (s *Signer) Sign(hostname string, r *tender.Record) error {
+	r.PublicKey = s.PublicKey()
+	payload, err := r.SigningPayload(hostname)
+	if err != nil {
+		return err
+	}
+	r.Signature = base64.RawURLEncoding.EncodeToString(ed25519.Sign(s.key, payload))
+	return nil
+}
+
+// configPath returns the file named by the environment variable env, or name inside the
+// piphos directory of the user's configuration directory.
+func configPath(env, name string) (string, error) {
+	if path := os.Getenv(env); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find configuration directory: %w", err)
+	}
+	return filepath.Join(dir, "piphos", name), nil
+}
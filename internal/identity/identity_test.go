@@ -0,0 +1,155 @@
+package identity
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	t.Setenv("PIPHOS_SIGNING_KEY_FILE", filepath.Join(t.TempDir(), "signing_key"))
+	signer, created, err := LoadOrCreateSigner()
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+	if !created {
+		t.Fatal("expected a new signing key to be generated")
+	}
+	return signer
+}
+
+func TestLoadOrCreateSigner(t *testing.T) {
+	signer := newTestSigner(t)
+	reloaded, created, err := LoadOrCreateSigner()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if created {
+		t.Error("expected the existing signing key to be reused")
+	}
+	if reloaded.PublicKey() != signer.PublicKey() {
+		t.Errorf("expected public key %s but got %s", signer.PublicKey(), reloaded.PublicKey())
+	}
+}
+
+func TestVerify(t *testing.T) {
+	signer := newTestSigner(t)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	signed := tender.Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now, Aliases: []string{"nas"}}
+	if err := signer.Sign("server", &signed); err != nil {
+		t.Fatalf("failed to sign record: %v", err)
+	}
+	// Records are stored as JSON, so verify what comes back from storage
+	content, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatalf("failed to marshal record: %v", err)
+	}
+	var stored tender.Record
+	if err := json.Unmarshal(content, &stored); err != nil {
+		t.Fatalf("failed to unmarshal record: %v", err)
+	}
+	tampered := stored
+	tampered.IPv4 = "198.51.100.66"
+	carriedOver := stored
+	carriedOver.UpdatedAt = now.Add(-72 * time.Hour)
+	trusted := &TrustStore{}
+	if err := trusted.Add("server", signer.PublicKey()); err != nil {
+		t.Fatalf("failed to trust key: %v", err)
+	}
+	revoked := &TrustStore{}
+	revoked.Add("server", signer.PublicKey())
+	revoked.Revoke(signer.PublicKey())
+	tests := []struct {
+		name           string
+		store          *TrustStore
+		hostname       string
+		record         tender.Record
+		expectedStatus Status
+	}{
+		{name: "verified", store: trusted, hostname: "server", record: stored, expectedStatus: Verified},
+		{name: "updated time carried over", store: trusted, hostname: "server", record: carriedOver, expectedStatus: Verified},
+		{name: "unsigned", store: trusted, hostname: "server", record: tender.NewRecord("203.0.113.1"), expectedStatus: Unsigned},
+		{name: "tampered address", store: trusted, hostname: "server", record: tampered, expectedStatus: Invalid},
+		{name: "copied to another hostname", store: trusted, hostname: "laptop", record: stored, expectedStatus: Invalid},
+		{name: "key not trusted", store: &TrustStore{}, hostname: "server", record: stored, expectedStatus: Untrusted},
+		{name: "key revoked", store: revoked, hostname: "server", record: stored, expectedStatus: Revoked},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := tt.store.Verify(tt.hostname, tt.record); status != tt.expectedStatus {
+				t.Errorf("expected %s but got %s", tt.expectedStatus, status)
+			}
+		})
+	}
+}
+
+func TestTrustStore(t *testing.T) {
+	laptop := newTestSigner(t).PublicKey()
+	server := newTestSigner(t).PublicKey()
+	path := filepath.Join(t.TempDir(), "trusted_keys")
+	t.Setenv("PIPHOS_TRUSTED_KEYS_FILE", path)
+	ts, err := LoadTrustStore()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !ts.Empty() {
+		t.Error("expected an empty store without trusted keys file")
+	}
+	if err := ts.Add("laptop", laptop); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if err := ts.Add("server", server); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if err := ts.Add("nas", server); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if err := ts.Add("laptop", "not-a-key"); err == nil {
+		t.Error("expected error for malformed key but got nil")
+	}
+	revokedKeys, err := ts.Revoke("laptop")
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if len(revokedKeys) != 1 || revokedKeys[0] != laptop {
+		t.Errorf("expected the laptop key to be revoked but got %v", revokedKeys)
+	}
+	if _, err := ts.Revoke("desktop"); err == nil {
+		t.Error("expected error revoking unknown host but got nil")
+	}
+	if err := ts.Add("laptop", laptop); err == nil {
+		t.Error("expected error re-adding a revoked key but got nil")
+	}
+	if err := ts.Save(path); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	loaded, err := LoadTrustStore()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if len(loaded.Trusted()) != 2 || len(loaded.Revoked()) != 1 {
+		t.Errorf("expected 2 trusted and 1 revoked key but got %d and %d", len(loaded.Trusted()), len(loaded.Revoked()))
+	}
+}
+
+func TestParseTrustStore_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "missing key", content: "laptop\n"},
+		{name: "malformed key", content: "laptop piphos-ed25519-AAAA\n"},
+		{name: "too many fields", content: "laptop piphos-ed25519-AAAA extra\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTrustStore([]byte(tt.content)); err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package identity
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/kappapee/piphos/internal/fileutil"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+// revokedMarker starts a line of the trusted keys file listing a revoked key,
+// following the @revoked marker of OpenSSH's known_hosts.
+const revokedMarker = "@revoked"
+
+// Status is the result of verifying a record against the trusted keys.
+type Status int
+
+const (
+	// Verified means the record is signed by a key trusted for its hostname.
+	Verified Status = iota
+	// Unsigned means the record carries no signature.
+	Unsigned
+	// Untrusted means the signature is valid but the key is not trusted for the hostname.
+	Untrusted
+	// Invalid means the signature does not match the record.
+	Invalid
+	// Revoked means the record is signed by a revoked key.
+	Revoked
+)
+
+func (s Status) String() string {
+	switch s {
+	case Verified:
+		return "verified"
+	case Unsigned:
+		return "unsigned"
+	case Untrusted:
+		return "untrusted"
+	case Invalid:
+		return "invalid signature"
+	case Revoked:
+		return "revoked key"
+	default:
+		return "unknown"
+	}
+}
+
+// Trust is a key trusted to sign the records of a hostname.
+type Trust struct {
+	Hostname  string
+	PublicKey string
+}
+
+// TrustStore is the content of the trusted keys file.
+type TrustStore struct {
+	trusted []Trust
+	revoked []string
+}
+
+// TrustStorePath returns the trusted keys file location: PIPHOS_TRUSTED_KEYS_FILE if set,
+// otherwise "piphos/trusted_keys" in the user's configuration directory.
+func TrustStorePath() (string, error) {
+	return configPath("PIPHOS_TRUSTED_KEYS_FILE", "trusted_keys")
+}
+
+// LoadTrustStore reads the trusted keys file. It returns an empty store if there is none.
+func LoadTrustStore() (*TrustStore, error) {
+	path, err := TrustStorePath()
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &TrustStore{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+	}
+	ts, err := ParseTrustStore(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted keys file %s: %w", path, err)
+	}
+	return ts, nil
+}
+
+// ParseTrustStore reads the trusted keys file format: one "<hostname> <public key>" or
+// "@revoked <public key>" per line. Empty lines and lines starting with # are ignored.
+func ParseTrustStore(content []byte) (*TrustStore, error) {
+	ts := &TrustStore{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: expected a hostname or %s and a public key", line, revokedMarker)
+		}
+		if _, err := ParsePublicKey(fields[1]); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if fields[0] == revokedMarker {
+			ts.revoked = append(ts.revoked, fields[1])
+			continue
+		}
+		ts.trusted = append(ts.trusted, Trust{Hostname: fields[0], PublicKey: fields[1]})
+	}
+	return ts, nil
+}
+
+// Empty reports whether the store neither trusts nor revokes any key, i.e. signing is not set up.
+func (ts *TrustStore) Empty() bool {
+	return len(ts.trusted) == 0 && len(ts.revoked) == 0
+}
+
+// Trusted returns the trusted keys in file order.
+func (ts *TrustStore) Trusted() []Trust {
+	return ts.trusted
+}
+
+// Revoked returns the revoked keys in file order.
+func (ts *TrustStore) Revoked() []string {
+	return ts.revoked
+}
+
+// Add trusts publicKey to sign the records of hostname.
+// Returns an error if the key is malformed or was revoked.
+func (ts *TrustStore) Add(hostname, publicKey string) error {
+	if _, err := ParsePublicKey(publicKey); err != nil {
+		return err
+	}
+	if slices.Contains(ts.revoked, publicKey) {
+		return fmt.Errorf("key %s was revoked", Fingerprint(publicKey))
+	}
+	trust := Trust{Hostname: hostname, PublicKey: publicKey}
+	if !slices.Contains(ts.trusted, trust) {
+		ts.trusted = append(ts.trusted, trust)
+	}
+	return nil
+}
+
+// Revoke revokes a public key, or every key trusted for a hostname, and returns the
+// revoked keys. A revoked key is no longer trusted for any hostname and cannot be added again.
+func (ts *TrustStore) Revoke(keyOrHostname string) ([]string, error) {
+	var keys []string
+	if _, err := ParsePublicKey(keyOrHostname); err == nil {
+		keys = []string{keyOrHostname}
+	} else {
+		for _, trust := range ts.trusted {
+			if trust.Hostname == keyOrHostname {
+				keys = append(keys, trust.PublicKey)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key is trusted for %s", keyOrHostname)
+	}
+	ts.trusted = slices.DeleteFunc(ts.trusted, func(trust Trust) bool {
+		return slices.Contains(keys, trust.PublicKey)
+	})
+	for _, key := range keys {
+		if !slices.Contains(ts.revoked, key) {
+			ts.revoked = append(ts.revoked, key)
+		}
+	}
+	return keys, nil
+}
+
+// Save writes the store to path.
+func (ts *TrustStore) Save(path string) error {
+	var b strings.Builder
+	b.WriteString("# piphos trusted keys: \"<hostname> <public key>\" or \"" + revokedMarker + " <public key>\"\n")
+	for _, trust := range ts.trusted {
+		fmt.Fprintf(&b, "%s %s\n", trust.Hostname, trust.PublicKey)
+	}
+	for _, key := range ts.revoked {
+		fmt.Fprintf(&b, "%s %s\n", revokedMarker, key)
+	}
+	if err := fileutil.WriteAtomic(path, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to save trusted keys: %w", err)
+	}
+	return nil
+}
+
+// Verify checks the signature of a record stored under hostname against the trusted keys.
+func (ts *TrustStore) Verify(hostname string, r tender.Record) Status {
+	if r.Signature == "" {
+		return Unsigned
+	}
+	key, err := ParsePublicKey(r.PublicKey)
+	if err != nil {
+		return Invalid
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return Invalid
+	}
+	payload, err := r.SigningPayload(hostname)
+	if err != nil || !ed25519.Verify(key, payload, signature) {
+		return Invalid
+	}
+	if slices.Contains(ts.revoked, r.PublicKey) {
+		return Revoked
+	}
+	if !slices.Contains(ts.trusted, Trust{Hostname: hostname, PublicKey: r.PublicKey}) {
+		return Untrusted
+	}
+	return Verified
+}
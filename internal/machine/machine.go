@@ -0,0 +1,106 @@
+// Package machine derives a stable identifier for the machine piphos runs on.
+//
+// The identifier tells machines apart that report under the same hostname, such as
+// several Raspberry Pis all called "raspberrypi". It is derived from the operating
+// system's machine ID but never reveals it: the raw ID is only used as the key of an
+// HMAC over a piphos specific message, as recommended by machine-id(5).
+package machine
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	osexec "os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	// idLength is the number of hex characters of the derived identifier.
+	idLength = 32
+	// commandTimeout bounds how long querying the machine ID from a system tool may take.
+	commandTimeout = 5 * time.Second
+)
+
+// idFiles are the locations of the machine ID on Linux and the BSDs, in order of preference.
+var idFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id", "/etc/hostid"}
+
+// ID returns the piphos specific identifier of this machine.
+// Returns an error if the operating system does not expose a machine ID.
+func ID() (string, error) {
+	raw, err := rawID()
+	if err != nil {
+		return "", err
+	}
+	return derive(raw), nil
+}
+
+// derive turns a raw machine ID into the piphos specific identifier.
+func derive(raw string) string {
+	mac := hmac.New(sha256.New, []byte(raw))
+	mac.Write([]byte("piphos"))
+	return hex.EncodeToString(mac.Sum(nil))[:idLength]
+}
+
+// rawID returns the operating system's machine ID.
+func rawID() (string, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		out, err := command("ioreg", "-rd1", "-c", "IOPlatformExpertDevice")
+		if err != nil {
+			return "", err
+		}
+		return parseValue(out, `"IOPlatformUUID" = `)
+	case "windows":
+		out, err := command("reg", "query", `HKLM\SOFTWARE\Microsoft\Cryptography`, "/v", "MachineGuid")
+		if err != nil {
+			return "", err
+		}
+		return parseValue(out, "REG_SZ")
+	default:
+		return readIDFile(idFiles)
+	}
+}
+
+// readIDFile returns the content of the first non-empty file in paths.
+func readIDFile(paths []string) (string, error) {
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if id := strings.TrimSpace(string(content)); id != "" {
+			return id, nil
+		}
+	}
+	return "", errors.New("no machine ID found")
+}
+
+// command runs a system tool and returns its output.
+func command(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+	out, err := osexec.CommandContext(ctx, name, args...).Output()
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// parseValue returns the quoted or bare value following marker on the first line containing it.
+func parseValue(out, marker string) (string, error) {
+	for _, line := range strings.Split(out, "\n") {
+		_, value, ok := strings.Cut(line, marker)
+		if !ok {
+			continue
+		}
+		if value = strings.Trim(strings.TrimSpace(value), `"`); value != "" {
+			return value, nil
+		}
+	}
+	return "", errors.New("no machine ID found")
+}
@@ -0,0 +1,120 @@
+package machine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDerive(t *testing.T) {
+	id := derive("0123456789abcdef0123456789abcdef")
+	if len(id) != idLength {
+		t.Errorf("expected %d characters but got %d", idLength, len(id))
+	}
+	if id != derive("0123456789abcdef0123456789abcdef") {
+		t.Error("expected the same raw ID to derive the same identifier")
+	}
+	if id == derive("fedcba9876543210fedcba9876543210") {
+		t.Error("expected different raw IDs to derive different identifiers")
+	}
+	if id == "0123456789abcdef0123456789abcdef" {
+		t.Error("expected the raw ID not to be revealed")
+	}
+}
+
+func TestReadIDFile(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	valid := filepath.Join(dir, "machine-id")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	if err := os.WriteFile(valid, []byte("0123456789abcdef\n"), 0o600); err != nil {
+		t.Fatalf("failed to write test file: %v", err)
+	}
+	tests := []struct {
+		name          string
+		paths         []string
+		expectedID    string
+		expectedError bool
+	}{
+		{
+			name:       "first file",
+			paths:      []string{valid},
+			expectedID: "0123456789abcdef",
+		},
+		{
+			name:       "skips missing and empty files",
+			paths:      []string{filepath.Join(dir, "missing"), empty, valid},
+			expectedID: "0123456789abcdef",
+		},
+		{
+			name:          "no file",
+			paths:         []string{filepath.Join(dir, "missing")},
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := readIDFile(tt.paths)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if id != tt.expectedID {
+				t.Errorf("expected %q but got %q", tt.expectedID, id)
+			}
+		})
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		name          string
+		out           string
+		marker        string
+		expectedValue string
+		expectedError bool
+	}{
+		{
+			name:          "ioreg",
+			out:           "+-o J314sAP  <class IOPlatformExpertDevice>\n    \"IOPlatformUUID\" = \"A1B2C3D4-0000-1111-2222-333344445555\"\n",
+			marker:        `"IOPlatformUUID" = `,
+			expectedValue: "A1B2C3D4-0000-1111-2222-333344445555",
+		},
+		{
+			name:          "reg query",
+			out:           "\r\nHKEY_LOCAL_MACHINE\\SOFTWARE\\Microsoft\\Cryptography\r\n    MachineGuid    REG_SZ    5f1e2d3c-aaaa-bbbb-cccc-1234567890ab\r\n",
+			marker:        "REG_SZ",
+			expectedValue: "5f1e2d3c-aaaa-bbbb-cccc-1234567890ab",
+		},
+		{
+			name:          "missing",
+			out:           "nothing here\n",
+			marker:        "REG_SZ",
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := parseValue(tt.out, tt.marker)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if value != tt.expectedValue {
+				t.Errorf("expected %q but got %q", tt.expectedValue, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/kappapee/piphos/internal/fileutil"
+	"github.com/kappapee/piphos/internal/hook"
+)
+
+// DefaultInterval is the default minimum time between two notifications about the same host.
+const DefaultInterval = 10 * time.Minute
+
+// Dispatcher sends a message for every IP change to all notifiers, at most once per
+// Interval for each host, so a flapping link does not flood anyone's phone. Changes held
+// back by the limit are coalesced into one message, sent by the first run after Interval.
+type Dispatcher struct {
+	Notifiers []Notifier
+	// Interval is the minimum time between notifications about a host, DefaultInterval if zero.
+	Interval time.Duration
+	// StatePath is the file remembering when each host was last notified about and the
+	// changes held back, so the limit also holds across runs from cron. Empty only
+	// remembers them in memory.
+	StatePath string
+	// Log receives suppressed notifications and failures. Nil discards them.
+	Log io.Writer
+
+	hosts map[string]notified
+	// now returns the current time when a run has no events to take it from; tests replace it.
+	now func() time.Time
+}
+
+// notified is what the dispatcher remembers about a host.
+type notified struct {
+	Sent time.Time `json:"sent"`
+	// Pending is the change held back by the limit, from the IP last notified about to the latest one.
+	Pending *hook.Event `json:"pending,omitempty"`
+}
+
+// Run notifies about events, which must all be from the same namespace, and sends the
+// changes held back once their host's interval has passed, even if events is empty.
+// Failures are logged rather than returned, so notifications never stop the command
+// that triggered them.
+func (d *Dispatcher) Run(ctx context.Context, events []hook.Event) {
+	interval := d.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if err := d.load(); err != nil {
+		d.logf("warning: %v\n", err)
+	}
+	changed := false
+	for _, e := range events {
+		state, ok := d.hosts[e.Host]
+		if ok && e.Time.Sub(state.Sent) < interval {
+			d.logf("not notifying that %s is now %s yet, last notification was %s ago\n", e.Host, e.NewIP, e.Time.Sub(state.Sent).Round(time.Second))
+			state.Pending = coalesce(state.Pending, e)
+			d.hosts[e.Host] = state
+			changed = true
+			continue
+		}
+		d.send(ctx, *coalesce(state.Pending, e))
+		changed = true
+	}
+	// Events carry the time they happened, which is also the time of this run
+	now := d.currentTime()
+	if len(events) > 0 {
+		now = events[len(events)-1].Time
+	}
+	for _, host := range slices.Sorted(maps.Keys(d.hosts)) {
+		state := d.hosts[host]
+		if state.Pending == nil || now.Sub(state.Sent) < interval {
+			continue
+		}
+		changed = true
+		e := *state.Pending
+		e.Time = now
+		d.send(ctx, e)
+	}
+	if !changed {
+		return
+	}
+	if err := d.save(); err != nil {
+		d.logf("warning: %v\n", err)
+	}
+}
+
+// send notifies all notifiers about e and remembers it as the last notification about its
+// host. A host that went back to the IP last notified about is not notified about.
+func (d *Dispatcher) send(ctx context.Context, e hook.Event) {
+	if e.OldIP != "" && !e.Changed() {
+		d.logf("not notifying about %s, it is back at %s\n", e.Host, e.NewIP)
+		d.hosts[e.Host] = notified{Sent: d.hosts[e.Host].Sent}
+		return
+	}
+	message := Message(e)
+	for _, n := range d.Notifiers {
+		if err := n.Notify(ctx, message); err != nil {
+			d.logf("notification to %s failed: %v\n", n, err)
+		}
+	}
+	// Failed notifications count too: retrying a broken notifier on every change
+	// would flood the log instead
+	d.hosts[e.Host] = notified{Sent: e.Time}
+}
+
+// coalesce returns the change from pending's old addresses to e's new ones, or e without pending.
+func coalesce(pending *hook.Event, e hook.Event) *hook.Event {
+	if pending != nil {
+		e.OldIP, e.OldIPv4, e.OldIPv6 = pending.OldIP, pending.OldIPv4, pending.OldIPv6
+	}
+	return &e
+}
+
+func (d *Dispatcher) currentTime() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now().UTC()
+}
+
+// load reads the times of the last notifications and the changes held back from StatePath.
+func (d *Dispatcher) load() error {
+	if d.StatePath == "" {
+		if d.hosts == nil {
+			d.hosts = make(map[string]notified)
+		}
+		return nil
+	}
+	d.hosts = make(map[string]notified)
+	data, err := os.ReadFile(d.StatePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read notification state: %w", err)
+	}
+	if err := json.Unmarshal(data, &d.hosts); err != nil {
+		return fmt.Errorf("failed to parse notification state %s: %w", d.StatePath, err)
+	}
+	return nil
+}
+
+// save writes the times of the last notifications and the changes held back to StatePath.
+func (d *Dispatcher) save() error {
+	if d.StatePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(d.hosts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification state: %w", err)
+	}
+	return fileutil.WriteAtomic(d.StatePath, append(data, '\n'), 0o600)
+}
+
+func (d *Dispatcher) logf(format string, args ...any) {
+	if d.Log != nil {
+		fmt.Fprintf(d.Log, format, args...)
+	}
+}
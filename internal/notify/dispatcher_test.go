@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/hook"
+)
+
+// fakeNotifier records the messages it was asked to send, failing if err is set.
+type fakeNotifier struct {
+	messages []string
+	err      error
+}
+
+func (n *fakeNotifier) Notify(_ context.Context, message string) error {
+	n.messages = append(n.messages, message)
+	return n.err
+}
+
+func (n *fakeNotifier) String() string { return "fake" }
+
+func TestDispatcher(t *testing.T) {
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	event := func(host, ip string, minutes int) hook.Event {
+		return hook.Event{Host: host, NewIP: ip, Time: start.Add(time.Duration(minutes) * time.Minute)}
+	}
+	change := func(host, oldIP, newIP string, minutes int) hook.Event {
+		e := event(host, newIP, minutes)
+		e.OldIP = oldIP
+		return e
+	}
+	dualStack := func(oldIPv6, newIPv6 string, minutes int) hook.Event {
+		return hook.NewEvent("laptop", hook.Addresses{IPv4: "203.0.113.1", IPv6: oldIPv6}, hook.Addresses{IPv4: "203.0.113.1", IPv6: newIPv6}, "", "pull", start.Add(time.Duration(minutes)*time.Minute))
+	}
+	tests := []struct {
+		name             string
+		runs             [][]hook.Event
+		statePath        bool
+		now              time.Time
+		expectedMessages []string
+	}{
+		{
+			name: "flapping host",
+			runs: [][]hook.Event{
+				{event("laptop", "203.0.113.1", 0)},
+				{event("laptop", "203.0.113.2", 1)},
+				{event("laptop", "203.0.113.1", 2)},
+				{event("laptop", "203.0.113.3", 12)},
+			},
+			expectedMessages: []string{"laptop is now 203.0.113.1", "laptop is now 203.0.113.3"},
+		},
+		{
+			name:             "hosts are limited separately",
+			runs:             [][]hook.Event{{event("laptop", "203.0.113.1", 0), event("server", "203.0.113.2", 0)}},
+			expectedMessages: []string{"laptop is now 203.0.113.1", "server is now 203.0.113.2"},
+		},
+		{
+			name: "limit persists across runs",
+			runs: [][]hook.Event{
+				{event("laptop", "203.0.113.1", 0)},
+				{event("laptop", "203.0.113.2", 5)},
+			},
+			statePath:        true,
+			expectedMessages: []string{"laptop is now 203.0.113.1"},
+		},
+		{
+			name: "held back changes are sent coalesced after the interval",
+			runs: [][]hook.Event{
+				{change("laptop", "", "203.0.113.1", 0)},
+				{change("laptop", "203.0.113.1", "203.0.113.2", 1)},
+				{change("laptop", "203.0.113.2", "203.0.113.3", 2)},
+				nil,
+			},
+			statePath:        true,
+			now:              start.Add(12 * time.Minute),
+			expectedMessages: []string{"laptop is now 203.0.113.1", "laptop is now 203.0.113.3 (was 203.0.113.1)"},
+		},
+		{
+			name: "held back changes are not sent before the interval",
+			runs: [][]hook.Event{
+				{change("laptop", "", "203.0.113.1", 0)},
+				{change("laptop", "203.0.113.1", "203.0.113.2", 1)},
+				nil,
+			},
+			now:              start.Add(5 * time.Minute),
+			expectedMessages: []string{"laptop is now 203.0.113.1"},
+		},
+		{
+			name: "held back changes are coalesced into the next change",
+			runs: [][]hook.Event{
+				{change("laptop", "", "203.0.113.1", 0)},
+				{change("laptop", "203.0.113.1", "203.0.113.2", 1)},
+				{change("laptop", "203.0.113.2", "203.0.113.3", 12)},
+			},
+			expectedMessages: []string{"laptop is now 203.0.113.1", "laptop is now 203.0.113.3 (was 203.0.113.1)"},
+		},
+		{
+			name: "host back at the notified IP",
+			runs: [][]hook.Event{
+				{change("laptop", "", "203.0.113.1", 0)},
+				{change("laptop", "203.0.113.1", "203.0.113.2", 1)},
+				{change("laptop", "203.0.113.2", "203.0.113.1", 2)},
+				nil,
+			},
+			now:              start.Add(12 * time.Minute),
+			expectedMessages: []string{"laptop is now 203.0.113.1"},
+		},
+		{
+			name:             "IPv6 change of a dual-stack host",
+			runs:             [][]hook.Event{{dualStack("2001:db8::1", "2001:db8::2", 0)}},
+			expectedMessages: []string{"laptop is now 203.0.113.1 and 2001:db8::2 (was 203.0.113.1 and 2001:db8::1)"},
+		},
+		{
+			name: "dual-stack host back at the notified IPv6 address",
+			runs: [][]hook.Event{
+				{hook.NewEvent("laptop", hook.Addresses{}, hook.Addresses{IPv4: "203.0.113.1", IPv6: "2001:db8::1"}, "", "pull", start)},
+				{dualStack("2001:db8::1", "2001:db8::2", 1)},
+				{dualStack("2001:db8::2", "2001:db8::1", 2)},
+				nil,
+			},
+			now:              start.Add(12 * time.Minute),
+			expectedMessages: []string{"laptop is now 203.0.113.1 and 2001:db8::1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &fakeNotifier{}
+			var statePath string
+			if tt.statePath {
+				statePath = filepath.Join(t.TempDir(), "notified.json")
+			}
+			now := func() time.Time { return tt.now }
+			d := &Dispatcher{Notifiers: []Notifier{n}, StatePath: statePath, now: now}
+			for _, events := range tt.runs {
+				if tt.statePath {
+					// A new process for every run, as from cron
+					d = &Dispatcher{Notifiers: []Notifier{n}, StatePath: statePath, now: now}
+				}
+				d.Run(context.Background(), events)
+			}
+			if !slices.Equal(n.messages, tt.expectedMessages) {
+				t.Errorf("expected messages %q but got %q", tt.expectedMessages, n.messages)
+			}
+		})
+	}
+}
+
+func TestDispatcher_Failure(t *testing.T) {
+	failing := &fakeNotifier{err: errors.New("unreachable")}
+	working := &fakeNotifier{}
+	var log bytes.Buffer
+	d := &Dispatcher{Notifiers: []Notifier{failing, working}, Log: &log}
+	d.Run(context.Background(), []hook.Event{{Host: "laptop", NewIP: "203.0.113.1"}})
+	if len(working.messages) != 1 {
+		t.Errorf("expected a failing notifier not to stop the others but got %q", working.messages)
+	}
+	if !strings.Contains(log.String(), "notification to fake failed: unreachable") {
+		t.Errorf("expected the failure to be logged but got: %q", log.String())
+	}
+}
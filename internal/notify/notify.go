@@ -0,0 +1,90 @@
+// Package notify sends a short message, such as "laptop is now 203.0.113.9", through
+// push notification services, chat webhooks or email when a host's IP changes.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/kappapee/piphos/internal/config"
+	"github.com/kappapee/piphos/internal/hook"
+)
+
+// Usage lists the supported notifier URLs for flag help and error messages.
+const Usage = "ntfy://[token@]HOST/TOPIC, gotify://HOST/TOKEN, slack://WEBHOOK, discord://WEBHOOK, " +
+	"telegram://BOT_TOKEN@api.telegram.org/CHAT_ID, smtp[s]://[USER:PASS@]HOST[:PORT]/?from=FROM&to=TO[,TO]"
+
+// Notifier sends messages to a single destination.
+type Notifier interface {
+	// Notify sends message.
+	Notify(ctx context.Context, message string) error
+	// String describes the destination without any credentials, for logs.
+	String() string
+}
+
+// New returns the notifier configured by rawURL, see Usage. The scheme names the service;
+// HTTP-based services are reached with https, unless it is followed by +http, as in
+// ntfy+http://localhost:8080/alerts for a self-hosted instance without TLS.
+func New(rawURL string) (Notifier, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// url.Parse includes the URL, which may contain credentials
+		return nil, fmt.Errorf("invalid notifier URL, expected one of: %s", Usage)
+	}
+	service, transport, found := strings.Cut(u.Scheme, "+")
+	if !found {
+		transport = "https"
+	}
+	if transport != "https" && transport != "http" {
+		return nil, fmt.Errorf("unsupported notifier transport %q, expected https or http", transport)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid %s notifier URL: missing host", service)
+	}
+	switch service {
+	case "ntfy":
+		return newNtfy(u, transport)
+	case "gotify":
+		return newGotify(u, transport)
+	case "slack", "discord":
+		return newChat(u, service, transport)
+	case "telegram":
+		return newTelegram(u, transport)
+	case "smtp", "smtps":
+		if found {
+			return nil, fmt.Errorf("unsupported notifier transport %q for %s", transport, service)
+		}
+		return newSMTP(u, service == "smtps")
+	default:
+		return nil, fmt.Errorf("unknown notifier %q, expected one of: %s", u.Scheme, Usage)
+	}
+}
+
+// Message returns the text announcing e, e.g. "laptop is now 203.0.113.9 (was 203.0.113.4)",
+// naming the addresses of both families of dual-stack hosts.
+// The namespace is prefixed unless it is the default one.
+func Message(e hook.Event) string {
+	var b strings.Builder
+	if e.Namespace != "" && e.Namespace != config.DefaultNamespace {
+		fmt.Fprintf(&b, "[%s] ", e.Namespace)
+	}
+	fmt.Fprintf(&b, "%s is now %s", e.Host, addresses(e.NewIPv4, e.NewIPv6, e.NewIP))
+	if e.OldIP != "" {
+		fmt.Fprintf(&b, " (was %s)", addresses(e.OldIPv4, e.OldIPv6, e.OldIP))
+	}
+	return b.String()
+}
+
+// addresses joins the addresses of both families, falling back to preferred for events
+// without them, such as those held back by earlier releases.
+func addresses(ipv4, ipv6, preferred string) string {
+	switch {
+	case ipv4 != "" && ipv6 != "":
+		return ipv4 + " and " + ipv6
+	case ipv4 != "" || ipv6 != "":
+		return ipv4 + ipv6
+	}
+	return preferred
+}
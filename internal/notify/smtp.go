@@ -0,0 +1,145 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// smtpTimeout bounds a whole delivery, unless the context has an earlier deadline.
+const smtpTimeout = 30 * time.Second
+
+// smtpNotifier emails messages through an SMTP server.
+type smtpNotifier struct {
+	addr     string
+	host     string
+	implicit bool
+	user     *url.Userinfo
+	from     *mail.Address
+	to       []*mail.Address
+	// tlsConfig is used for implicit TLS and STARTTLS, tests replace it.
+	tlsConfig *tls.Config
+}
+
+// newSMTP returns a notifier sending from the from query parameter to the comma-separated
+// addresses in to. With implicit, smtps, the connection uses TLS from the start on port
+// 465; otherwise it is upgraded with STARTTLS when the server offers it, on port 587.
+func newSMTP(u *url.URL, implicit bool) (Notifier, error) {
+	query := u.Query()
+	from, err := mail.ParseAddress(query.Get("from"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp notifier URL: invalid from address %q", query.Get("from"))
+	}
+	to, err := mail.ParseAddressList(query.Get("to"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid smtp notifier URL: invalid to addresses %q", query.Get("to"))
+	}
+	port := u.Port()
+	if port == "" {
+		port = "587"
+		if implicit {
+			port = "465"
+		}
+	}
+	return &smtpNotifier{
+		addr:      net.JoinHostPort(u.Hostname(), port),
+		host:      u.Hostname(),
+		implicit:  implicit,
+		user:      u.User,
+		from:      from,
+		to:        to,
+		tlsConfig: &tls.Config{ServerName: u.Hostname()},
+	}, nil
+}
+
+func (n *smtpNotifier) String() string { return "smtp " + n.addr }
+
+func (n *smtpNotifier) Notify(ctx context.Context, message string) error {
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	var conn net.Conn
+	var err error
+	if n.implicit {
+		dialer := &tls.Dialer{Config: n.tlsConfig}
+		conn, err = dialer.DialContext(ctx, "tcp", n.addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", n.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", n.addr, err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	c, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to greet %s: %w", n.addr, err)
+	}
+	defer c.Close()
+	if err := n.send(c, message); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// send delivers message on the connection of c.
+func (n *smtpNotifier) send(c *smtp.Client, message string) error {
+	if ok, _ := c.Extension("STARTTLS"); ok && !n.implicit {
+		if err := c.StartTLS(n.tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if n.user != nil {
+		password, _ := n.user.Password()
+		// PlainAuth refuses to send the password without TLS, except to localhost
+		if err := c.Auth(smtp.PlainAuth("", n.user.Username(), password, n.host)); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+	if err := c.Mail(n.from.Address); err != nil {
+		return fmt.Errorf("failed to set sender: %w", err)
+	}
+	for _, address := range n.to {
+		if err := c.Rcpt(address.Address); err != nil {
+			return fmt.Errorf("failed to add recipient %s: %w", address.Address, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("failed to start message: %w", err)
+	}
+	if _, err := w.Write(n.compose(message, time.Now())); err != nil {
+		w.Close()
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	return nil
+}
+
+// compose returns the email carrying message, with message as the subject and body.
+func (n *smtpNotifier) compose(message string, now time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.from.String())
+	to := make([]string, len(n.to))
+	for i, address := range n.to {
+		to[i] = address.String()
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", title+": "+message))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(message + "\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+// smtpStandIn accepts a single delivery and records the commands and the message it received.
+type smtpStandIn struct {
+	listener net.Listener
+	commands []string
+	data     string
+	done     chan struct{}
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &smtpStandIn{listener: l, done: make(chan struct{})}
+	t.Cleanup(func() { l.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	defer close(s.done)
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.commands = append(s.commands, line)
+		verb, _, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			fmt.Fprint(conn, "250-localhost\r\n250 AUTH PLAIN\r\n")
+		case "AUTH":
+			fmt.Fprint(conn, "235 2.7.0 Authentication successful\r\n")
+		case "DATA":
+			fmt.Fprint(conn, "354 End data with <CR><LF>.<CR><LF>\r\n")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				data.WriteString(line)
+			}
+			s.data = data.String()
+			fmt.Fprint(conn, "250 OK\r\n")
+		case "QUIT":
+			fmt.Fprint(conn, "221 Bye\r\n")
+			return
+		default:
+			fmt.Fprint(conn, "250 OK\r\n")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	s := newSMTPStandIn(t)
+	n, err := New("smtp://piphos:secret@" + s.listener.Addr().String() + "/?from=Piphos+<piphos@example.com>&to=me@example.com,sib@example.com")
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if err := n.Notify(context.Background(), "laptop is now 203.0.113.9"); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	<-s.done
+	expectedCommands := []string{
+		"AUTH PLAIN AHBpcGhvcwBzZWNyZXQ=",
+		"MAIL FROM:<piphos@example.com>",
+		"RCPT TO:<me@example.com>",
+		"RCPT TO:<sib@example.com>",
+		"DATA",
+		"QUIT",
+	}
+	if got := strings.Join(s.commands[1:], "\n"); !strings.HasPrefix(got, strings.Join(expectedCommands, "\n")) {
+		t.Errorf("expected commands %q but got %q", expectedCommands, s.commands)
+	}
+	for _, expected := range []string{
+		"From: \"Piphos\" <piphos@example.com>\r\n",
+		"To: <me@example.com>, <sib@example.com>\r\n",
+		"Subject: piphos: laptop is now 203.0.113.9\r\n",
+		"\r\nlaptop is now 203.0.113.9\r\n",
+	} {
+		if !strings.Contains(s.data, expected) {
+			t.Errorf("expected %q in message %q", expected, s.data)
+		}
+	}
+}
+
+func TestSMTPNotifier_Unreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	n, err := New("smtp://" + addr + "/?from=piphos@example.com&to=me@example.com")
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if err := n.Notify(context.Background(), "test"); err == nil {
+		t.Error("expected error but got nil")
+	}
+}
@@ -0,0 +1,137 @@
+// Package output writes command results in the format selected with the -o flag:
+// an aligned table (the default), JSON, YAML, CSV, or a Go text/template.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// Supported format names.
+const (
+	Table    = "table"
+	JSON     = "json"
+	YAML     = "yaml"
+	CSV      = "csv"
+	Template = "template"
+)
+
+// Usage describes the accepted -o values.
+const Usage = "output format: table, json, yaml, csv or template=TEMPLATE"
+
+// Format is an output format as selected with -o. It implements flag.Value.
+// The zero value writes tables.
+type Format struct {
+	// Name is one of the supported format names.
+	Name string
+	// Template is the Go text/template source for the template format.
+	Template string
+}
+
+// Parse parses an -o value such as "json" or "template={{.Host}}".
+func Parse(value string) (Format, error) {
+	name, text, hasText := strings.Cut(value, "=")
+	switch name {
+	case Table, JSON, YAML, CSV:
+		if hasText {
+			return Format{}, fmt.Errorf("output format %s takes no argument", name)
+		}
+		return Format{Name: name}, nil
+	case Template:
+		if text == "" {
+			return Format{}, fmt.Errorf("output format template needs a template, e.g. template='{{.Host}}'")
+		}
+		if _, err := template.New("output").Parse(text); err != nil {
+			return Format{}, fmt.Errorf("failed to parse template: %w", err)
+		}
+		return Format{Name: name, Template: text}, nil
+	default:
+		return Format{}, fmt.Errorf("unknown output format: %s", value)
+	}
+}
+
+func (f *Format) String() string {
+	if f.Name == "" {
+		return Table
+	}
+	if f.Name == Template {
+		return Template + "=" + f.Template
+	}
+	return f.Name
+}
+
+func (f *Format) Set(value string) error {
+	parsed, err := Parse(value)
+	if err != nil {
+		return err
+	}
+	*f = parsed
+	return nil
+}
+
+// Result is a command result in both of the forms the formats need.
+type Result struct {
+	// Value is written by the json, yaml and template formats. Templates see its Go fields.
+	Value any
+	// Header and Rows are written by the table and csv formats.
+	Header []string
+	Rows   [][]string
+}
+
+// Write writes r to w in format f.
+func Write(w io.Writer, f Format, r Result) error {
+	switch f.Name {
+	case "", Table:
+		return writeTable(w, r)
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(r.Value)
+	case YAML:
+		return writeYAML(w, r.Value)
+	case CSV:
+		cw := csv.NewWriter(w)
+		cw.Write(r.Header)
+		cw.WriteAll(r.Rows)
+		return cw.Error()
+	case Template:
+		tmpl, err := template.New("output").Parse(f.Template)
+		if err != nil {
+			return fmt.Errorf("failed to parse template: %w", err)
+		}
+		if err := tmpl.Execute(w, r.Value); err != nil {
+			return fmt.Errorf("failed to execute template: %w", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format: %s", f.Name)
+	}
+}
+
+// writeTable writes r as columns aligned with spaces, showing empty cells as "-".
+func writeTable(w io.Writer, r Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(r.Header, "\t"))
+	for _, row := range r.Rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = dash(cell)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// dash returns s, or "-" if s is empty, for table cells.
+func dash(s string) string {
+	if strings.TrimSpace(s) == "" {
+		return "-"
+	}
+	return s
+}
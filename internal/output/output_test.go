@@ -0,0 +1,138 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+type host struct {
+	Host      string    `json:"host"`
+	IPv4      string    `json:"ipv4,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Aliases   []string  `json:"aliases,omitempty"`
+	Manual    bool      `json:"manual"`
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name           string
+		value          string
+		expectedFormat Format
+		expectedError  bool
+	}{
+		{name: "table", value: "table", expectedFormat: Format{Name: Table}},
+		{name: "json", value: "json", expectedFormat: Format{Name: JSON}},
+		{name: "template", value: "template={{.Host}}={{.IPv4}}", expectedFormat: Format{Name: Template, Template: "{{.Host}}={{.IPv4}}"}},
+		{name: "template without text", value: "template", expectedError: true},
+		{name: "invalid template", value: "template={{.Host", expectedError: true},
+		{name: "argument to json", value: "json=pretty", expectedError: true},
+		{name: "unknown", value: "xml", expectedError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := Parse(tt.value)
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if f != tt.expectedFormat {
+				t.Errorf("expected %+v but got %+v", tt.expectedFormat, f)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	updated := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	hosts := []host{
+		{Host: "nas", IPv4: "203.0.113.1", UpdatedAt: updated, Aliases: []string{"backup", "yes"}},
+		{Host: "printer", Manual: true},
+	}
+	result := Result{
+		Value:  hosts,
+		Header: []string{"HOST", "IPV4"},
+		Rows:   [][]string{{"nas", "203.0.113.1"}, {"printer", ""}},
+	}
+	tests := []struct {
+		name           string
+		format         Format
+		result         Result
+		expectedOutput string
+	}{
+		{
+			name:           "table",
+			format:         Format{},
+			result:         result,
+			expectedOutput: "HOST     IPV4\nnas      203.0.113.1\nprinter  -\n",
+		},
+		{
+			name:           "csv",
+			format:         Format{Name: CSV},
+			result:         result,
+			expectedOutput: "HOST,IPV4\nnas,203.0.113.1\nprinter,\n",
+		},
+		{
+			name:   "json",
+			format: Format{Name: JSON},
+			result: Result{Value: hosts[1]},
+			expectedOutput: `{
+  "host": "printer",
+  "updated_at": "0001-01-01T00:00:00Z",
+  "manual": true
+}
+`,
+		},
+		{
+			name:   "yaml",
+			format: Format{Name: YAML},
+			result: result,
+			expectedOutput: `- host: nas
+  ipv4: "203.0.113.1"
+  updated_at: "2025-06-01T12:00:00Z"
+  aliases:
+    - backup
+    - "yes"
+  manual: false
+- host: printer
+  updated_at: "0001-01-01T00:00:00Z"
+  manual: true
+`,
+		},
+		{
+			name:           "yaml empty list",
+			format:         Format{Name: YAML},
+			result:         Result{Value: []host{}},
+			expectedOutput: "[]\n",
+		},
+		{
+			name:           "template",
+			format:         Format{Name: Template, Template: "{{range .}}{{.Host}} {{.IPv4}}\n{{end}}"},
+			result:         result,
+			expectedOutput: "nas 203.0.113.1\nprinter \n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, tt.format, tt.result); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if buf.String() != tt.expectedOutput {
+				t.Errorf("expected %q but got %q", tt.expectedOutput, buf.String())
+			}
+		})
+	}
+}
+
+func TestWrite_TemplateError(t *testing.T) {
+	f := Format{Name: Template, Template: "{{.Missing}}"}
+	if err := Write(&bytes.Buffer{}, f, Result{Value: host{}}); err == nil {
+		t.Error("expected error for unknown field but got nil")
+	}
+}
@@ -0,0 +1,155 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// yamlNode is a value decoded from JSON with the order of object keys preserved.
+type yamlNode struct {
+	// scalar is the rendered value of strings, numbers, booleans and null.
+	scalar string
+	// keys is set for objects, values for objects and arrays.
+	keys   []string
+	values []*yamlNode
+	object bool
+	array  bool
+}
+
+// writeYAML writes v as block-style YAML. The value is marshalled to JSON first,
+// so json struct tags and custom marshallers apply as for the json format.
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal output: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	n, err := decodeNode(dec)
+	if err != nil {
+		return fmt.Errorf("failed to convert output to yaml: %w", err)
+	}
+	var b strings.Builder
+	switch {
+	case n.object && len(n.keys) > 0:
+		writeMapping(&b, n, "")
+	case n.array && len(n.values) > 0:
+		writeSequence(&b, n, "")
+	default:
+		b.WriteString(inline(n) + "\n")
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// decodeNode reads the next JSON value from dec.
+func decodeNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		n := &yamlNode{object: t == '{', array: t == '['}
+		for dec.More() {
+			if n.object {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.keys = append(n.keys, key.(string))
+			}
+			value, err := decodeNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.values = append(n.values, value)
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case string:
+		return &yamlNode{scalar: quote(t)}, nil
+	case json.Number:
+		return &yamlNode{scalar: t.String()}, nil
+	case bool:
+		return &yamlNode{scalar: fmt.Sprint(t)}, nil
+	default:
+		return &yamlNode{scalar: "null"}, nil
+	}
+}
+
+// writeMapping writes the keys of an object, one per line at indent.
+func writeMapping(b *strings.Builder, n *yamlNode, indent string) {
+	for i, key := range n.keys {
+		b.WriteString(indent + quote(key) + ":")
+		writeNested(b, n.values[i], indent+"  ")
+	}
+}
+
+// writeSequence writes the elements of an array, one "- " entry each at indent.
+func writeSequence(b *strings.Builder, n *yamlNode, indent string) {
+	for _, v := range n.values {
+		if v.object && len(v.keys) > 0 {
+			// The first key of an object goes on the same line as the dash
+			var item strings.Builder
+			writeMapping(&item, v, indent+"  ")
+			b.WriteString(indent + "- " + strings.TrimPrefix(item.String(), indent+"  "))
+			continue
+		}
+		b.WriteString(indent + "-")
+		writeNested(b, v, indent+"  ")
+	}
+}
+
+// writeNested writes the value following a "key:" or "-" already on the line.
+func writeNested(b *strings.Builder, n *yamlNode, indent string) {
+	switch {
+	case n.object && len(n.keys) > 0:
+		b.WriteString("\n")
+		writeMapping(b, n, indent)
+	case n.array && len(n.values) > 0:
+		b.WriteString("\n")
+		writeSequence(b, n, indent)
+	default:
+		b.WriteString(" " + inline(n) + "\n")
+	}
+}
+
+// inline renders scalars and empty collections.
+func inline(n *yamlNode) string {
+	switch {
+	case n.object:
+		return "{}"
+	case n.array:
+		return "[]"
+	default:
+		return n.scalar
+	}
+}
+
+// quote returns s as a plain YAML scalar if that cannot be mistaken for anything but
+// the string, e.g. a hostname, and double-quoted otherwise, e.g. for IPs and timestamps.
+func quote(s string) string {
+	plain := s != "" && isLetter(s[0])
+	for i := 0; plain && i < len(s); i++ {
+		plain = isLetter(s[i]) || s[i] >= '0' && s[i] <= '9' || strings.IndexByte("._-/", s[i]) >= 0
+	}
+	switch strings.ToLower(s) {
+	case "true", "false", "yes", "no", "on", "off", "y", "n", "null":
+		plain = false
+	}
+	if plain {
+		return s
+	}
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
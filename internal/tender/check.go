@@ -0,0 +1,62 @@
+package tender
+
+import (
+	"context"
+	"time"
+)
+
+// Permission is the outcome of probing whether a tender operation is allowed.
+type Permission int
+
+const (
+	// PermissionUnknown means the permission could not be determined without side effects.
+	PermissionUnknown Permission = iota
+	// PermissionGranted means the operation is allowed.
+	PermissionGranted
+	// PermissionDenied means the operation is refused.
+	PermissionDenied
+)
+
+// String returns a human readable form of the permission.
+func (p Permission) String() string {
+	switch p {
+	case PermissionGranted:
+		return "ok"
+	case PermissionDenied:
+		return "denied"
+	default:
+		return "unknown"
+	}
+}
+
+// AuthStatus describes the credentials a tender uses and what they allow.
+type AuthStatus struct {
+	// Identity is the account the credentials belong to.
+	Identity string
+	// TokenKind is the kind of token in use, one of the validate.Token* constants.
+	TokenKind string
+	// Source describes where the credentials were found.
+	Source string
+	// Scopes lists the OAuth scopes of classic tokens, nil for other kinds.
+	Scopes []string
+	// ExpiresAt is when the credentials expire, zero if they do not expire or it is unknown.
+	ExpiresAt time.Time
+	// Read and Write tell whether pull and push will work.
+	Read  Permission
+	Write Permission
+	// Hints explains denied or unknown permissions.
+	Hints []string
+}
+
+// Ready reports whether pull and push are expected to work.
+// Unknown permissions are not treated as failures.
+func (s *AuthStatus) Ready() bool {
+	return s.Read != PermissionDenied && s.Write != PermissionDenied
+}
+
+// Checker is implemented by tenders that can verify their credentials without
+// modifying stored data, so problems surface before a push.
+type Checker interface {
+	// Check inspects the configured credentials and probes read and write access.
+	Check(ctx context.Context) (*AuthStatus, error)
+}
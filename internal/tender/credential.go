@@ -0,0 +1,225 @@
+package tender
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+const (
+	// githubHost is the gh CLI hosts.yml key holding the github.com credentials.
+	githubHost = "github.com"
+	// keyringService and keyringAccount are the Secret Service attributes used to store the token.
+	keyringService = "piphos"
+	keyringAccount = "github"
+	// keyringTimeout bounds how long the Secret Service lookup may take.
+	keyringTimeout = 5 * time.Second
+)
+
+// credential is a GitHub token together with a description of where it was found.
+// The source is safe to print, the token is not.
+type credential struct {
+	token  string
+	source string
+}
+
+// credentialSource looks up a token in one place.
+// It returns an empty token if the place holds nothing, and an error only if
+// the place is configured but unusable (e.g. an unreadable token file).
+type credentialSource struct {
+	name   string
+	lookup func() (string, error)
+}
+
+// lookupKeyring queries the OS keyring. It is a variable so tests can replace it.
+var lookupKeyring = keyringToken
+
+// githubCredential walks the credential discovery chain and returns the first token found.
+// The order is: PIPHOS_GITHUB_TOKEN, PIPHOS_GITHUB_TOKEN_FILE, GITHUB_TOKEN, GH_TOKEN,
+// the gh CLI hosts.yml and finally the OS keyring.
+func githubCredential() (credential, error) {
+	sources := []credentialSource{
+		{name: "PIPHOS_GITHUB_TOKEN", lookup: envToken("PIPHOS_GITHUB_TOKEN")},
+		{name: "PIPHOS_GITHUB_TOKEN_FILE", lookup: fileToken},
+		{name: "GITHUB_TOKEN", lookup: envToken("GITHUB_TOKEN")},
+		{name: "GH_TOKEN", lookup: envToken("GH_TOKEN")},
+		{name: "gh CLI hosts.yml", lookup: ghHostsToken},
+		{name: "OS keyring", lookup: func() (string, error) { return lookupKeyring() }},
+	}
+	for _, s := range sources {
+		token, err := s.lookup()
+		if err != nil {
+			return credential{}, fmt.Errorf("failed to read token from %s: %w", s.name, err)
+		}
+		if token != "" {
+			if err := validate.Token(token); err != nil {
+				return credential{}, fmt.Errorf("token from %s: %w", s.name, err)
+			}
+			return credential{token: token, source: s.name}, nil
+		}
+	}
+	return credential{}, validate.Token("")
+}
+
+// githubFromEnv creates a GitHub tender authenticating as the GitHub App configured in
+// the environment, or otherwise with the first token found by githubCredential.
+func githubFromEnv(opts Options) (*github, error) {
+	app, err := githubAppFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	if app != nil {
+		opts.logf("using GitHub App %s installation %s", app.appID, app.installationID)
+		gh := newGithubWithAuth(app)
+		gh.source = "GitHub App"
+		return gh, nil
+	}
+	cred, err := githubCredential()
+	if err != nil {
+		return nil, err
+	}
+	opts.logf("using %s GitHub token from %s", validate.TokenKind(cred.token), cred.source)
+	gh := newGithub(cred.token)
+	gh.source = cred.source
+	return gh, nil
+}
+
+// envToken returns a lookup function reading the token from the named environment variable.
+func envToken(name string) func() (string, error) {
+	return func() (string, error) {
+		return strings.TrimSpace(os.Getenv(name)), nil
+	}
+}
+
+// fileToken reads the token from the file named by PIPHOS_GITHUB_TOKEN_FILE.
+// This suits systemd credentials ($CREDENTIALS_DIRECTORY) and Docker secrets (/run/secrets).
+func fileToken() (string, error) {
+	path := os.Getenv("PIPHOS_GITHUB_TOKEN_FILE")
+	if path == "" {
+		return "", nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// ghHostsToken reads the github.com oauth_token from the gh CLI hosts.yml.
+// A missing file is not an error. Recent gh versions keep the token in the
+// keyring instead, in which case the file has no oauth_token and nothing is returned.
+func ghHostsToken() (string, error) {
+	path, err := ghHostsPath()
+	if err != nil {
+		return "", nil
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return parseGhHosts(content, githubHost), nil
+}
+
+// ghHostsPath returns the location of the gh CLI hosts.yml, following gh's own lookup rules.
+func ghHostsPath() (string, error) {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml"), nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml"), nil
+	}
+	if dir := os.Getenv("AppData"); dir != "" {
+		return filepath.Join(dir, "GitHub CLI", "hosts.yml"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gh", "hosts.yml"), nil
+}
+
+// parseGhHosts extracts the oauth_token of host from hosts.yml content.
+// Only the small YAML subset written by gh is understood: a top-level host key
+// followed by indented "key: value" lines. Tokens of nested per-user entries are ignored.
+func parseGhHosts(content []byte, host string) string {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	inHost := false
+	childIndent := -1
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent == 0 {
+			inHost = strings.TrimSuffix(trimmed, ":") == host
+			childIndent = -1
+			continue
+		}
+		if !inHost {
+			continue
+		}
+		if childIndent == -1 {
+			childIndent = indent
+		}
+		if indent != childIndent {
+			continue
+		}
+		key, value, ok := strings.Cut(trimmed, ":")
+		if ok && strings.TrimSpace(key) == "oauth_token" {
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
+
+// keyringToken looks up the token in the OS keyring through the Secret Service D-Bus API.
+// The lookup is delegated to libsecret's secret-tool, so a token stored with
+//
+//	secret-tool store --label=piphos service piphos account github
+//
+// is found. If secret-tool is not installed or holds no such item, nothing is returned.
+// Any other failure, such as an unreachable Secret Service, is returned with the message
+// secret-tool printed.
+func keyringToken() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyringTimeout)
+	defer cancel()
+	cmd := osexec.CommandContext(ctx, "secret-tool", "lookup", "service", keyringService, "account", keyringAccount)
+	out, err := cmd.Output()
+	var exitErr *osexec.ExitError
+	switch {
+	case err == nil:
+		return strings.TrimSpace(string(out)), nil
+	case errors.Is(err, osexec.ErrNotFound):
+		return "", nil
+	case ctx.Err() != nil:
+		return "", fmt.Errorf("secret-tool did not answer within %s", keyringTimeout)
+	case errors.As(err, &exitErr):
+		message := strings.TrimSpace(string(exitErr.Stderr))
+		// secret-tool exits with 1 without a message if no item matches
+		if exitErr.ExitCode() == 1 && message == "" {
+			return "", nil
+		}
+		if message != "" {
+			return "", fmt.Errorf("failed to run secret-tool: %w: %s", err, message)
+		}
+	}
+	return "", fmt.Errorf("failed to run secret-tool: %w", err)
+}
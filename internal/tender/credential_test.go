@@ -0,0 +1,234 @@
+package tender
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// isolateCredentials clears every credential source so tests only see what they set up.
+func isolateCredentials(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"PIPHOS_GITHUB_TOKEN", "PIPHOS_GITHUB_TOKEN_FILE", "GITHUB_TOKEN", "GH_TOKEN", "AppData", "PIPHOS_GITHUB_APP_ID", "PIPHOS_GITHUB_APP_INSTALLATION_ID", "PIPHOS_GITHUB_APP_PRIVATE_KEY_FILE"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("GH_CONFIG_DIR", dir)
+	original := lookupKeyring
+	lookupKeyring = func() (string, error) { return "", nil }
+	t.Cleanup(func() { lookupKeyring = original })
+}
+
+func TestGithubCredential(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		tokenFile      string
+		hostsFile      string
+		keyring        string
+		expectedToken  string
+		expectedSource string
+		expectedError  bool
+	}{
+		{
+			name:           "piphos env wins over everything",
+			env:            map[string]string{"PIPHOS_GITHUB_TOKEN": "piphos-token", "GITHUB_TOKEN": "github-token"},
+			tokenFile:      "file-token",
+			expectedToken:  "piphos-token",
+			expectedSource: "PIPHOS_GITHUB_TOKEN",
+		},
+		{
+			name:           "token file",
+			tokenFile:      "file-token\n",
+			env:            map[string]string{"GH_TOKEN": "gh-token"},
+			expectedToken:  "file-token",
+			expectedSource: "PIPHOS_GITHUB_TOKEN_FILE",
+		},
+		{
+			name:           "GITHUB_TOKEN before GH_TOKEN",
+			env:            map[string]string{"GITHUB_TOKEN": "github-token", "GH_TOKEN": "gh-token"},
+			expectedToken:  "github-token",
+			expectedSource: "GITHUB_TOKEN",
+		},
+		{
+			name:           "GH_TOKEN",
+			env:            map[string]string{"GH_TOKEN": "gh-token"},
+			expectedToken:  "gh-token",
+			expectedSource: "GH_TOKEN",
+		},
+		{
+			name:           "gh hosts.yml",
+			hostsFile:      "github.com:\n    user: octocat\n    oauth_token: gho_hosts\n    git_protocol: https\n",
+			keyring:        "keyring-token",
+			expectedToken:  "gho_hosts",
+			expectedSource: "gh CLI hosts.yml",
+		},
+		{
+			name:           "keyring",
+			keyring:        "keyring-token",
+			expectedToken:  "keyring-token",
+			expectedSource: "OS keyring",
+		},
+		{
+			name:          "empty token file",
+			tokenFile:     "  \n",
+			expectedError: true,
+		},
+		{
+			name:          "nothing configured",
+			expectedError: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			isolateCredentials(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if tt.tokenFile != "" {
+				path := filepath.Join(t.TempDir(), "token")
+				if err := os.WriteFile(path, []byte(tt.tokenFile), 0o600); err != nil {
+					t.Fatalf("failed to write token file: %v", err)
+				}
+				t.Setenv("PIPHOS_GITHUB_TOKEN_FILE", path)
+			}
+			if tt.hostsFile != "" {
+				if err := os.WriteFile(filepath.Join(os.Getenv("GH_CONFIG_DIR"), "hosts.yml"), []byte(tt.hostsFile), 0o600); err != nil {
+					t.Fatalf("failed to write hosts file: %v", err)
+				}
+			}
+			lookupKeyring = func() (string, error) { return tt.keyring, nil }
+			cred, err := githubCredential()
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if cred.token != tt.expectedToken {
+				t.Errorf("expected token %s but got %s", tt.expectedToken, cred.token)
+			}
+			if cred.source != tt.expectedSource {
+				t.Errorf("expected source %s but got %s", tt.expectedSource, cred.source)
+			}
+		})
+	}
+}
+
+func TestGithubCredential_MissingTokenFile(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv("PIPHOS_GITHUB_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+	_, err := githubCredential()
+	if err == nil {
+		t.Fatal("expected error for missing token file but got nil")
+	}
+	if !strings.Contains(err.Error(), "PIPHOS_GITHUB_TOKEN_FILE") {
+		t.Errorf("expected error to name the source but got: %v", err)
+	}
+}
+
+func TestParseGhHosts(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedToken string
+	}{
+		{
+			name:          "single host",
+			content:       "github.com:\n    oauth_token: gho_abc\n    user: octocat\n",
+			expectedToken: "gho_abc",
+		},
+		{
+			name:          "quoted token",
+			content:       "github.com:\n  oauth_token: \"gho_abc\"\n",
+			expectedToken: "gho_abc",
+		},
+		{
+			name:          "other host first",
+			content:       "ghe.example.com:\n    oauth_token: gho_enterprise\ngithub.com:\n    oauth_token: gho_abc\n",
+			expectedToken: "gho_abc",
+		},
+		{
+			name:          "nested user token ignored",
+			content:       "github.com:\n    user: octocat\n    users:\n        octocat:\n            oauth_token: gho_nested\n",
+			expectedToken: "",
+		},
+		{
+			name:          "token stored in keyring",
+			content:       "github.com:\n    user: octocat\n    git_protocol: ssh\n",
+			expectedToken: "",
+		},
+		{
+			name:          "empty file",
+			content:       "",
+			expectedToken: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := parseGhHosts([]byte(tt.content), githubHost)
+			if token != tt.expectedToken {
+				t.Errorf("expected token %q but got %q", tt.expectedToken, token)
+			}
+		})
+	}
+}
+
+func TestKeyringToken(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake secret-tool is a shell script")
+	}
+	tests := []struct {
+		name          string
+		script        string
+		expectedToken string
+		expectedError string
+	}{
+		{
+			name: "not installed",
+		},
+		{
+			name:          "token stored",
+			script:        "echo ghp_keyring",
+			expectedToken: "ghp_keyring",
+		},
+		{
+			name:   "no such item",
+			script: "exit 1",
+		},
+		{
+			name:          "secret service unavailable",
+			script:        "echo 'Cannot autolaunch D-Bus without X11 $DISPLAY' >&2; exit 1",
+			expectedError: "Cannot autolaunch D-Bus",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.script != "" {
+				if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte("#!/bin/sh\n"+tt.script+"\n"), 0o700); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("PATH", dir)
+			token, err := keyringToken()
+			if tt.expectedError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("expected error containing %q but got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if token != tt.expectedToken {
+				t.Errorf("expected token %q but got %q", tt.expectedToken, token)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package tender
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kappapee/piphos/internal/fileutil"
+)
+
+const fileName = "file"
+
+// fileStore keeps the document of a namespace in a local file, see documentName.
+// The directory may be shared, e.g. on a network drive or a synchronised folder.
+type fileStore struct {
+	dir  string
+	name string
+}
+
+// fileFromEnv creates a file tender in the directory named by PIPHOS_FILE_DIR,
+// or piphos in the user's configuration directory.
+func fileFromEnv(opts Options) (*documentTender, error) {
+	dir := os.Getenv("PIPHOS_FILE_DIR")
+	if dir == "" {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to find configuration directory, set PIPHOS_FILE_DIR: %w", err)
+		}
+		dir = filepath.Join(configDir, "piphos")
+	}
+	opts.logf("storing hosts in %s", dir)
+	return &documentTender{store: &fileStore{dir: dir, name: documentName(opts.Namespace)}}, nil
+}
+
+// Locking parameters of fileStore.save.
+const (
+	fileLockRetry = 20 * time.Millisecond
+	// fileLockStale is the age after which a lock file is considered left behind by a
+	// crashed writer and removed; a save holds the lock for milliseconds.
+	fileLockStale = 10 * time.Second
+)
+
+// load returns the document and the SHA-256 of its content as version.
+func (s *fileStore) load(context.Context) ([]byte, string, error) {
+	content, err := os.ReadFile(s.path())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read hosts file: %w", err)
+	}
+	return content, contentVersion(content), nil
+}
+
+// save writes the document while holding a lock file next to it, after checking that the
+// document is still at version.
+func (s *fileStore) save(ctx context.Context, content []byte, version string) error {
+	unlock, err := s.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	current, currentVersion, err := s.load(ctx)
+	if err != nil {
+		return err
+	}
+	if current == nil {
+		currentVersion = ""
+	}
+	if currentVersion != version {
+		return errConflict
+	}
+	return fileutil.WriteAtomic(s.path(), content, 0o600)
+}
+
+// lock creates the lock file of the document, waiting while another writer holds it.
+// Returns a function removing it.
+func (s *fileStore) lock(ctx context.Context) (func(), error) {
+	path := s.path() + ".lock"
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create hosts directory: %w", err)
+	}
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock hosts file: %w", err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > fileLockStale {
+			os.Remove(path)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("failed to lock hosts file: %w", ctx.Err())
+		case <-time.After(fileLockRetry):
+		}
+	}
+}
+
+func (s *fileStore) path() string {
+	return filepath.Join(s.dir, s.name)
+}
+
+// contentVersion returns the SHA-256 of content in hex.
+func contentVersion(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *fileStore) namespaces(context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list hosts files: %w", err)
+	}
+	var namespaces []string
+	for _, entry := range entries {
+		if namespace, ok := documentNamespace(entry.Name()); ok && entry.Type().IsRegular() {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces, nil
+}
@@ -0,0 +1,104 @@
+package tender
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFileTender(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PIPHOS_FILE_DIR", dir)
+	ctx := context.Background()
+	ft, err := New("file", Options{})
+	if err != nil {
+		t.Fatalf("failed to create tender: %v", err)
+	}
+	hosts, err := ft.Pull(ctx)
+	if err != nil || hosts != nil {
+		t.Fatalf("expected no hosts and no error before the first push but got %v, %v", hosts, err)
+	}
+	if err := ft.Push(ctx, "laptop", NewRecord("203.0.113.1")); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	if err := ft.Push(ctx, "server", NewRecord("203.0.113.2")); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	if err := ft.Push(ctx, "Laptop.local", NewRecord("203.0.113.1")); err == nil {
+		t.Error("expected an invalid hostname to be rejected")
+	}
+	if err := ft.Push(ctx, "desktop", Record{IPv4: "203.0.113.4", Aliases: []string{"my pc"}}); err == nil {
+		t.Error("expected an invalid alias to be rejected")
+	}
+	if err := ft.Rename(ctx, "laptop", "Notebook"); err == nil {
+		t.Error("expected an invalid new hostname to be rejected")
+	}
+	if err := ft.Rename(ctx, "laptop", "notebook"); err != nil {
+		t.Fatalf("failed to rename: %v", err)
+	}
+	if err := ft.Delete(ctx, "server"); err != nil {
+		t.Fatalf("failed to delete: %v", err)
+	}
+	if err := ft.Delete(ctx, "server"); !errors.Is(err, ErrHostNotFound) {
+		t.Errorf("expected ErrHostNotFound but got: %v", err)
+	}
+	hosts, err = ft.Pull(ctx)
+	if err != nil {
+		t.Fatalf("failed to pull: %v", err)
+	}
+	if len(hosts) != 1 || hosts["notebook"].IPv4 != "203.0.113.1" {
+		t.Errorf("unexpected hosts: %+v", hosts)
+	}
+	info, err := os.Stat(filepath.Join(dir, "hosts.json"))
+	if err != nil {
+		t.Fatalf("expected hosts.json to exist: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode 0600 but got %v", info.Mode().Perm())
+	}
+	other, err := New("file", Options{Namespace: "homelab"})
+	if err != nil {
+		t.Fatalf("failed to create tender: %v", err)
+	}
+	if err := other.Push(ctx, "nas", NewRecord("203.0.113.3")); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	namespaces, err := other.(Namespacer).Namespaces(ctx)
+	if err != nil {
+		t.Fatalf("failed to list namespaces: %v", err)
+	}
+	if expected := []string{"default", "homelab"}; !slices.Equal(namespaces, expected) {
+		t.Errorf("expected namespaces %v but got %v", expected, namespaces)
+	}
+}
+
+func TestDocumentName(t *testing.T) {
+	tests := []struct {
+		namespace    string
+		expectedName string
+	}{
+		{namespace: "", expectedName: "hosts.json"},
+		{namespace: "default", expectedName: "hosts.json"},
+		{namespace: "homelab", expectedName: "hosts-homelab.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expectedName, func(t *testing.T) {
+			name := documentName(tt.namespace)
+			if name != tt.expectedName {
+				t.Errorf("expected %s but got %s", tt.expectedName, name)
+			}
+			namespace, ok := documentNamespace(name)
+			if !ok || (namespace != tt.namespace && tt.namespace != "") {
+				t.Errorf("expected namespace %q back but got %q, %v", tt.namespace, namespace, ok)
+			}
+		})
+	}
+	for _, name := range []string{"hosts-.json", "hosts.txt", "other.json"} {
+		if _, ok := documentNamespace(name); ok {
+			t.Errorf("expected %s not to be a document", name)
+		}
+	}
+}
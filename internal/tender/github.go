@@ -8,29 +8,49 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/kappapee/piphos/internal/config"
+	"github.com/kappapee/piphos/internal/validate"
 )
 
 const (
-	githubName = "gh"
-	githubURL  = "https://api.github.com/gists"
+	githubName   = "gh"
+	githubAPIURL = "https://api.github.com"
+	githubURL    = githubAPIURL + "/gists"
 )
 
 // github implements the Tender interface using GitHub Gists as storage.
 // It stores hostname-to-IP mappings in a private gist identified by the
-// description "_piphos_" containing a single JSON file.
+// description "_piphos_" containing a single JSON file. Other namespaces use
+// their own gist, see config.Stamp.
 type github struct {
-	baseURL string
-	client  *http.Client
-	headers map[string]string
-	name    string
-	token   string
+	apiURL    string
+	auth      tokenSource
+	baseURL   string
+	client    *http.Client
+	codec     Codec
+	headers   map[string]string
+	heartbeat time.Duration
+	name      string
+	source    string
+	stamp     string
+	verbose   io.Writer
+	warnings  io.Writer
 }
 
 // newGithub creates a GitHub tender with the provided authentication token.
 func newGithub(token string) *github {
+	return newGithubWithAuth(staticToken(token))
+}
+
+// newGithubWithAuth creates a GitHub tender authenticating through the given token source.
+func newGithubWithAuth(auth tokenSource) *github {
 	return &github{
+		apiURL:  githubAPIURL,
+		auth:    auth,
 		baseURL: githubURL,
 		client:  &http.Client{Timeout: config.HTTPClientTimeout},
 		headers: map[string]string{
@@ -39,7 +59,7 @@ func newGithub(token string) *github {
 			"X-GitHub-Api-Version": "2022-11-28",
 		},
 		name:  githubName,
-		token: token,
+		stamp: config.PiphosStamp,
 	}
 }
 
@@ -49,6 +69,7 @@ type gist struct {
 	Description string              `json:"description"`
 	Files       map[string]gistFile `json:"files"`
 	Public      bool                `json:"public"`
+	History     []gistCommit        `json:"history,omitempty"`
 }
 
 // gistFile represents a file within a GitHub Gist.
@@ -58,9 +79,9 @@ type gistFile struct {
 	Truncated bool   `json:"truncated"`
 }
 
-// Pull retrieves all hostname-to-IP mappings from the piphos GitHub Gist.
+// Pull retrieves all host records from the piphos GitHub Gist.
 // Returns an error if the gist doesn't exist or cannot be parsed.
-func (gh *github) Pull(ctx context.Context) (map[string]string, error) {
+func (gh *github) Pull(ctx context.Context) (map[string]Record, error) {
 	result, _, err := gh.readGist(ctx)
 	if err != nil {
 		return nil, err
@@ -68,37 +89,130 @@ func (gh *github) Pull(ctx context.Context) (map[string]string, error) {
 	return result, nil
 }
 
-// Push updates the IP address for the specified hostname in the GitHub Gist.
+// Push stores the record for the specified hostname in the GitHub Gist.
+// The hostname and aliases must be valid host names, see validate.Hostname.
 // If no piphos gist exists, a new private gist is created.
-// If the hostname already has the same IP, no API call is made.
-func (gh *github) Push(ctx context.Context, localHostname, publicIP string) error {
+// If the hostname already has the same addresses, no API call is made unless
+// a heartbeat is configured and the stored last-seen time is older than it.
+func (gh *github) Push(ctx context.Context, localHostname string, record Record) error {
+	if err := validateNames(localHostname, record); err != nil {
+		return err
+	}
 	gistPiphosFileContent, gistPiphosID, err := gh.readGist(ctx)
 	if err != nil {
 		return err
 	}
 	if gistPiphosFileContent == nil {
-		return gh.createGist(ctx, localHostname, publicIP)
+		return gh.createGist(ctx, map[string]Record{localHostname: record})
+	}
+	existing, ok := gistPiphosFileContent[localHostname]
+	checkCollisions(gh.warnings, gistPiphosFileContent, localHostname, existing, ok, record)
+	// Skip update if IP hasn't changed and no heartbeat is due
+	record, changed := merge(existing, ok, record, gh.heartbeat)
+	if !changed {
+		return nil
+	}
+	gistPiphosFileContent[localHostname] = record
+	_, err = gh.updateGist(ctx, gistPiphosID, gistPiphosFileContent)
+	return err
+}
+
+// Replace writes hosts as the complete content of the piphos gist, creating it if needed.
+func (gh *github) Replace(ctx context.Context, hosts map[string]Record) error {
+	gistPiphosID, err := gh.findGist(ctx)
+	if err != nil {
+		return err
+	}
+	if gistPiphosID == "" {
+		return gh.createGist(ctx, hosts)
+	}
+	_, err = gh.updateGist(ctx, gistPiphosID, hosts)
+	return err
+}
+
+// Reseal rewrites the piphos gist with its current records, encoding them with the current codec.
+func (gh *github) Reseal(ctx context.Context) error {
+	gistPiphosFileContent, gistPiphosID, err := gh.readGist(ctx)
+	if err != nil {
+		return err
 	}
-	// Skip update if IP hasn't changed
-	if gistPiphosFileContent[localHostname] == publicIP {
+	if gistPiphosFileContent == nil {
 		return nil
 	}
-	return gh.updateGist(ctx, gistPiphosID, gistPiphosFileContent, localHostname, publicIP)
+	_, err = gh.updateGist(ctx, gistPiphosID, gistPiphosFileContent)
+	return err
 }
 
-// createGist creates a new private GitHub Gist with the initial hostname-to-IP mapping.
-func (gh *github) createGist(ctx context.Context, localHostname, publicIP string) error {
-	gistPiphosContent := map[string]string{localHostname: publicIP}
-	content, err := json.Marshal(gistPiphosContent)
+// checkCollisions warns on warnings if record is pushed under a hostname last pushed by a
+// different machine, or claims an alias that already belongs to another host.
+func checkCollisions(warnings io.Writer, hosts map[string]Record, hostname string, existing Record, ok bool, record Record) {
+	if ok && existing.MachineID != "" && record.MachineID != "" && existing.MachineID != record.MachineID {
+		warnf(warnings, "host %s was last pushed by a different machine, give this machine a unique name with -name", hostname)
+	}
+	for _, alias := range record.Aliases {
+		if owner, _, found := Resolve(hosts, alias); found && owner != hostname {
+			warnf(warnings, "alias %s already belongs to host %s", alias, owner)
+		}
+	}
+}
+
+// warnf writes a warning to warnings unless it is nil.
+func warnf(warnings io.Writer, format string, args ...any) {
+	if warnings != nil {
+		fmt.Fprintf(warnings, "warning: "+format+"\n", args...)
+	}
+}
+
+// Delete removes the record of the specified hostname or alias from the GitHub Gist.
+func (gh *github) Delete(ctx context.Context, name string) error {
+	gistPiphosFileContent, gistPiphosID, err := gh.readGist(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to marshal content: %w", err)
+		return err
+	}
+	hostname, _, ok := Resolve(gistPiphosFileContent, name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrHostNotFound, name)
+	}
+	delete(gistPiphosFileContent, hostname)
+	_, err = gh.updateGist(ctx, gistPiphosID, gistPiphosFileContent)
+	return err
+}
+
+// Rename moves the record of the specified hostname or alias to newHostname in the GitHub Gist.
+func (gh *github) Rename(ctx context.Context, oldName, newHostname string) error {
+	if err := validate.Hostname(newHostname); err != nil {
+		return err
+	}
+	gistPiphosFileContent, gistPiphosID, err := gh.readGist(ctx)
+	if err != nil {
+		return err
+	}
+	oldHostname, record, ok := Resolve(gistPiphosFileContent, oldName)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrHostNotFound, oldName)
+	}
+	if owner, _, found := Resolve(gistPiphosFileContent, newHostname); found && owner != oldHostname {
+		return fmt.Errorf("%w: %s", ErrHostExists, newHostname)
+	}
+	// Renaming a host to one of its aliases promotes the alias
+	delete(gistPiphosFileContent, oldHostname)
+	gistPiphosFileContent[newHostname] = record.renamed(newHostname)
+	_, err = gh.updateGist(ctx, gistPiphosID, gistPiphosFileContent)
+	return err
+}
+
+// createGist creates a new private GitHub Gist holding the given records.
+func (gh *github) createGist(ctx context.Context, hosts map[string]Record) error {
+	content, err := gh.encode(hosts)
+	if err != nil {
+		return err
 	}
 	gistPayload := gist{
-		Description: config.PiphosStamp,
+		Description: gh.stamp,
 		Public:      false,
 		Files: map[string]gistFile{
-			config.PiphosStamp: {
-				Filename: config.PiphosStamp,
+			gh.stamp: {
+				Filename: gh.stamp,
 				Content:  string(content),
 			},
 		},
@@ -116,22 +230,10 @@ func (gh *github) createGist(ctx context.Context, localHostname, publicIP string
 // readGist finds and retrieves the piphos gist.
 // NOTE: The two API requests are necessary since there is no easier option to search by description and fetch a gist's file content together.
 // Returns nil if no piphos gist exists, which is not considered an error.
-func (gh *github) readGist(ctx context.Context) (map[string]string, string, error) {
-	gistsResponseBody, err := gh.gistRequest(ctx, http.MethodGet, gh.baseURL, http.StatusOK, nil)
+func (gh *github) readGist(ctx context.Context) (map[string]Record, string, error) {
+	gistPiphosID, err := gh.findGist(ctx)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to complete gist request: %w", err)
-	}
-	var gists []gist
-	if err := json.Unmarshal(gistsResponseBody, &gists); err != nil {
-		return nil, "", fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-	// Find the piphos gist by searching for the stamp description
-	var gistPiphosID string
-	for _, g := range gists {
-		if g.Description == config.PiphosStamp {
-			gistPiphosID = g.ID
-			break
-		}
+		return nil, "", err
 	}
 	// No piphos gist exists yet, not an error
 	if gistPiphosID == "" {
@@ -142,70 +244,171 @@ func (gh *github) readGist(ctx context.Context) (map[string]string, string, erro
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to complete gist request: %w", err)
 	}
+	gistPiphosFileContent, err := gh.parseGist(gistPiphosResponseBody)
+	if err != nil {
+		return nil, "", err
+	}
+	return gistPiphosFileContent, gistPiphosID, nil
+}
+
+// parseGist decodes a gist API response and returns the host records stored in its piphos file.
+func (gh *github) parseGist(body []byte) (map[string]Record, error) {
 	var gistPiphos gist
-	if err := json.Unmarshal(gistPiphosResponseBody, &gistPiphos); err != nil {
-		return nil, "", fmt.Errorf("failed to unmarshal response: %w", err)
+	if err := json.Unmarshal(body, &gistPiphos); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
-	gistPiphosFile, ok := gistPiphos.Files[config.PiphosStamp]
+	gistPiphosFile, ok := gistPiphos.Files[gh.stamp]
 	if !ok {
-		return nil, "", fmt.Errorf("gist missing file: %s", config.PiphosStamp)
+		return nil, fmt.Errorf("gist missing file: %s", gh.stamp)
 	}
 	if gistPiphosFile.Truncated {
-		return nil, "", fmt.Errorf("gist file is too large and has been truncated, aborting")
+		return nil, fmt.Errorf("gist file is too large and has been truncated, aborting")
+	}
+	return gh.decode([]byte(gistPiphosFile.Content))
+}
+
+// encode serialises records for storage, passing them through the codec if one is set.
+func (gh *github) encode(hosts map[string]Record) ([]byte, error) {
+	content, err := EncodeHosts(hosts)
+	if err != nil || gh.codec == nil {
+		return content, err
+	}
+	return gh.codec.Encode(content)
+}
+
+// decode parses stored content, passing it through the codec first if one is set.
+func (gh *github) decode(content []byte) (map[string]Record, error) {
+	if gh.codec != nil {
+		decoded, err := gh.codec.Decode(content)
+		if err != nil {
+			return nil, err
+		}
+		content = decoded
+	}
+	return DecodeHosts(content)
+}
+
+// findGist returns the ID of the gist described by the tender's stamp,
+// or an empty string if there is no such gist.
+func (gh *github) findGist(ctx context.Context) (string, error) {
+	gists, err := gh.listGists(ctx)
+	if err != nil {
+		return "", err
+	}
+	// Find the piphos gist by searching for the stamp description
+	for _, g := range gists {
+		if g.Description == gh.stamp {
+			return g.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// listGists returns all gists of the authenticated user, following pagination.
+func (gh *github) listGists(ctx context.Context) ([]gist, error) {
+	var gists []gist
+	URL := gh.baseURL + "?per_page=100"
+	for URL != "" {
+		body, header, err := gh.apiRequest(ctx, http.MethodGet, URL, http.StatusOK, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to complete gist request: %w", err)
+		}
+		var page []gist
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		gists = append(gists, page...)
+		URL = nextPageURL(header.Get("Link"))
+	}
+	return gists, nil
+}
+
+// nextPageURL extracts the rel="next" URL from a GitHub Link header, or returns
+// an empty string on the last page.
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(target), "<>")
+	}
+	return ""
+}
+
+// Namespaces lists the namespaces that have a piphos gist in the account.
+func (gh *github) Namespaces(ctx context.Context) ([]string, error) {
+	gists, err := gh.listGists(ctx)
+	if err != nil {
+		return nil, err
 	}
-	var gistPiphosFileContent map[string]string
-	if err := json.Unmarshal([]byte(gistPiphosFile.Content), &gistPiphosFileContent); err != nil {
-		return nil, "", fmt.Errorf("failed to unmarshal content: %w", err)
+	var namespaces []string
+	for _, g := range gists {
+		if namespace, ok := config.Namespace(g.Description); ok {
+			namespaces = append(namespaces, namespace)
+		}
 	}
-	return gistPiphosFileContent, gistPiphos.ID, nil
+	slices.Sort(namespaces)
+	return slices.Compact(namespaces), nil
 }
 
-// updateGist modifies an existing gist to update the hostname-to-IP mapping.
-func (gh *github) updateGist(ctx context.Context, gistPiphosID string, fileContent map[string]string, localHostname, publicIP string) error {
-	fileContent[localHostname] = publicIP
-	content, err := json.Marshal(fileContent)
+// updateGist replaces the records stored in an existing gist and returns the API response.
+func (gh *github) updateGist(ctx context.Context, gistPiphosID string, hosts map[string]Record) ([]byte, error) {
+	content, err := gh.encode(hosts)
 	if err != nil {
-		return fmt.Errorf("failed to marshal content: %w", err)
+		return nil, err
 	}
 	gistPayload := gist{
-		Description: config.PiphosStamp,
+		Description: gh.stamp,
 		Public:      false,
 		Files: map[string]gistFile{
-			config.PiphosStamp: {
-				Filename: config.PiphosStamp,
+			gh.stamp: {
+				Filename: gh.stamp,
 				Content:  string(content),
 			},
 		},
 	}
 	gistRequestBody, err := json.Marshal(gistPayload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 	URL := fmt.Sprintf("%s/%s", gh.baseURL, gistPiphosID)
-	if _, err := gh.gistRequest(ctx, http.MethodPatch, URL, http.StatusOK, gistRequestBody); err != nil {
-		return fmt.Errorf("failed to complete gist request: %w", err)
+	body, err := gh.gistRequest(ctx, http.MethodPatch, URL, http.StatusOK, gistRequestBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to complete gist request: %w", err)
 	}
-	return nil
+	return body, nil
 }
 
 // gistRequest executes an HTTP request to the GitHub Gist API.
 // It handles authentication, headers, and validates the response status code.
 func (gh *github) gistRequest(ctx context.Context, HTTPMethod, URL string, expectedStatus int, requestBody []byte) ([]byte, error) {
+	data, _, err := gh.apiRequest(ctx, HTTPMethod, URL, expectedStatus, requestBody)
+	return data, err
+}
+
+// apiRequest executes an HTTP request to the GitHub API and returns the response body and headers.
+// It handles authentication, headers, and validates the response status code.
+func (gh *github) apiRequest(ctx context.Context, HTTPMethod, URL string, expectedStatus int, requestBody []byte) ([]byte, http.Header, error) {
 	var requestBodyReader io.Reader
 	if requestBody != nil {
 		requestBodyReader = bytes.NewReader(requestBody)
 	}
 	req, err := http.NewRequestWithContext(ctx, HTTPMethod, URL, requestBodyReader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	for k, v := range gh.headers {
 		req.Header.Set(k, v)
 	}
-	req.Header.Set("Authorization", "Bearer "+gh.token)
+	token, err := gh.auth.token(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
 	resp, err := gh.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get response: %w", err)
+		return nil, nil, fmt.Errorf("failed to get response: %w", err)
 	}
 	defer func() {
 		if err := resp.Body.Close(); err != nil {
@@ -213,12 +416,65 @@ func (gh *github) gistRequest(ctx context.Context, HTTPMethod, URL string, expec
 		}
 	}()
 	if resp.StatusCode != expectedStatus {
-		return nil, fmt.Errorf("unexpected response status: %d", resp.StatusCode)
+		return nil, resp.Header, newStatusError(resp, gh.auth.kind())
 	}
 	limitedBody := io.LimitReader(resp.Body, config.MaxResponseBodySize)
 	data, err := io.ReadAll(limitedBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return data, resp.Header, nil
+}
+
+// statusError reports an unexpected GitHub API response status together with
+// a hint about the likely cause for authentication and permission failures.
+type statusError struct {
+	code int
+	hint string
+}
+
+func (e *statusError) Error() string {
+	if e.hint == "" {
+		return fmt.Sprintf("unexpected response status: %d", e.code)
+	}
+	return fmt.Sprintf("unexpected response status: %d (hint: %s)", e.code, e.hint)
+}
+
+// newStatusError builds a statusError for resp, mapping 401, 403 and 404 to
+// hints specific to the kind of token that was used.
+func newStatusError(resp *http.Response, tokenKind string) *statusError {
+	e := &statusError{code: resp.StatusCode}
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		e.hint = "the token is invalid, expired or revoked"
+		if tokenKind == validate.TokenInstallation {
+			e.hint = "the App JWT or installation token was rejected, check the App ID, installation ID, private key and system clock"
+		}
+	case http.StatusForbidden, http.StatusNotFound:
+		if resp.StatusCode == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			e.hint = "the API rate limit is exhausted, try again later"
+			break
+		}
+		e.hint = gistPermissionHint(tokenKind)
+		if accepted := resp.Header.Get("X-Accepted-GitHub-Permissions"); accepted != "" {
+			e.hint += fmt.Sprintf(" (required permissions: %s)", accepted)
+		}
+	}
+	return e
+}
+
+// gistPermissionHint explains how a token of the given kind obtains gist access.
+func gistPermissionHint(tokenKind string) string {
+	switch tokenKind {
+	case validate.TokenClassic:
+		return "the classic token lacks the gist scope"
+	case validate.TokenFineGrained:
+		return `the fine-grained token lacks the "Gists" account permission, grant it read and write access`
+	case validate.TokenInstallation:
+		return `gists belong to users, so the App needs the "Gists" account permission and installation tokens may be refused; a user access token (ghu_) of the App works`
+	case validate.TokenUserToServer, validate.TokenOAuth:
+		return `the token's user has not granted the "Gists" permission or gist scope`
+	default:
+		return "the token may lack gist permission"
 	}
-	return data, nil
 }
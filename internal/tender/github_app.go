@@ -0,0 +1,204 @@
+package tender
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kappapee/piphos/internal/config"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+const (
+	// githubAppJWTLifetime is how long a signed App JWT is valid; GitHub allows at most 10 minutes.
+	githubAppJWTLifetime = 9 * time.Minute
+	// githubAppClockSkew backdates the JWT issue time to tolerate clock drift.
+	githubAppClockSkew = 60 * time.Second
+	// githubAppRefreshMargin refreshes installation tokens this long before they expire.
+	githubAppRefreshMargin = 5 * time.Minute
+)
+
+// tokenSource supplies the bearer token for GitHub API requests.
+type tokenSource interface {
+	// token returns a bearer token valid for at least the next request.
+	token(ctx context.Context) (string, error)
+	// kind returns the token kind, one of the validate.Token* constants.
+	kind() string
+}
+
+// staticToken is a tokenSource for a fixed personal, OAuth or fine-grained token.
+type staticToken string
+
+func (s staticToken) token(context.Context) (string, error) { return string(s), nil }
+
+func (s staticToken) kind() string { return validate.TokenKind(string(s)) }
+
+// githubApp is a tokenSource authenticating as a GitHub App installation.
+// It signs a short-lived JWT with the App's private key, exchanges it for an
+// installation access token and refreshes that token shortly before it expires.
+type githubApp struct {
+	apiURL         string
+	appID          string
+	installationID string
+	client         *http.Client
+	key            *rsa.PrivateKey
+	now            func() time.Time
+
+	mu        sync.Mutex
+	cached    string
+	expiresAt time.Time
+}
+
+// installationToken is the response of the installation access token endpoint.
+type installationToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// newGithubApp creates a GitHub App token source from a PEM encoded RSA private key.
+func newGithubApp(appID, installationID string, privateKeyPEM []byte) (*githubApp, error) {
+	if err := validate.GithubAppID("GitHub App ID", appID); err != nil {
+		return nil, err
+	}
+	if err := validate.GithubAppID("GitHub App installation ID", installationID); err != nil {
+		return nil, err
+	}
+	key, err := parseRSAPrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &githubApp{
+		apiURL:         githubAPIURL,
+		appID:          appID,
+		installationID: installationID,
+		client:         &http.Client{Timeout: config.HTTPClientTimeout},
+		key:            key,
+		now:            time.Now,
+	}, nil
+}
+
+// githubAppFromEnv builds a GitHub App token source from PIPHOS_GITHUB_APP_ID,
+// PIPHOS_GITHUB_APP_INSTALLATION_ID and PIPHOS_GITHUB_APP_PRIVATE_KEY_FILE.
+// Returns nil if no App is configured.
+func githubAppFromEnv() (*githubApp, error) {
+	appID := os.Getenv("PIPHOS_GITHUB_APP_ID")
+	installationID := os.Getenv("PIPHOS_GITHUB_APP_INSTALLATION_ID")
+	keyFile := os.Getenv("PIPHOS_GITHUB_APP_PRIVATE_KEY_FILE")
+	if appID == "" && installationID == "" && keyFile == "" {
+		return nil, nil
+	}
+	if appID == "" || installationID == "" || keyFile == "" {
+		return nil, fmt.Errorf("incomplete GitHub App configuration: PIPHOS_GITHUB_APP_ID, PIPHOS_GITHUB_APP_INSTALLATION_ID and PIPHOS_GITHUB_APP_PRIVATE_KEY_FILE are all required")
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GitHub App private key: %w", err)
+	}
+	return newGithubApp(appID, installationID, keyPEM)
+}
+
+// parseRSAPrivateKey decodes a PKCS#1 or PKCS#8 PEM encoded RSA private key,
+// as downloaded from the GitHub App settings page.
+func parseRSAPrivateKey(keyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("invalid GitHub App private key: no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GitHub App private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid GitHub App private key: not an RSA key")
+	}
+	return key, nil
+}
+
+func (a *githubApp) kind() string { return validate.TokenInstallation }
+
+// token returns the cached installation token, requesting a new one if it is
+// missing or about to expire.
+func (a *githubApp) token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cached != "" && a.now().Add(githubAppRefreshMargin).Before(a.expiresAt) {
+		return a.cached, nil
+	}
+	jwt, err := a.signJWT()
+	if err != nil {
+		return "", err
+	}
+	URL := fmt.Sprintf("%s/app/installations/%s/access_tokens", a.apiURL, a.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", config.PiphosUserAgent)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get response: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close response body: %v\n", err)
+		}
+	}()
+	if resp.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("failed to get installation token: %w", newStatusError(resp, validate.TokenInstallation))
+	}
+	var it installationToken
+	if err := json.NewDecoder(io.LimitReader(resp.Body, config.MaxResponseBodySize)).Decode(&it); err != nil {
+		return "", fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if it.Token == "" {
+		return "", errors.New("failed to get installation token: empty token in response")
+	}
+	a.cached = it.Token
+	a.expiresAt = it.ExpiresAt
+	return a.cached, nil
+}
+
+// signJWT creates an RS256 signed JWT identifying the App, as required by
+// the installation access token endpoint.
+func (a *githubApp) signJWT() (string, error) {
+	now := a.now()
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JWT header: %w", err)
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-githubAppClockSkew).Unix(),
+		"exp": now.Add(githubAppJWTLifetime).Unix(),
+		"iss": a.appID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal JWT claims: %w", err)
+	}
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign JWT: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
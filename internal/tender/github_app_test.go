@@ -0,0 +1,211 @@
+package tender
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testAppKey generates a small RSA key and returns it with its PKCS#1 PEM encoding.
+func testAppKey(t *testing.T) (*rsa.PrivateKey, []byte) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return key, keyPEM
+}
+
+func TestGithubApp_TokenRefresh(t *testing.T) {
+	key, keyPEM := testAppKey(t)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var tokenCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+		jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(jwt, ".")
+		if len(parts) != 3 {
+			t.Fatalf("expected JWT with 3 parts but got %d", len(parts))
+		}
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			t.Fatalf("failed to decode signature: %v", err)
+		}
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			t.Errorf("JWT signature does not verify: %v", err)
+		}
+		claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			t.Fatalf("failed to decode claims: %v", err)
+		}
+		var claims struct {
+			Iss string `json:"iss"`
+			Iat int64  `json:"iat"`
+			Exp int64  `json:"exp"`
+		}
+		if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+			t.Fatalf("failed to unmarshal claims: %v", err)
+		}
+		if claims.Iss != "7" {
+			t.Errorf("expected issuer 7 but got %s", claims.Iss)
+		}
+		if claims.Exp-claims.Iat > int64((10 * time.Minute).Seconds()) {
+			t.Error("expected JWT lifetime of at most 10 minutes")
+		}
+		n := tokenCalls.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(installationToken{
+			Token:     "ghs_token" + string(rune('0'+n)),
+			ExpiresAt: now.Add(time.Hour),
+		})
+	}))
+	defer server.Close()
+	app, err := newGithubApp("7", "42", keyPEM)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	app.apiURL = server.URL
+	app.now = func() time.Time { return now }
+	ctx := context.Background()
+	first, err := app.token(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	second, err := app.token(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if first != second || tokenCalls.Load() != 1 {
+		t.Errorf("expected cached token to be reused, got %s then %s after %d calls", first, second, tokenCalls.Load())
+	}
+	now = now.Add(58 * time.Minute)
+	third, err := app.token(ctx)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if third == first || tokenCalls.Load() != 2 {
+		t.Errorf("expected token to be refreshed before expiry, got %s after %d calls", third, tokenCalls.Load())
+	}
+}
+
+func TestGithubApp_TokenRejected(t *testing.T) {
+	_, keyPEM := testAppKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	app, err := newGithubApp("7", "42", keyPEM)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	app.apiURL = server.URL
+	_, err = app.token(context.Background())
+	if err == nil {
+		t.Fatal("expected error but got nil")
+	}
+	if !strings.Contains(err.Error(), "private key") {
+		t.Errorf("expected hint about the App credentials but got: %v", err)
+	}
+}
+
+func TestNewGithubApp_Invalid(t *testing.T) {
+	_, keyPEM := testAppKey(t)
+	tests := []struct {
+		name           string
+		appID          string
+		installationID string
+		keyPEM         []byte
+	}{
+		{name: "non-numeric app ID", appID: "app", installationID: "42", keyPEM: keyPEM},
+		{name: "missing installation ID", appID: "7", installationID: "", keyPEM: keyPEM},
+		{name: "not a PEM key", appID: "7", installationID: "42", keyPEM: []byte("not a key")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newGithubApp(tt.appID, tt.installationID, tt.keyPEM); err == nil {
+				t.Error("expected error but got nil")
+			}
+		})
+	}
+}
+
+func TestGithubAppFromEnv(t *testing.T) {
+	_, keyPEM := testAppKey(t)
+	keyFile := filepath.Join(t.TempDir(), "app.pem")
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	t.Setenv("PIPHOS_GITHUB_APP_ID", "")
+	t.Setenv("PIPHOS_GITHUB_APP_INSTALLATION_ID", "")
+	t.Setenv("PIPHOS_GITHUB_APP_PRIVATE_KEY_FILE", "")
+	app, err := githubAppFromEnv()
+	if err != nil || app != nil {
+		t.Errorf("expected no App without configuration, got %v, %v", app, err)
+	}
+	t.Setenv("PIPHOS_GITHUB_APP_ID", "7")
+	if _, err := githubAppFromEnv(); err == nil {
+		t.Error("expected error for incomplete configuration but got nil")
+	}
+	t.Setenv("PIPHOS_GITHUB_APP_INSTALLATION_ID", "42")
+	t.Setenv("PIPHOS_GITHUB_APP_PRIVATE_KEY_FILE", keyFile)
+	app, err = githubAppFromEnv()
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if app.appID != "7" || app.installationID != "42" {
+		t.Errorf("expected App 7 installation 42 but got %s %s", app.appID, app.installationID)
+	}
+}
+
+func TestStatusErrorHints(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		header       map[string]string
+		tokenKind    string
+		expectedHint string
+	}{
+		{name: "unauthorized", status: http.StatusUnauthorized, tokenKind: "classic", expectedHint: "invalid, expired or revoked"},
+		{name: "classic without gist scope", status: http.StatusForbidden, tokenKind: "classic", expectedHint: "gist scope"},
+		{name: "fine-grained not found", status: http.StatusNotFound, tokenKind: "fine-grained", expectedHint: `"Gists" account permission`},
+		{name: "accepted permissions", status: http.StatusForbidden, header: map[string]string{"X-Accepted-GitHub-Permissions": "gists=write"}, tokenKind: "fine-grained", expectedHint: "gists=write"},
+		{name: "rate limited", status: http.StatusForbidden, header: map[string]string{"X-RateLimit-Remaining": "0"}, tokenKind: "classic", expectedHint: "rate limit"},
+		{name: "server error", status: http.StatusInternalServerError, tokenKind: "classic", expectedHint: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+			for k, v := range tt.header {
+				resp.Header.Set(k, v)
+			}
+			err := newStatusError(resp, tt.tokenKind)
+			if !strings.Contains(err.Error(), "unexpected response status") {
+				t.Errorf("expected status error but got: %v", err)
+			}
+			if tt.expectedHint == "" && strings.Contains(err.Error(), "hint") {
+				t.Errorf("expected no hint but got: %v", err)
+			}
+			if !strings.Contains(err.Error(), tt.expectedHint) {
+				t.Errorf("expected hint containing %q but got: %v", tt.expectedHint, err)
+			}
+		})
+	}
+}
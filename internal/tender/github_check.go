@@ -0,0 +1,124 @@
+package tender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// tokenExpirationLayouts are the formats seen in the github-authentication-token-expiration header.
+var tokenExpirationLayouts = []string{
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05 -0700",
+	time.RFC3339,
+}
+
+// githubUser is the subset of the authenticated user response used by Check.
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+// Check reports the identity, token kind, expiry and gist access of the configured credentials.
+// Gist read access is probed by listing gists. Write access is probed with a no-op
+// update of the piphos gist if it exists; otherwise it is derived from the token's
+// scopes where possible and left unknown for fine-grained tokens.
+func (gh *github) Check(ctx context.Context) (*AuthStatus, error) {
+	status := &AuthStatus{TokenKind: gh.auth.kind(), Source: gh.source}
+	if app, ok := gh.auth.(*githubApp); ok {
+		status.Identity = fmt.Sprintf("GitHub App %s installation %s", app.appID, app.installationID)
+		if _, err := app.token(ctx); err != nil {
+			return nil, err
+		}
+		status.ExpiresAt = app.expiresAt
+	} else {
+		body, header, err := gh.apiRequest(ctx, http.MethodGet, gh.apiURL+"/user", http.StatusOK, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to identify token owner: %w", err)
+		}
+		var user githubUser
+		if err := json.Unmarshal(body, &user); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		status.Identity = user.Login
+		status.Scopes = parseScopes(header.Get("X-OAuth-Scopes"))
+		status.ExpiresAt = parseTokenExpiration(header.Get("github-authentication-token-expiration"))
+	}
+	gistPiphosID, err := gh.findGist(ctx)
+	if err != nil {
+		status.Read = PermissionDenied
+		status.Write = PermissionDenied
+		status.Hints = append(status.Hints, fmt.Sprintf("gist read failed: %v", err))
+		return status, nil
+	}
+	status.Read = PermissionGranted
+	switch {
+	case gistPiphosID != "":
+		status.Write, err = gh.probeWrite(ctx, gistPiphosID)
+		if err != nil {
+			status.Hints = append(status.Hints, fmt.Sprintf("gist write failed: %v", err))
+		}
+	case status.TokenKind == validate.TokenClassic:
+		status.Write = PermissionDenied
+		if slices.Contains(status.Scopes, "gist") {
+			status.Write = PermissionGranted
+		} else {
+			status.Hints = append(status.Hints, gistPermissionHint(validate.TokenClassic))
+		}
+	default:
+		status.Hints = append(status.Hints, "gist write cannot be verified until the piphos gist exists, run push once")
+	}
+	return status, nil
+}
+
+// probeWrite tests write access by setting the piphos gist's description to its current value.
+// This changes no content and creates no new gist revision.
+func (gh *github) probeWrite(ctx context.Context, gistPiphosID string) (Permission, error) {
+	requestBody, err := json.Marshal(map[string]string{"description": gh.stamp})
+	if err != nil {
+		return PermissionUnknown, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	URL := fmt.Sprintf("%s/%s", gh.baseURL, gistPiphosID)
+	_, _, err = gh.apiRequest(ctx, http.MethodPatch, URL, http.StatusOK, requestBody)
+	var se *statusError
+	switch {
+	case err == nil:
+		return PermissionGranted, nil
+	case errors.As(err, &se) && (se.code == http.StatusForbidden || se.code == http.StatusNotFound):
+		return PermissionDenied, err
+	default:
+		return PermissionUnknown, err
+	}
+}
+
+// parseScopes splits the X-OAuth-Scopes header. Returns nil if the header is absent,
+// which is the case for every token kind except classic tokens.
+func parseScopes(header string) []string {
+	if header == "" {
+		return nil
+	}
+	var scopes []string
+	for _, s := range strings.Split(header, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// parseTokenExpiration parses the github-authentication-token-expiration header.
+// Returns the zero time if the header is absent or malformed.
+func parseTokenExpiration(header string) time.Time {
+	for _, layout := range tokenExpirationLayouts {
+		if t, err := time.Parse(layout, header); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
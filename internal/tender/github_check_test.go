@@ -0,0 +1,170 @@
+package tender
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/config"
+)
+
+func TestGithubCheck(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		scopes        string
+		expiration    string
+		gists         []gist
+		patchStatus   int
+		expectedRead  Permission
+		expectedWrite Permission
+		expectedReady bool
+	}{
+		{
+			name:          "classic token with gist scope and no gist yet",
+			token:         "ghp_abc",
+			scopes:        "gist, repo",
+			gists:         []gist{},
+			expectedRead:  PermissionGranted,
+			expectedWrite: PermissionGranted,
+			expectedReady: true,
+		},
+		{
+			name:          "classic token without gist scope",
+			token:         "ghp_abc",
+			scopes:        "repo",
+			gists:         []gist{},
+			expectedRead:  PermissionGranted,
+			expectedWrite: PermissionDenied,
+			expectedReady: false,
+		},
+		{
+			name:          "fine-grained token without gist yet",
+			token:         "github_pat_abc",
+			expiration:    "2030-01-02 03:04:05 UTC",
+			gists:         []gist{},
+			expectedRead:  PermissionGranted,
+			expectedWrite: PermissionUnknown,
+			expectedReady: true,
+		},
+		{
+			name:          "fine-grained token with write access",
+			token:         "github_pat_abc",
+			gists:         []gist{{ID: "gist-id", Description: config.PiphosStamp}},
+			patchStatus:   http.StatusOK,
+			expectedRead:  PermissionGranted,
+			expectedWrite: PermissionGranted,
+			expectedReady: true,
+		},
+		{
+			name:          "fine-grained token with read-only access",
+			token:         "github_pat_abc",
+			gists:         []gist{{ID: "gist-id", Description: config.PiphosStamp}},
+			patchStatus:   http.StatusNotFound,
+			expectedRead:  PermissionGranted,
+			expectedWrite: PermissionDenied,
+			expectedReady: false,
+		},
+		{
+			name:          "no gist access",
+			token:         "github_pat_abc",
+			gists:         nil,
+			expectedRead:  PermissionDenied,
+			expectedWrite: PermissionDenied,
+			expectedReady: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/user":
+					if tt.scopes != "" {
+						w.Header().Set("X-OAuth-Scopes", tt.scopes)
+					}
+					if tt.expiration != "" {
+						w.Header().Set("github-authentication-token-expiration", tt.expiration)
+					}
+					w.WriteHeader(http.StatusOK)
+					w.Write([]byte(`{"login": "octocat"}`))
+				case r.Method == http.MethodGet && r.URL.Path == "/gists":
+					if tt.gists == nil {
+						w.WriteHeader(http.StatusForbidden)
+						return
+					}
+					w.WriteHeader(http.StatusOK)
+					json.NewEncoder(w).Encode(tt.gists)
+				case r.Method == http.MethodPatch && r.URL.Path == "/gists/gist-id":
+					var payload map[string]any
+					if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+						t.Errorf("failed to decode request body: %v", err)
+					}
+					if _, ok := payload["files"]; ok {
+						t.Error("write probe must not modify files")
+					}
+					w.WriteHeader(tt.patchStatus)
+				default:
+					t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+				}
+			}))
+			defer server.Close()
+			gh := newGithub(tt.token)
+			gh.apiURL = server.URL
+			gh.baseURL = server.URL + "/gists"
+			status, err := gh.Check(context.Background())
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if status.Identity != "octocat" {
+				t.Errorf("expected identity octocat but got %s", status.Identity)
+			}
+			if status.Read != tt.expectedRead {
+				t.Errorf("expected read %s but got %s", tt.expectedRead, status.Read)
+			}
+			if status.Write != tt.expectedWrite {
+				t.Errorf("expected write %s but got %s", tt.expectedWrite, status.Write)
+			}
+			if status.Ready() != tt.expectedReady {
+				t.Errorf("expected ready %v but got %v", tt.expectedReady, status.Ready())
+			}
+			if tt.expiration != "" && !status.ExpiresAt.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) {
+				t.Errorf("expected expiry to be parsed but got %v", status.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestGithubCheck_InvalidToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	gh := newGithub("ghp_expired")
+	gh.apiURL = server.URL
+	gh.baseURL = server.URL + "/gists"
+	if _, err := gh.Check(context.Background()); err == nil {
+		t.Error("expected error for invalid token but got nil")
+	}
+}
+
+func TestParseTokenExpiration(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected time.Time
+	}{
+		{header: "2030-01-02 03:04:05 UTC", expected: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{header: "2030-01-02 03:04:05 +0000", expected: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{header: "", expected: time.Time{}},
+		{header: "soon", expected: time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := parseTokenExpiration(tt.header); !got.Equal(tt.expected) {
+				t.Errorf("expected %v but got %v", tt.expected, got)
+			}
+		})
+	}
+}
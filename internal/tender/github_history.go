@@ -0,0 +1,78 @@
+package tender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// gistCommit represents an entry of the GitHub Gist commits API response.
+type gistCommit struct {
+	Version     string    `json:"version"`
+	CommittedAt time.Time `json:"committed_at"`
+}
+
+// History walks the piphos gist's commits and fetches each revision's mappings.
+// Commits are listed newest first, so listing stops at the first commit older than since.
+// Revisions whose piphos file cannot be read, e.g. from before a namespace existed, are
+// skipped and reported on the verbose writer, see Options.Verbose.
+// Returns nil if no piphos gist exists.
+func (gh *github) History(ctx context.Context, since time.Time) ([]Revision, error) {
+	gistPiphosID, err := gh.findGist(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if gistPiphosID == "" {
+		return nil, nil
+	}
+	commits, err := gh.listCommits(ctx, gistPiphosID, since)
+	if err != nil {
+		return nil, err
+	}
+	var revisions []Revision
+	for _, c := range commits {
+		URL := fmt.Sprintf("%s/%s/%s", gh.baseURL, gistPiphosID, c.Version)
+		body, err := gh.gistRequest(ctx, http.MethodGet, URL, http.StatusOK, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to complete gist request: %w", err)
+		}
+		hosts, err := gh.parseGist(body)
+		if err != nil {
+			if gh.verbose != nil {
+				fmt.Fprintf(gh.verbose, "skipping revision %s: %v\n", c.Version, err)
+			}
+			continue
+		}
+		revisions = append(revisions, Revision{Version: c.Version, CommittedAt: c.CommittedAt, Hosts: hosts})
+	}
+	slices.Reverse(revisions)
+	return revisions, nil
+}
+
+// listCommits returns the gist's commits, newest first, down to and including
+// the first commit older than since.
+func (gh *github) listCommits(ctx context.Context, gistPiphosID string, since time.Time) ([]gistCommit, error) {
+	var commits []gistCommit
+	URL := fmt.Sprintf("%s/%s/commits?per_page=100", gh.baseURL, gistPiphosID)
+	for URL != "" {
+		body, header, err := gh.apiRequest(ctx, http.MethodGet, URL, http.StatusOK, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to complete gist request: %w", err)
+		}
+		var page []gistCommit
+		if err := json.Unmarshal(body, &page); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		for _, c := range page {
+			commits = append(commits, c)
+			if !since.IsZero() && c.CommittedAt.Before(since) {
+				return commits, nil
+			}
+		}
+		URL = nextPageURL(header.Get("Link"))
+	}
+	return commits, nil
+}
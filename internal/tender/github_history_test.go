@@ -0,0 +1,146 @@
+package tender
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/config"
+)
+
+// historyServer serves a piphos gist with the given revisions, keyed by version and listed newest first.
+func historyServer(t *testing.T, commits []gistCommit, contents map[string]string, fetched map[string]bool) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode([]gist{{ID: "gist-id", Description: config.PiphosStamp}})
+		case "/gist-id/commits":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(commits)
+		default:
+			version := r.URL.Path[len("/gist-id/"):]
+			content, ok := contents[version]
+			if !ok {
+				t.Fatalf("unexpected request path: %s", r.URL.Path)
+			}
+			fetched[version] = true
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(gist{
+				ID: "gist-id",
+				Files: map[string]gistFile{
+					config.PiphosStamp: {Content: content, Filename: config.PiphosStamp},
+				},
+			})
+		}
+	}))
+}
+
+func TestGithubHistory(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	commits := []gistCommit{
+		{Version: "v3", CommittedAt: now.Add(-10 * time.Minute)},
+		{Version: "v2", CommittedAt: now.Add(-2 * time.Hour)},
+		{Version: "v1", CommittedAt: now.Add(-48 * time.Hour)},
+	}
+	contents := map[string]string{
+		"v1": `{"server": "203.0.113.1"}`,
+		"v2": `{"server": "203.0.113.1", "laptop": "198.51.100.7"}`,
+		"v3": `{"server": "203.0.113.2", "laptop": "198.51.100.7"}`,
+	}
+	tests := []struct {
+		name             string
+		since            time.Time
+		expectedVersions []string
+	}{
+		{
+			name:             "complete history",
+			since:            time.Time{},
+			expectedVersions: []string{"v1", "v2", "v3"},
+		},
+		{
+			name:             "since includes one older baseline",
+			since:            now.Add(-time.Hour),
+			expectedVersions: []string{"v2", "v3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetched := map[string]bool{}
+			server := historyServer(t, commits, contents, fetched)
+			defer server.Close()
+			gh := newGithub("test-token")
+			gh.baseURL = server.URL
+			revisions, err := gh.History(context.Background(), tt.since)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if len(revisions) != len(tt.expectedVersions) {
+				t.Fatalf("expected %d revisions but got %d", len(tt.expectedVersions), len(revisions))
+			}
+			for i, v := range tt.expectedVersions {
+				if revisions[i].Version != v {
+					t.Errorf("expected revision %d to be %s but got %s", i, v, revisions[i].Version)
+				}
+			}
+			if len(fetched) != len(tt.expectedVersions) {
+				t.Errorf("expected %d revisions to be fetched but got %d", len(tt.expectedVersions), len(fetched))
+			}
+			last := revisions[len(revisions)-1]
+			if last.Hosts["server"].IP() != "203.0.113.2" {
+				t.Errorf("expected newest server IP 203.0.113.2 but got %s", last.Hosts["server"].IP())
+			}
+		})
+	}
+}
+
+func TestGithubHistory_SkipsUnreadable(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	commits := []gistCommit{
+		{Version: "v2", CommittedAt: now.Add(-10 * time.Minute)},
+		{Version: "v1", CommittedAt: now.Add(-2 * time.Hour)},
+	}
+	contents := map[string]string{
+		"v1": `not json`,
+		"v2": `{"server": "203.0.113.2"}`,
+	}
+	server := historyServer(t, commits, contents, map[string]bool{})
+	defer server.Close()
+	var verbose bytes.Buffer
+	gh := newGithub("test-token")
+	gh.baseURL = server.URL
+	gh.verbose = &verbose
+	revisions, err := gh.History(context.Background(), time.Time{})
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if len(revisions) != 1 || revisions[0].Version != "v2" {
+		t.Errorf("expected only revision v2 but got %+v", revisions)
+	}
+	if !strings.Contains(verbose.String(), "skipping revision v1: ") {
+		t.Errorf("expected the skipped revision to be reported but got: %q", verbose.String())
+	}
+}
+
+func TestGithubHistory_NoGist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+	gh := newGithub("test-token")
+	gh.baseURL = server.URL
+	revisions, err := gh.History(context.Background(), time.Time{})
+	if err != nil {
+		t.Errorf("expected no error but got: %v", err)
+	}
+	if revisions != nil {
+		t.Errorf("expected nil revisions but got: %v", revisions)
+	}
+}
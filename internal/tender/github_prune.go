@@ -0,0 +1,39 @@
+package tender
+
+import (
+	"context"
+	"maps"
+	"slices"
+)
+
+// Prune removes expired hosts from the piphos gist.
+// If a push landed between reading and writing the gist, the hosts are re-evaluated
+// against it, so a host that reported in concurrently is restored rather than lost.
+// Returns nil if no piphos gist exists.
+func (gh *github) Prune(ctx context.Context, expired func(hostname string, record Record) bool, dryRun bool) ([]string, error) {
+	var removed []string
+	err := gh.update(ctx, func(hosts map[string]Record) (map[string]Record, bool, error) {
+		removed = nil
+		if hosts == nil {
+			return nil, false, nil
+		}
+		removed = pruneHosts(hosts, expired)
+		return hosts, !dryRun && len(removed) > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// pruneHosts deletes the hosts for which expired returns true and returns their sorted names.
+func pruneHosts(hosts map[string]Record, expired func(hostname string, record Record) bool) []string {
+	var removed []string
+	for _, hostname := range slices.Sorted(maps.Keys(hosts)) {
+		if expired(hostname, hosts[hostname]) {
+			delete(hosts, hostname)
+			removed = append(removed, hostname)
+		}
+	}
+	return removed
+}
@@ -0,0 +1,157 @@
+package tender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/config"
+)
+
+// pruneServer serves a piphos gist whose current revision is "v1" holding hosts.
+// Each PATCH is recorded in patches. If concurrent is set, the first PATCH reports
+// that revision "v2" holding concurrent was written between the read and the write.
+func pruneServer(t *testing.T, hosts, concurrent map[string]Record, patches *[]map[string]Record) *httptest.Server {
+	t.Helper()
+	gistResponse := func(hosts map[string]Record, versions ...string) gist {
+		content, err := EncodeHosts(hosts)
+		if err != nil {
+			t.Fatalf("failed to marshal test data: %v", err)
+		}
+		g := gist{
+			ID:    "gist-id",
+			Files: map[string]gistFile{config.PiphosStamp: {Content: string(content), Filename: config.PiphosStamp}},
+		}
+		for _, v := range versions {
+			g.History = append(g.History, gistCommit{Version: v})
+		}
+		return g
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/":
+			json.NewEncoder(w).Encode([]gist{{ID: "gist-id", Description: config.PiphosStamp}})
+		case r.Method == http.MethodGet && r.URL.Path == "/gist-id":
+			json.NewEncoder(w).Encode(gistResponse(hosts, "v1"))
+		case r.Method == http.MethodGet && r.URL.Path == "/gist-id/v2":
+			json.NewEncoder(w).Encode(gistResponse(concurrent, "v2"))
+		case r.Method == http.MethodPatch:
+			var payload gist
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("failed to decode request body: %v", err)
+			}
+			written, err := DecodeHosts([]byte(payload.Files[config.PiphosStamp].Content))
+			if err != nil {
+				t.Errorf("failed to decode file content: %v", err)
+			}
+			*patches = append(*patches, written)
+			version := fmt.Sprintf("p%d", len(*patches))
+			parent := "v1"
+			if concurrent != nil {
+				parent = "v2"
+				if len(*patches) > 1 {
+					parent = "p1"
+				}
+			}
+			json.NewEncoder(w).Encode(gistResponse(written, version, parent))
+		default:
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+}
+
+func TestGithubPrune(t *testing.T) {
+	now := time.Now().UTC()
+	hosts := map[string]Record{
+		"live":    {IPv4: "203.0.113.1", SeenAt: now.Add(-time.Hour)},
+		"sold":    {IPv4: "203.0.113.2", SeenAt: now.Add(-90 * 24 * time.Hour)},
+		"laptop":  {IPv4: "203.0.113.3", SeenAt: now.Add(-2 * time.Hour), TTL: Duration(time.Hour)},
+		"unknown": {IPv4: "203.0.113.4"},
+	}
+	revived := map[string]Record{
+		"live":   hosts["live"],
+		"sold":   {IPv4: "198.51.100.2", SeenAt: now},
+		"laptop": hosts["laptop"],
+		"new":    {IPv4: "198.51.100.5", SeenAt: now},
+	}
+	expired := func(_ string, r Record) bool { return r.Expired(now, 30*24*time.Hour) }
+	tests := []struct {
+		name            string
+		dryRun          bool
+		concurrent      map[string]Record
+		expectedRemoved []string
+		expectedPatches int
+		expectedHosts   []string
+	}{
+		{
+			name:            "dry run",
+			dryRun:          true,
+			expectedRemoved: []string{"laptop", "sold"},
+			expectedPatches: 0,
+		},
+		{
+			name:            "prune",
+			expectedRemoved: []string{"laptop", "sold"},
+			expectedPatches: 1,
+			expectedHosts:   []string{"live", "unknown"},
+		},
+		{
+			name:            "concurrent push is kept",
+			concurrent:      revived,
+			expectedRemoved: []string{"laptop"},
+			expectedPatches: 2,
+			expectedHosts:   []string{"live", "new", "sold"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var patches []map[string]Record
+			server := pruneServer(t, hosts, tt.concurrent, &patches)
+			defer server.Close()
+			gh := newGithub("test-token")
+			gh.baseURL = server.URL
+			removed, err := gh.Prune(context.Background(), expired, tt.dryRun)
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if !slices.Equal(removed, tt.expectedRemoved) {
+				t.Errorf("expected removed %v but got %v", tt.expectedRemoved, removed)
+			}
+			if len(patches) != tt.expectedPatches {
+				t.Fatalf("expected %d writes but got %d", tt.expectedPatches, len(patches))
+			}
+			if len(patches) == 0 {
+				return
+			}
+			var written []string
+			for hostname := range patches[len(patches)-1] {
+				written = append(written, hostname)
+			}
+			slices.Sort(written)
+			if !slices.Equal(written, tt.expectedHosts) {
+				t.Errorf("expected stored hosts %v but got %v", tt.expectedHosts, written)
+			}
+		})
+	}
+}
+
+func TestGithubPrune_NoGist(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]gist{})
+	}))
+	defer server.Close()
+	gh := newGithub("test-token")
+	gh.baseURL = server.URL
+	removed, err := gh.Prune(context.Background(), func(string, Record) bool { return true }, false)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if removed != nil {
+		t.Errorf("expected nothing removed but got %v", removed)
+	}
+}
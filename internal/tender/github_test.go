@@ -3,8 +3,11 @@ package tender
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -90,11 +93,11 @@ func TestGithubPull_WithGist(t *testing.T) {
 	if len(result) != 2 {
 		t.Errorf("expected 2 entries but got %d", len(result))
 	}
-	if result["host1"] != "203.0.113.1" {
-		t.Errorf("expected host1 IP to be 203.0.113.1 but got %s", result["host1"])
+	if result["host1"].IP() != "203.0.113.1" {
+		t.Errorf("expected host1 IP to be 203.0.113.1 but got %s", result["host1"].IP())
 	}
-	if result["host2"] != "203.0.113.2" {
-		t.Errorf("expected host2 IP to be 203.0.113.2 but got %s", result["host2"])
+	if result["host2"].IP() != "203.0.113.2" {
+		t.Errorf("expected host2 IP to be 203.0.113.2 but got %s", result["host2"].IP())
 	}
 }
 
@@ -207,12 +210,15 @@ func TestGithubPush_CreateNewGist(t *testing.T) {
 			if !ok {
 				t.Error("expected file not found in payload")
 			}
-			var content map[string]string
+			var content document
 			if err := json.Unmarshal([]byte(file.Content), &content); err != nil {
 				t.Errorf("failed to decode file content: %v", err)
 			}
-			if content[hostname] != ip {
-				t.Errorf("expected IP %s for host %s but got %s", ip, hostname, content[hostname])
+			if content.Schema != SchemaVersion {
+				t.Errorf("expected schema %d but got %d", SchemaVersion, content.Schema)
+			}
+			if content.Hosts[hostname].IPv4 != ip {
+				t.Errorf("expected IP %s for host %s but got %s", ip, hostname, content.Hosts[hostname].IPv4)
 			}
 			w.WriteHeader(http.StatusCreated)
 			json.NewEncoder(w).Encode(payload)
@@ -224,7 +230,7 @@ func TestGithubPush_CreateNewGist(t *testing.T) {
 	gh := newGithub("test-token")
 	gh.baseURL = server.URL
 	ctx := context.Background()
-	err := gh.Push(ctx, hostname, ip)
+	err := gh.Push(ctx, hostname, NewRecord(ip))
 	if err != nil {
 		t.Errorf("expected no error but got: %v", err)
 	}
@@ -274,14 +280,14 @@ func TestGithubPush_UpdateExistingGist(t *testing.T) {
 				t.Errorf("failed to decode request body: %v", err)
 			}
 			file := payload.Files[config.PiphosStamp]
-			var updatedContent map[string]string
-			if err := json.Unmarshal([]byte(file.Content), &updatedContent); err != nil {
+			updatedContent, err := DecodeHosts([]byte(file.Content))
+			if err != nil {
 				t.Errorf("failed to decode file content: %v", err)
 			}
-			if updatedContent[hostname] != newIP {
-				t.Errorf("expected new IP %s for host %s but got %s", newIP, hostname, updatedContent[hostname])
+			if updatedContent[hostname].IP() != newIP {
+				t.Errorf("expected new IP %s for host %s but got %s", newIP, hostname, updatedContent[hostname].IP())
 			}
-			if updatedContent["otherhost"] != "203.0.113.1" {
+			if updatedContent["otherhost"].IP() != "203.0.113.1" {
 				t.Error("expected existing host to remain unchanged")
 			}
 			w.WriteHeader(http.StatusOK)
@@ -294,7 +300,7 @@ func TestGithubPush_UpdateExistingGist(t *testing.T) {
 	gh := newGithub("test-token")
 	gh.baseURL = server.URL
 	ctx := context.Background()
-	if err := gh.Push(ctx, hostname, newIP); err != nil {
+	if err := gh.Push(ctx, hostname, NewRecord(newIP)); err != nil {
 		t.Errorf("expected no error but got: %v", err)
 	}
 }
@@ -349,7 +355,7 @@ func TestGithubPush_SkipUnchangedIP(t *testing.T) {
 	gh := newGithub("test-token")
 	gh.baseURL = server.URL
 	ctx := context.Background()
-	if err := gh.Push(ctx, hostname, sameIP); err != nil {
+	if err := gh.Push(ctx, hostname, NewRecord(sameIP)); err != nil {
 		t.Errorf("expected no error but got: %v", err)
 	}
 	if patchCalled {
@@ -366,7 +372,7 @@ func TestGithubPush_ErrorStatus(t *testing.T) {
 	gh := newGithub("invalid-token")
 	gh.baseURL = server.URL
 	ctx := context.Background()
-	err := gh.Push(ctx, "testhost", "203.0.113.1")
+	err := gh.Push(ctx, "testhost", NewRecord("203.0.113.1"))
 	if err == nil {
 		t.Error("expected error but got nil")
 	}
@@ -470,7 +476,7 @@ func TestGithubCreateGist_Success(t *testing.T) {
 	gh := newGithub("test-token")
 	gh.baseURL = server.URL
 	ctx := context.Background()
-	err := gh.Push(ctx, "testhost", "203.0.113.1")
+	err := gh.Push(ctx, "testhost", NewRecord("203.0.113.1"))
 	if err != nil {
 		t.Errorf("expected no error but got: %v", err)
 	}
@@ -501,3 +507,418 @@ func TestGithubHeaders(t *testing.T) {
 	ctx := context.Background()
 	gh.Pull(ctx)
 }
+
+func TestGithubRequest_AppAuth(t *testing.T) {
+	_, keyPEM := testAppKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/installations/42/access_tokens":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"token": "ghs_installation", "expires_at": "2999-01-01T00:00:00Z"}`))
+		case "/":
+			if auth := r.Header.Get("Authorization"); auth != "Bearer ghs_installation" {
+				t.Errorf("expected installation token but got %s", auth)
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("[]"))
+		default:
+			t.Fatalf("unexpected request path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	app, err := newGithubApp("7", "42", keyPEM)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	app.apiURL = server.URL
+	gh := newGithubWithAuth(app)
+	gh.baseURL = server.URL
+	if _, err := gh.Pull(context.Background()); err != nil {
+		t.Errorf("expected no error but got: %v", err)
+	}
+}
+
+func TestGithubPull_Namespace(t *testing.T) {
+	stamp := config.Stamp("homelab")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			gists := []gist{
+				{ID: "default-id", Description: config.PiphosStamp},
+				{ID: "homelab-id", Description: stamp},
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(gists)
+		case "/homelab-id":
+			gistResponse := gist{
+				ID:          "homelab-id",
+				Description: stamp,
+				Files: map[string]gistFile{
+					stamp: {Content: `{"nas": "203.0.113.9"}`, Filename: stamp},
+				},
+			}
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(gistResponse)
+		default:
+			t.Fatalf("unexpected request path: %s", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	gh := newGithub("test-token")
+	gh.baseURL = server.URL
+	gh.stamp = stamp
+	result, err := gh.Pull(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if len(result) != 1 || result["nas"].IP() != "203.0.113.9" {
+		t.Errorf("expected only the homelab hosts but got: %v", result)
+	}
+}
+
+func TestGithubNamespaces(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var gists []gist
+		if r.URL.Query().Get("page") == "2" {
+			gists = []gist{{ID: "3", Description: config.Stamp("family")}}
+		} else {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/?page=2>; rel="next", <%s/?page=2>; rel="last"`, server.URL, server.URL))
+			gists = []gist{
+				{ID: "1", Description: config.Stamp("homelab")},
+				{ID: "2", Description: "shopping list"},
+				{ID: "4", Description: config.PiphosStamp},
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(gists)
+	}))
+	defer server.Close()
+	gh := newGithub("test-token")
+	gh.baseURL = server.URL
+	namespaces, err := gh.Namespaces(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	expected := []string{"default", "family", "homelab"}
+	if strings.Join(namespaces, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected namespaces %v but got %v", expected, namespaces)
+	}
+}
+
+func TestGithubPush_Heartbeat(t *testing.T) {
+	hostname := "testhost"
+	now := time.Now().UTC().Truncate(time.Second)
+	updated := now.Add(-72 * time.Hour)
+	content, err := EncodeHosts(map[string]Record{
+		hostname: {IPv4: "203.0.113.1", UpdatedAt: updated, SeenAt: now.Add(-7 * time.Hour)},
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal test data: %v", err)
+	}
+	var patched atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode([]gist{{ID: "gist-id", Description: config.PiphosStamp}})
+		case r.Method == http.MethodGet && r.URL.Path == "/gist-id":
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(gist{
+				ID:    "gist-id",
+				Files: map[string]gistFile{config.PiphosStamp: {Content: string(content), Filename: config.PiphosStamp}},
+			})
+		case r.Method == http.MethodPatch:
+			patched.Store(true)
+			var payload gist
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("failed to decode request body: %v", err)
+			}
+			hosts, err := DecodeHosts([]byte(payload.Files[config.PiphosStamp].Content))
+			if err != nil {
+				t.Errorf("failed to decode file content: %v", err)
+			}
+			if !hosts[hostname].SeenAt.Equal(now) {
+				t.Errorf("expected seen time %v but got %v", now, hosts[hostname].SeenAt)
+			}
+			if !hosts[hostname].UpdatedAt.Equal(updated) {
+				t.Errorf("expected updated time to stay %v but got %v", updated, hosts[hostname].UpdatedAt)
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	gh := newGithub("test-token")
+	gh.baseURL = server.URL
+	gh.heartbeat = 6 * time.Hour
+	record := Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now}
+	if err := gh.Push(context.Background(), hostname, record); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !patched.Load() {
+		t.Error("expected heartbeat to write the gist")
+	}
+}
+
+// editServer serves a piphos gist holding hosts and records the hosts written by a PATCH.
+func editServer(t *testing.T, hosts map[string]Record, written *map[string]Record) *httptest.Server {
+	t.Helper()
+	content, err := EncodeHosts(hosts)
+	if err != nil {
+		t.Fatalf("failed to marshal test data: %v", err)
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/":
+			json.NewEncoder(w).Encode([]gist{{ID: "gist-id", Description: config.PiphosStamp}})
+		case r.Method == http.MethodGet && r.URL.Path == "/gist-id":
+			json.NewEncoder(w).Encode(gist{
+				ID:    "gist-id",
+				Files: map[string]gistFile{config.PiphosStamp: {Content: string(content), Filename: config.PiphosStamp}},
+			})
+		case r.Method == http.MethodPatch:
+			var payload gist
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("failed to decode request body: %v", err)
+			}
+			*written, err = DecodeHosts([]byte(payload.Files[config.PiphosStamp].Content))
+			if err != nil {
+				t.Errorf("failed to decode file content: %v", err)
+			}
+			w.WriteHeader(http.StatusOK)
+		default:
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+}
+
+func TestGithubDelete(t *testing.T) {
+	hosts := map[string]Record{"laptop": NewRecord("203.0.113.1"), "server": {IPv4: "203.0.113.2", Aliases: []string{"nas"}}}
+	tests := []struct {
+		name          string
+		hostname      string
+		expectedGone  string
+		expectedError error
+	}{
+		{name: "existing host", hostname: "laptop", expectedGone: "laptop"},
+		{name: "alias", hostname: "nas", expectedGone: "server"},
+		{name: "missing host", hostname: "desktop", expectedError: ErrHostNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written map[string]Record
+			server := editServer(t, hosts, &written)
+			defer server.Close()
+			gh := newGithub("test-token")
+			gh.baseURL = server.URL
+			err := gh.Delete(context.Background(), tt.hostname)
+			if tt.expectedError != nil {
+				if !errors.Is(err, tt.expectedError) {
+					t.Errorf("expected %v but got: %v", tt.expectedError, err)
+				}
+				if written != nil {
+					t.Error("expected no write")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if _, ok := written[tt.expectedGone]; ok {
+				t.Errorf("expected %s to be deleted", tt.expectedGone)
+			}
+			if len(written) != len(hosts)-1 {
+				t.Errorf("expected %d hosts to remain but got %d", len(hosts)-1, len(written))
+			}
+		})
+	}
+}
+
+func TestGithubReplace(t *testing.T) {
+	hosts := map[string]Record{"laptop": NewRecord("203.0.113.1"), "server": NewRecord("203.0.113.2")}
+	var written map[string]Record
+	server := editServer(t, hosts, &written)
+	defer server.Close()
+	gh := newGithub("test-token")
+	gh.baseURL = server.URL
+	replacement := map[string]Record{"nas": {IPv4: "203.0.113.3", SeenAt: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}}
+	if err := gh.Replace(context.Background(), replacement); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if len(written) != 1 || !written["nas"].SeenAt.Equal(replacement["nas"].SeenAt) {
+		t.Errorf("expected the records to be written as given but got %+v", written)
+	}
+}
+
+func TestGithubRename(t *testing.T) {
+	hosts := map[string]Record{"laptpo": NewRecord("203.0.113.1"), "server": {IPv4: "203.0.113.2", Aliases: []string{"nas", "backup"}, PublicKey: "key", Signature: "sig"}}
+	tests := []struct {
+		name            string
+		oldHostname     string
+		newHostname     string
+		expectedAliases []string
+		expectedError   error
+	}{
+		{name: "rename", oldHostname: "laptpo", newHostname: "laptop"},
+		{name: "promote alias", oldHostname: "server", newHostname: "nas", expectedAliases: []string{"backup"}},
+		{name: "rename by alias", oldHostname: "backup", newHostname: "storage", expectedAliases: []string{"nas", "backup"}},
+		{name: "missing host", oldHostname: "desktop", newHostname: "laptop", expectedError: ErrHostNotFound},
+		{name: "target taken", oldHostname: "laptpo", newHostname: "server", expectedError: ErrHostExists},
+		{name: "target is alias of another host", oldHostname: "laptpo", newHostname: "nas", expectedError: ErrHostExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written map[string]Record
+			server := editServer(t, hosts, &written)
+			defer server.Close()
+			gh := newGithub("test-token")
+			gh.baseURL = server.URL
+			err := gh.Rename(context.Background(), tt.oldHostname, tt.newHostname)
+			if tt.expectedError != nil {
+				if !errors.Is(err, tt.expectedError) {
+					t.Errorf("expected %v but got: %v", tt.expectedError, err)
+				}
+				if written != nil {
+					t.Error("expected no write")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			oldHostname, record, _ := Resolve(hosts, tt.oldHostname)
+			if _, ok := written[oldHostname]; ok {
+				t.Errorf("expected %s to be gone", oldHostname)
+			}
+			if written[tt.newHostname].IP() != record.IP() {
+				t.Errorf("expected %s to hold %s but got %s", tt.newHostname, record.IP(), written[tt.newHostname].IP())
+			}
+			if !slices.Equal(written[tt.newHostname].Aliases, tt.expectedAliases) {
+				t.Errorf("expected aliases %v but got %v", tt.expectedAliases, written[tt.newHostname].Aliases)
+			}
+			if written[tt.newHostname].Signature != "" || written[tt.newHostname].PublicKey != "" {
+				t.Errorf("expected the signature to be removed but got %+v", written[tt.newHostname])
+			}
+		})
+	}
+}
+
+func TestGithubPush_Collisions(t *testing.T) {
+	hosts := map[string]Record{
+		"raspberrypi": {IPv4: "203.0.113.1", MachineID: "machine-a"},
+		"server":      {IPv4: "203.0.113.2", Aliases: []string{"nas"}},
+	}
+	tests := []struct {
+		name            string
+		hostname        string
+		record          Record
+		expectedWarning string
+	}{
+		{
+			name:     "same machine",
+			hostname: "raspberrypi",
+			record:   Record{IPv4: "203.0.113.1", MachineID: "machine-a"},
+		},
+		{
+			name:            "different machine",
+			hostname:        "raspberrypi",
+			record:          Record{IPv4: "198.51.100.1", MachineID: "machine-b"},
+			expectedWarning: "host raspberrypi was last pushed by a different machine",
+		},
+		{
+			name:     "unknown machine",
+			hostname: "raspberrypi",
+			record:   Record{IPv4: "198.51.100.1"},
+		},
+		{
+			name:            "alias of another host",
+			hostname:        "laptop",
+			record:          Record{IPv4: "198.51.100.1", Aliases: []string{"nas"}},
+			expectedWarning: "alias nas already belongs to host server",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var written map[string]Record
+			server := editServer(t, hosts, &written)
+			defer server.Close()
+			var warnings strings.Builder
+			gh := newGithub("test-token")
+			gh.baseURL = server.URL
+			gh.warnings = &warnings
+			if err := gh.Push(context.Background(), tt.hostname, tt.record); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if tt.expectedWarning == "" && warnings.Len() > 0 {
+				t.Errorf("expected no warning but got: %q", warnings.String())
+			}
+			if !strings.Contains(warnings.String(), tt.expectedWarning) {
+				t.Errorf("expected warning %q but got: %q", tt.expectedWarning, warnings.String())
+			}
+		})
+	}
+}
+
+// reverseCodec is a Codec reversing the stored content, standing in for encryption.
+type reverseCodec struct{}
+
+func (reverseCodec) Encode(content []byte) ([]byte, error) { return reverse(content), nil }
+
+func (reverseCodec) Decode(content []byte) ([]byte, error) { return reverse(content), nil }
+
+func reverse(content []byte) []byte {
+	reversed := slices.Clone(content)
+	slices.Reverse(reversed)
+	return reversed
+}
+
+func TestGithub_Codec(t *testing.T) {
+	var stored string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/":
+			if stored == "" {
+				json.NewEncoder(w).Encode([]gist{})
+				return
+			}
+			json.NewEncoder(w).Encode([]gist{{ID: "gist-id", Description: config.PiphosStamp}})
+		case r.Method == http.MethodGet && r.URL.Path == "/gist-id":
+			json.NewEncoder(w).Encode(gist{
+				ID:    "gist-id",
+				Files: map[string]gistFile{config.PiphosStamp: {Content: stored, Filename: config.PiphosStamp}},
+			})
+		case r.Method == http.MethodPost:
+			var payload gist
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Errorf("failed to decode request body: %v", err)
+			}
+			stored = payload.Files[config.PiphosStamp].Content
+			w.WriteHeader(http.StatusCreated)
+		default:
+			t.Fatalf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer server.Close()
+	gh := newGithub("test-token")
+	gh.baseURL = server.URL
+	gh.codec = reverseCodec{}
+	if err := gh.Push(context.Background(), "laptop", NewRecord("203.0.113.42")); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if strings.Contains(stored, "203.0.113.42") {
+		t.Errorf("expected stored content to be encoded but got: %s", stored)
+	}
+	hosts, err := gh.Pull(context.Background())
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if hosts["laptop"].IP() != "203.0.113.42" {
+		t.Errorf("expected decoded IP 203.0.113.42 but got %s", hosts["laptop"].IP())
+	}
+	gh.codec = nil
+	if _, err := gh.Pull(context.Background()); err == nil {
+		t.Error("expected error reading encoded content without the codec but got nil")
+	}
+}
@@ -0,0 +1,94 @@
+package tender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// githubUpdateAttempts bounds how often update rebases onto a concurrent write before giving up.
+const githubUpdateAttempts = 5
+
+// Update lets change modify the records of the piphos gist, see Updater.
+func (gh *github) Update(ctx context.Context, change func(hosts map[string]Record) (map[string]Record, bool, error)) error {
+	return gh.update(ctx, change)
+}
+
+// update reads the records of the piphos gist, nil if none exists, and lets change modify
+// them. If change reports a modification, the records are written back, creating the gist
+// if needed. Gists offer no conditional update, so the write is verified instead: the
+// revision preceding ours must be the one the hosts were read from. If a push landed in
+// between, its revision is read, change runs again on it, and the result is written again,
+// so a host that reported in concurrently is restored rather than lost.
+func (gh *github) update(ctx context.Context, change func(hosts map[string]Record) (map[string]Record, bool, error)) error {
+	gistPiphosID, err := gh.findGist(ctx)
+	if err != nil {
+		return err
+	}
+	if gistPiphosID == "" {
+		hosts, changed, err := change(nil)
+		if err != nil || !changed {
+			return err
+		}
+		return gh.createGist(ctx, hosts)
+	}
+	URL := fmt.Sprintf("%s/%s", gh.baseURL, gistPiphosID)
+	body, err := gh.gistRequest(ctx, http.MethodGet, URL, http.StatusOK, nil)
+	if err != nil {
+		return fmt.Errorf("failed to complete gist request: %w", err)
+	}
+	base, err := gistVersions(body)
+	if err != nil {
+		return err
+	}
+	rebased := false
+	for range githubUpdateAttempts {
+		hosts, err := gh.parseGist(body)
+		if err != nil {
+			return err
+		}
+		hosts, changed, err := change(hosts)
+		if err != nil {
+			return err
+		}
+		// After a rebase the concurrent revision overwrote ours, so it is written even unchanged
+		if !changed && !rebased {
+			return nil
+		}
+		updated, err := gh.updateGist(ctx, gistPiphosID, hosts)
+		if err != nil {
+			return err
+		}
+		written, err := gistVersions(updated)
+		if err != nil {
+			return err
+		}
+		// Without revision information the write cannot be verified
+		if len(base) == 0 || len(written) < 2 || written[1] == base[0] {
+			return nil
+		}
+		// A concurrent write landed between our read and our write and was overwritten.
+		// Rebase onto it; the next write must then directly follow our own.
+		body, err = gh.gistRequest(ctx, http.MethodGet, fmt.Sprintf("%s/%s", URL, written[1]), http.StatusOK, nil)
+		if err != nil {
+			return fmt.Errorf("failed to complete gist request: %w", err)
+		}
+		base = written[:1]
+		rebased = true
+	}
+	return fmt.Errorf("gist kept changing, gave up after %d attempts", githubUpdateAttempts)
+}
+
+// gistVersions returns the revision versions listed in a gist API response, newest first.
+func gistVersions(body []byte) ([]string, error) {
+	var g gist
+	if err := json.Unmarshal(body, &g); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	versions := make([]string, 0, len(g.History))
+	for _, c := range g.History {
+		versions = append(versions, c.Version)
+	}
+	return versions, nil
+}
@@ -0,0 +1,25 @@
+package tender
+
+import (
+	"context"
+	"time"
+)
+
+// Revision is a past version of the stored hostname-to-IP mappings.
+type Revision struct {
+	// Version identifies the revision in the tender's storage.
+	Version string
+	// CommittedAt is when the revision was written.
+	CommittedAt time.Time
+	// Hosts holds the records as they were in this revision.
+	Hosts map[string]Record
+}
+
+// Historian is implemented by tenders that keep previous versions of the stored mappings.
+type Historian interface {
+	// History returns the revisions committed at or after since, oldest first.
+	// The newest revision older than since is included as well, so callers can
+	// tell what changed in the first revision of the window.
+	// A zero since returns the complete history.
+	History(ctx context.Context, since time.Time) ([]Revision, error)
+}
@@ -0,0 +1,265 @@
+package tender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// multi fans every operation out to several tenders, so hosts can be kept in more than
+// one place. Writes go to all tenders concurrently and pulls merge their records.
+type multi struct {
+	names   []string
+	tenders []Tender
+	report  io.Writer
+}
+
+// FanOutError is returned when an operation failed on some of the tenders it was sent to.
+// The operation still completed on the others.
+type FanOutError struct {
+	// Failed maps the name of each tender that failed to its error.
+	Failed map[string]error
+	// All is set if the operation failed on every tender.
+	All bool
+}
+
+// Error lists the failed tenders and their errors.
+func (e *FanOutError) Error() string {
+	names := slices.Sorted(maps.Keys(e.Failed))
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %v", name, e.Failed[name])
+	}
+	return "failed on " + strings.Join(parts, "; ")
+}
+
+// Unwrap returns the errors of the failed tenders, so errors.Is sees through them.
+func (e *FanOutError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failed))
+	for _, err := range e.Failed {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Partial reports whether err is a FanOutError for an operation that succeeded on at
+// least one tender.
+func Partial(err error) bool {
+	var fanOut *FanOutError
+	return errors.As(err, &fanOut) && !fanOut.All
+}
+
+// newMulti creates a tender for each of names and combines them.
+func newMulti(names []string, opts Options) (*multi, error) {
+	m := &multi{report: opts.Report}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(m.names, name) {
+			continue
+		}
+		t, err := New(name, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tender %s: %w", name, err)
+		}
+		m.names = append(m.names, name)
+		m.tenders = append(m.tenders, t)
+	}
+	if len(m.tenders) == 0 {
+		return nil, fmt.Errorf("no tender given")
+	}
+	return m, nil
+}
+
+// each runs op on every tender concurrently and returns the errors by tender index.
+// If verb is set, a line per tender reporting the outcome is written to the report writer.
+func (m *multi) each(verb string, op func(i int, t Tender) error) []error {
+	errs := make([]error, len(m.tenders))
+	var wg sync.WaitGroup
+	for i, t := range m.tenders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = op(i, t)
+		}()
+	}
+	wg.Wait()
+	if verb != "" && m.report != nil {
+		for i, name := range m.names {
+			if errs[i] != nil {
+				fmt.Fprintf(m.report, "%s to %s: failed: %v\n", verb, name, errs[i])
+			} else {
+				fmt.Fprintf(m.report, "%s to %s: ok\n", verb, name)
+			}
+		}
+	}
+	return errs
+}
+
+// combine returns nil if no tender failed and a FanOutError otherwise. Errors for which
+// ignore returns true do not count as failures, unless every tender failed.
+func (m *multi) combine(errs []error, ignore func(error) bool) error {
+	failed := map[string]error{}
+	ignored := 0
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if ignore != nil && ignore(err) {
+			ignored++
+			continue
+		}
+		failed[m.names[i]] = err
+	}
+	if len(failed) == 0 {
+		if ignored == len(errs) {
+			return errs[0]
+		}
+		return nil
+	}
+	return &FanOutError{Failed: failed, All: ignored+len(failed) == len(errs)}
+}
+
+// Pull merges the records of all tenders, keeping the newest record of each host.
+// If some tenders fail, the records of the others are returned with a FanOutError.
+func (m *multi) Pull(ctx context.Context) (map[string]Record, error) {
+	results := make([]map[string]Record, len(m.tenders))
+	errs := m.each("", func(i int, t Tender) error {
+		var err error
+		results[i], err = t.Pull(ctx)
+		return err
+	})
+	var merged map[string]Record
+	for i, hosts := range results {
+		if errs[i] != nil {
+			continue
+		}
+		for hostname, record := range hosts {
+			if merged == nil {
+				merged = map[string]Record{}
+			}
+			if existing, ok := merged[hostname]; !ok || record.Newer(existing) {
+				merged[hostname] = record
+			}
+		}
+	}
+	err := m.combine(errs, nil)
+	if err != nil && !Partial(err) {
+		return nil, err
+	}
+	return merged, err
+}
+
+// Push stores record in every tender and reports the outcome of each.
+func (m *multi) Push(ctx context.Context, hostname string, record Record) error {
+	return m.combine(m.each("push", func(_ int, t Tender) error { return t.Push(ctx, hostname, record) }), nil)
+}
+
+// Delete removes the host from every tender. Tenders that do not know the host are
+// ignored as long as one of them removed it.
+func (m *multi) Delete(ctx context.Context, hostname string) error {
+	errs := m.each("", func(_ int, t Tender) error { return t.Delete(ctx, hostname) })
+	return m.combine(errs, isNotFound)
+}
+
+// Rename renames the host in every tender. Tenders that do not know the host are
+// ignored as long as one of them renamed it.
+func (m *multi) Rename(ctx context.Context, oldHostname, newHostname string) error {
+	errs := m.each("", func(_ int, t Tender) error { return t.Rename(ctx, oldHostname, newHostname) })
+	return m.combine(errs, isNotFound)
+}
+
+// Replace stores hosts in every tender.
+func (m *multi) Replace(ctx context.Context, hosts map[string]Record) error {
+	return m.combine(m.each("", func(_ int, t Tender) error { return t.Replace(ctx, hosts) }), nil)
+}
+
+// Update lets change modify the records of every tender that supports it, one tender
+// after the other so change is never called concurrently. Tenders that do not support it
+// are skipped.
+func (m *multi) Update(ctx context.Context, change func(hosts map[string]Record) (map[string]Record, bool, error)) error {
+	errs := make([]error, len(m.tenders))
+	for i, t := range m.tenders {
+		if u, ok := t.(Updater); ok {
+			errs[i] = u.Update(ctx, change)
+		}
+	}
+	return m.combine(errs, nil)
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, ErrHostNotFound)
+}
+
+// Namespaces returns the namespaces of all tenders that can list them.
+func (m *multi) Namespaces(ctx context.Context) ([]string, error) {
+	results := make([][]string, len(m.tenders))
+	errs := m.each("", func(i int, t Tender) error {
+		n, ok := t.(Namespacer)
+		if !ok {
+			return nil
+		}
+		namespaces, err := n.Namespaces(ctx)
+		results[i] = namespaces
+		return err
+	})
+	if err := m.combine(errs, nil); err != nil {
+		return nil, err
+	}
+	namespaces := slices.Concat(results...)
+	slices.Sort(namespaces)
+	return slices.Compact(namespaces), nil
+}
+
+// Prune removes expired hosts from every tender that supports it and returns the
+// hosts removed from any of them.
+func (m *multi) Prune(ctx context.Context, expired func(hostname string, record Record) bool, dryRun bool) ([]string, error) {
+	results := make([][]string, len(m.tenders))
+	errs := m.each("", func(i int, t Tender) error {
+		p, ok := t.(Pruner)
+		if !ok {
+			return nil
+		}
+		removed, err := p.Prune(ctx, expired, dryRun)
+		results[i] = removed
+		return err
+	})
+	removed := slices.Concat(results...)
+	slices.Sort(removed)
+	return slices.Compact(removed), m.combine(errs, nil)
+}
+
+// Reseal rewrites the records of every tender that supports it.
+func (m *multi) Reseal(ctx context.Context) error {
+	return m.combine(m.each("", func(_ int, t Tender) error {
+		if r, ok := t.(Resealer); ok {
+			return r.Reseal(ctx)
+		}
+		return nil
+	}), nil)
+}
+
+// History returns the history of the first tender keeping one.
+func (m *multi) History(ctx context.Context, since time.Time) ([]Revision, error) {
+	for _, t := range m.tenders {
+		if h, ok := t.(Historian); ok {
+			return h.History(ctx, since)
+		}
+	}
+	return nil, fmt.Errorf("none of the tenders %s keeps a history", strings.Join(m.names, ", "))
+}
+
+// Check checks the credentials of the first tender that can verify them.
+func (m *multi) Check(ctx context.Context) (*AuthStatus, error) {
+	for _, t := range m.tenders {
+		if c, ok := t.(Checker); ok {
+			return c.Check(ctx)
+		}
+	}
+	return nil, fmt.Errorf("none of the tenders %s can check its credentials", strings.Join(m.names, ", "))
+}
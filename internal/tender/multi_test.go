@@ -0,0 +1,227 @@
+package tender
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memStore is a store kept in memory that fails every operation while err is set.
+// Its version counts the saves; beforeSave, if set, runs before every save, e.g. to
+// simulate a concurrent writer.
+type memStore struct {
+	content    []byte
+	version    int
+	err        error
+	beforeSave func()
+}
+
+func (s *memStore) load(context.Context) ([]byte, string, error) {
+	if s.content == nil {
+		return nil, "", s.err
+	}
+	return s.content, strconv.Itoa(s.version), s.err
+}
+
+func (s *memStore) save(_ context.Context, content []byte, version string) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.beforeSave != nil {
+		s.beforeSave()
+	}
+	if current, _, _ := s.load(context.Background()); (current == nil && version != "") || (current != nil && version != strconv.Itoa(s.version)) {
+		return errConflict
+	}
+	s.content = content
+	s.version++
+	return nil
+}
+
+func (s *memStore) namespaces(context.Context) ([]string, error) { return []string{"default"}, s.err }
+
+// newTestMulti combines a document tender per store, called by the matching entry of names.
+func newTestMulti(report io.Writer, names []string, stores ...*memStore) *multi {
+	m := &multi{names: names, report: report}
+	for _, s := range stores {
+		m.tenders = append(m.tenders, &documentTender{store: s})
+	}
+	return m
+}
+
+func TestMultiPush(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	tests := []struct {
+		name            string
+		failing         []bool
+		expectedError   bool
+		expectedPartial bool
+		expectedReport  string
+	}{
+		{
+			name:           "all succeed",
+			failing:        []bool{false, false},
+			expectedReport: "push to gh: ok\npush to s3: ok\n",
+		},
+		{
+			name:            "one fails",
+			failing:         []bool{false, true},
+			expectedError:   true,
+			expectedPartial: true,
+			expectedReport:  "push to gh: ok\npush to s3: failed: unavailable\n",
+		},
+		{
+			name:           "all fail",
+			failing:        []bool{true, true},
+			expectedError:  true,
+			expectedReport: "push to gh: failed: unavailable\npush to s3: failed: unavailable\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stores := []*memStore{{}, {}}
+			for i, failing := range tt.failing {
+				if failing {
+					stores[i].err = unavailable
+				}
+			}
+			var report bytes.Buffer
+			m := newTestMulti(&report, []string{"gh", "s3"}, stores...)
+			err := m.Push(context.Background(), "laptop", NewRecord("203.0.113.1"))
+			if (err != nil) != tt.expectedError {
+				t.Errorf("expected error %v but got: %v", tt.expectedError, err)
+			}
+			if Partial(err) != tt.expectedPartial {
+				t.Errorf("expected partial %v but got: %v", tt.expectedPartial, err)
+			}
+			if err != nil && !errors.Is(err, unavailable) {
+				t.Errorf("expected error to wrap the tender's error but got: %v", err)
+			}
+			if report.String() != tt.expectedReport {
+				t.Errorf("expected report %q but got %q", tt.expectedReport, report.String())
+			}
+			for i, s := range stores {
+				if !tt.failing[i] && s.content == nil {
+					t.Errorf("expected store %d to be written despite failures", i)
+				}
+			}
+		})
+	}
+}
+
+func TestMultiPull(t *testing.T) {
+	ctx := context.Background()
+	older := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	first, second := &memStore{}, &memStore{}
+	m := newTestMulti(nil, []string{"gh", "file"}, first, second)
+	push := func(s *memStore, hostname, ip string, updated, seen time.Time) {
+		t.Helper()
+		r := NewRecord(ip)
+		r.UpdatedAt, r.SeenAt = updated, seen
+		if err := (&documentTender{store: s}).Push(ctx, hostname, r); err != nil {
+			t.Fatalf("failed to push: %v", err)
+		}
+	}
+	push(first, "laptop", "203.0.113.1", older, older)
+	push(second, "laptop", "203.0.113.2", newer, newer)
+	push(first, "server", "203.0.113.3", older, newer)
+	push(second, "server", "203.0.113.4", older, older)
+	push(second, "nas", "203.0.113.5", older, older)
+	hosts, err := m.Pull(ctx)
+	if err != nil {
+		t.Fatalf("failed to pull: %v", err)
+	}
+	expected := map[string]string{"laptop": "203.0.113.2", "server": "203.0.113.3", "nas": "203.0.113.5"}
+	if len(hosts) != len(expected) {
+		t.Errorf("expected %d hosts but got %+v", len(expected), hosts)
+	}
+	for hostname, ip := range expected {
+		if hosts[hostname].IPv4 != ip {
+			t.Errorf("expected %s for %s but got %s", ip, hostname, hosts[hostname].IPv4)
+		}
+	}
+	second.err = errors.New("unavailable")
+	hosts, err = m.Pull(ctx)
+	if !Partial(err) || !strings.Contains(err.Error(), "file: unavailable") {
+		t.Errorf("expected a partial failure of file but got: %v", err)
+	}
+	if hosts["laptop"].IPv4 != "203.0.113.1" {
+		t.Errorf("expected the records of the working tender but got %+v", hosts)
+	}
+	first.err = second.err
+	if hosts, err = m.Pull(ctx); err == nil || Partial(err) || hosts != nil {
+		t.Errorf("expected a complete failure but got %+v, %v", hosts, err)
+	}
+}
+
+func TestMultiDelete(t *testing.T) {
+	ctx := context.Background()
+	first, second := &memStore{}, &memStore{}
+	m := newTestMulti(nil, []string{"gh", "file"}, first, second)
+	if err := (&documentTender{store: first}).Push(ctx, "laptop", NewRecord("203.0.113.1")); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	if err := m.Delete(ctx, "laptop"); err != nil {
+		t.Errorf("expected hosts missing from some tenders to be ignored but got: %v", err)
+	}
+	if err := m.Delete(ctx, "laptop"); !errors.Is(err, ErrHostNotFound) || Partial(err) {
+		t.Errorf("expected ErrHostNotFound once no tender knows the host but got: %v", err)
+	}
+}
+
+func TestMultiUpdate(t *testing.T) {
+	ctx := context.Background()
+	first, second, third := &memStore{}, &memStore{}, &memStore{err: errors.New("unavailable")}
+	m := newTestMulti(nil, []string{"gh", "file", "s3"}, first, second, third)
+	if err := (&documentTender{store: first}).Push(ctx, "laptop", NewRecord("203.0.113.1")); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	calls := 0
+	err := m.Update(ctx, func(hosts map[string]Record) (map[string]Record, bool, error) {
+		calls++
+		if hosts == nil {
+			hosts = map[string]Record{}
+		}
+		hosts["nas"] = NewRecord("203.0.113.5")
+		return hosts, true, nil
+	})
+	if !Partial(err) {
+		t.Errorf("expected a partial failure but got: %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("expected change to run once per available tender but it ran %d times", calls)
+	}
+	expectedHosts := []int{2, 1}
+	for i, s := range []*memStore{first, second} {
+		hosts, err := (&documentTender{store: s}).Pull(ctx)
+		if err != nil {
+			t.Fatalf("failed to pull: %v", err)
+		}
+		if len(hosts) != expectedHosts[i] || hosts["nas"].IPv4 != "203.0.113.5" {
+			t.Errorf("expected tender %d to hold %d hosts including nas but got %+v", i, expectedHosts[i], hosts)
+		}
+	}
+}
+
+func TestNewMulti(t *testing.T) {
+	t.Setenv("PIPHOS_FILE_DIR", t.TempDir())
+	isolateCredentials(t)
+	if _, err := New("file, file", Options{}); err != nil {
+		t.Errorf("expected no error but got: %v", err)
+	}
+	if _, err := New("file,unknown", Options{}); err == nil {
+		t.Error("expected error for an unknown tender but got nil")
+	}
+	if _, err := New("file,gh", Options{}); err == nil {
+		t.Error("expected error for a tender without credentials but got nil")
+	}
+	if _, err := New(",", Options{}); err == nil {
+		t.Error("expected error for an empty list but got nil")
+	}
+}
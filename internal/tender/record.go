@@ -0,0 +1,323 @@
+package tender
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// SchemaVersion is the version of the stored document format written by this release.
+// Version 1 is the legacy flat hostname-to-IP object, which is still read.
+const SchemaVersion = 2
+
+// Record holds everything stored about a single host.
+type Record struct {
+	// IPv4 and IPv6 are the host's public addresses; at least one is set.
+	IPv4 string `json:"ipv4,omitempty"`
+	IPv6 string `json:"ipv6,omitempty"`
+	// UpdatedAt is when the address last changed.
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	// SeenAt is when the host last reported in.
+	SeenAt time.Time `json:"seen_at,omitzero"`
+	// Beacon is the beacon provider that detected the address.
+	Beacon string `json:"beacon,omitempty"`
+	// Version is the piphos version that wrote the record.
+	Version string `json:"version,omitempty"`
+	// OS is the operating system and architecture of the host, e.g. "linux/amd64".
+	OS string `json:"os,omitempty"`
+	// TTL is how long the host may go without reporting in before prune removes it.
+	// Zero keeps the host until it is pruned explicitly.
+	TTL Duration `json:"ttl,omitempty"`
+	// Manual is set for records entered with piphos set rather than detected by a beacon.
+	Manual bool `json:"manual,omitempty"`
+	// Aliases are additional names resolving to this record.
+	Aliases []string `json:"aliases,omitempty"`
+	// MachineID identifies the machine that pushed the record, see machine.ID.
+	// It tells apart machines reporting under the same hostname.
+	MachineID string `json:"machine_id,omitempty"`
+	// PublicKey is the Ed25519 key of the host that signed the record, see identity.Signer.
+	PublicKey string `json:"public_key,omitempty"`
+	// Signature signs SigningPayload with PublicKey.
+	Signature string `json:"signature,omitempty"`
+}
+
+// Duration is a time.Duration stored as a string such as "720h0m0s".
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// NewRecord creates a record holding ip in the field matching its address family.
+func NewRecord(ip string) Record {
+	var r Record
+	r.SetIP(ip)
+	return r
+}
+
+// SetIP stores ip in IPv4 or IPv6 depending on its address family.
+func (r *Record) SetIP(ip string) {
+	parsed := net.ParseIP(ip)
+	if parsed != nil && parsed.To4() == nil {
+		r.IPv6 = ip
+		return
+	}
+	r.IPv4 = ip
+}
+
+// IP returns the host's preferred address: IPv4 if known, otherwise IPv6.
+func (r Record) IP() string {
+	if r.IPv4 != "" {
+		return r.IPv4
+	}
+	return r.IPv6
+}
+
+// Addresses returns all known addresses of the host, IPv4 first.
+func (r Record) Addresses() []string {
+	var addresses []string
+	if r.IPv4 != "" {
+		addresses = append(addresses, r.IPv4)
+	}
+	if r.IPv6 != "" {
+		addresses = append(addresses, r.IPv6)
+	}
+	return addresses
+}
+
+// SigningPayload returns the canonical bytes a host signs for its record stored under hostname.
+// UpdatedAt is excluded, since the tender carries it over from the stored record when the
+// address is unchanged, and so is the signature itself.
+func (r Record) SigningPayload(hostname string) ([]byte, error) {
+	payload, err := json.Marshal(struct {
+		Hostname  string    `json:"hostname"`
+		IPv4      string    `json:"ipv4"`
+		IPv6      string    `json:"ipv6"`
+		SeenAt    time.Time `json:"seen_at"`
+		Beacon    string    `json:"beacon"`
+		Version   string    `json:"version"`
+		OS        string    `json:"os"`
+		TTL       Duration  `json:"ttl"`
+		Manual    bool      `json:"manual"`
+		Aliases   []string  `json:"aliases"`
+		MachineID string    `json:"machine_id"`
+		PublicKey string    `json:"public_key"`
+	}{hostname, r.IPv4, r.IPv6, r.SeenAt.UTC(), r.Beacon, r.Version, r.OS, r.TTL, r.Manual, r.Aliases, r.MachineID, r.PublicKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal signing payload: %w", err)
+	}
+	return payload, nil
+}
+
+// validateNames checks that hostname and the aliases of r are valid host names, see
+// validate.Hostname, so that differently spelled names of one host cannot be stored.
+func validateNames(hostname string, r Record) error {
+	if err := validate.Hostname(hostname); err != nil {
+		return err
+	}
+	for _, alias := range r.Aliases {
+		if err := validate.Hostname(alias); err != nil {
+			return fmt.Errorf("invalid alias: %w", err)
+		}
+	}
+	return nil
+}
+
+// Resolve finds the record stored under name, either as its hostname or as one of its
+// aliases, and returns the hostname it is stored under. Hostnames take precedence over aliases.
+func Resolve(hosts map[string]Record, name string) (string, Record, bool) {
+	if r, ok := hosts[name]; ok {
+		return name, r, true
+	}
+	for _, hostname := range slices.Sorted(maps.Keys(hosts)) {
+		if slices.Contains(hosts[hostname].Aliases, name) {
+			return hostname, hosts[hostname], true
+		}
+	}
+	return "", Record{}, false
+}
+
+// KeepOtherFamily fills in the address of a family r does not hold from previous. A push
+// detects the public address of a single family and must not drop the other one, e.g.
+// an IPv6 address stored with set or by a push over IPv6.
+func (r *Record) KeepOtherFamily(previous Record) {
+	if r.IPv4 == "" {
+		r.IPv4 = previous.IPv4
+	}
+	if r.IPv6 == "" {
+		r.IPv6 = previous.IPv6
+	}
+}
+
+// SameAddress reports whether r and other hold the same addresses.
+func (r Record) SameAddress(other Record) bool {
+	return r.IPv4 == other.IPv4 && r.IPv6 == other.IPv6
+}
+
+// Newer reports whether r was updated after other, or seen after it if both were
+// updated at the same time.
+func (r Record) Newer(other Record) bool {
+	if !r.UpdatedAt.Equal(other.UpdatedAt) {
+		return r.UpdatedAt.After(other.UpdatedAt)
+	}
+	return r.SeenAt.After(other.SeenAt)
+}
+
+// Stale reports whether the host has not reported in for longer than threshold.
+// A zero threshold disables the check, and records without a last-seen time are never stale.
+func (r Record) Stale(now time.Time, threshold time.Duration) bool {
+	return threshold > 0 && !r.SeenAt.IsZero() && now.Sub(r.SeenAt) > threshold
+}
+
+// LastSeen returns when the host last reported in, falling back to when its address
+// last changed for records written without a last-seen time.
+func (r Record) LastSeen() time.Time {
+	if !r.SeenAt.IsZero() {
+		return r.SeenAt
+	}
+	return r.UpdatedAt
+}
+
+// Undated reports whether the record carries no timestamp at all, e.g. because it was
+// upgraded from the legacy format, so its age is unknown.
+func (r Record) Undated() bool {
+	return r.LastSeen().IsZero()
+}
+
+// Expired reports whether the host has not reported in for longer than olderThan or
+// its own TTL, whichever is set. Zero olderThan only honours the TTL. Manual records,
+// which never report in, and undated records never expire.
+func (r Record) Expired(now time.Time, olderThan time.Duration) bool {
+	if r.Manual || r.Undated() {
+		return false
+	}
+	age := now.Sub(r.LastSeen())
+	return (olderThan > 0 && age > olderThan) || (r.TTL > 0 && age > time.Duration(r.TTL))
+}
+
+// merge decides what to store when incoming is pushed over existing (ok reports whether
+// existing is present) and whether anything needs to be written at all.
+// A changed address is always written. An unchanged address is only written once the
+// stored last-seen time is at least heartbeat old or the metadata set by the user changed,
+// keeping the stored last-updated time; a zero heartbeat never writes unchanged addresses otherwise.
+// An unsigned incoming record keeps the address family it lacks, see KeepOtherFamily, so only
+// the family it carries is compared; signed records are completed before signing, since the
+// signature covers both addresses.
+func merge(existing Record, ok bool, incoming Record, heartbeat time.Duration) (Record, bool) {
+	if ok && incoming.Signature == "" {
+		incoming.KeepOtherFamily(existing)
+	}
+	if !ok || !existing.SameAddress(incoming) {
+		return incoming, true
+	}
+	heartbeatDue := heartbeat > 0 && incoming.SeenAt.Sub(existing.SeenAt) >= heartbeat
+	if !heartbeatDue && sameMetadata(existing, incoming) {
+		return existing, false
+	}
+	incoming.UpdatedAt = existing.UpdatedAt
+	return incoming, true
+}
+
+// sameMetadata reports whether r and other agree on everything but addresses and timestamps
+// that is worth a write of its own. Signatures cover the last-seen time and so differ on
+// every push; only one being present and the other not counts as a change, so a record
+// whose signature was removed, e.g. by a rename, is signed again by the next push.
+func sameMetadata(r, other Record) bool {
+	return r.TTL == other.TTL &&
+		r.Manual == other.Manual &&
+		r.MachineID == other.MachineID &&
+		r.PublicKey == other.PublicKey &&
+		(r.Signature == "") == (other.Signature == "") &&
+		slices.Equal(r.Aliases, other.Aliases)
+}
+
+// renamed returns r as moved to newHostname: an alias equal to newHostname is dropped, as
+// it is the host's name now, and so are the signature and its key, which bind the old
+// hostname and aliases and would no longer verify.
+func (r Record) renamed(newHostname string) Record {
+	r.Aliases = slices.DeleteFunc(slices.Clone(r.Aliases), func(alias string) bool { return alias == newHostname })
+	r.Signature = ""
+	r.PublicKey = ""
+	return r
+}
+
+// document is the stored representation of all records, schema version 2 and later.
+type document struct {
+	Schema int               `json:"schema"`
+	Hosts  map[string]Record `json:"hosts"`
+}
+
+// DecodeHosts parses stored or exported content into records. Both the current document
+// format and the legacy flat {"hostname": "ip"} format are accepted.
+func DecodeHosts(content []byte) (map[string]Record, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal content: %w", err)
+	}
+	// Encrypted content is an envelope with "encrypted" and "ciphertext" fields, see crypt.Keyring
+	if isEncrypted(raw) {
+		return nil, fmt.Errorf("content is encrypted, set up the key file to read it (see piphos key generate)")
+	}
+	if isLegacy(raw) {
+		var legacy map[string]string
+		if err := json.Unmarshal(content, &legacy); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal content: %w", err)
+		}
+		hosts := make(map[string]Record, len(legacy))
+		for hostname, ip := range legacy {
+			hosts[hostname] = NewRecord(ip)
+		}
+		return hosts, nil
+	}
+	var doc document
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal content: %w", err)
+	}
+	if doc.Schema > SchemaVersion {
+		return nil, fmt.Errorf("unsupported schema version %d, upgrade piphos to read it", doc.Schema)
+	}
+	if doc.Hosts == nil {
+		doc.Hosts = map[string]Record{}
+	}
+	return doc.Hosts, nil
+}
+
+// isEncrypted reports whether decoded content is an encryption envelope.
+func isEncrypted(raw map[string]json.RawMessage) bool {
+	_, encrypted := raw["encrypted"]
+	_, ciphertext := raw["ciphertext"]
+	return encrypted && ciphertext
+}
+
+// isLegacy reports whether decoded content uses the legacy flat format, in which
+// every value is a string. A document always has a numeric schema field.
+func isLegacy(raw map[string]json.RawMessage) bool {
+	schema, ok := raw["schema"]
+	return !ok || strings.HasPrefix(strings.TrimSpace(string(schema)), `"`)
+}
+
+// EncodeHosts serialises records in the current document format, as stored and exported.
+func EncodeHosts(hosts map[string]Record) ([]byte, error) {
+	content, err := json.MarshalIndent(document{Schema: SchemaVersion, Hosts: hosts}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal content: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,396 @@
+package tender
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewRecord(t *testing.T) {
+	tests := []struct {
+		name         string
+		ip           string
+		expectedIPv4 string
+		expectedIPv6 string
+	}{
+		{name: "IPv4", ip: "203.0.113.1", expectedIPv4: "203.0.113.1"},
+		{name: "IPv6", ip: "2001:db8::1", expectedIPv6: "2001:db8::1"},
+		{name: "IPv4-mapped IPv6", ip: "::ffff:203.0.113.1", expectedIPv4: "::ffff:203.0.113.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRecord(tt.ip)
+			if r.IPv4 != tt.expectedIPv4 || r.IPv6 != tt.expectedIPv6 {
+				t.Errorf("expected IPv4 %q and IPv6 %q but got %q and %q", tt.expectedIPv4, tt.expectedIPv6, r.IPv4, r.IPv6)
+			}
+			if r.IP() != tt.ip {
+				t.Errorf("expected IP %s but got %s", tt.ip, r.IP())
+			}
+		})
+	}
+}
+
+func TestRecordAddresses(t *testing.T) {
+	r := Record{IPv4: "203.0.113.1", IPv6: "2001:db8::1"}
+	if r.IP() != "203.0.113.1" {
+		t.Errorf("expected IPv4 to be preferred but got %s", r.IP())
+	}
+	addresses := r.Addresses()
+	if len(addresses) != 2 || addresses[0] != "203.0.113.1" || addresses[1] != "2001:db8::1" {
+		t.Errorf("expected both addresses, IPv4 first, but got %v", addresses)
+	}
+	if !r.SameAddress(Record{IPv4: "203.0.113.1", IPv6: "2001:db8::1", Beacon: "aws"}) {
+		t.Error("expected records with the same addresses to match")
+	}
+	if r.SameAddress(NewRecord("203.0.113.1")) {
+		t.Error("expected records with different IPv6 addresses not to match")
+	}
+}
+
+func TestDecodeHosts(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedHosts map[string]string
+		expectedError bool
+	}{
+		{
+			name:          "legacy flat format",
+			content:       `{"laptop": "203.0.113.42", "desktop": "2001:db8::17"}`,
+			expectedHosts: map[string]string{"laptop": "203.0.113.42", "desktop": "2001:db8::17"},
+		},
+		{
+			name:          "legacy host named schema",
+			content:       `{"schema": "203.0.113.1"}`,
+			expectedHosts: map[string]string{"schema": "203.0.113.1"},
+		},
+		{
+			name:          "current format",
+			content:       `{"schema": 2, "hosts": {"laptop": {"ipv4": "203.0.113.42", "updated_at": "2025-06-01T12:00:00Z"}}}`,
+			expectedHosts: map[string]string{"laptop": "203.0.113.42"},
+		},
+		{
+			name:          "empty document",
+			content:       `{"schema": 2}`,
+			expectedHosts: map[string]string{},
+		},
+		{
+			name:          "newer schema",
+			content:       `{"schema": 99, "hosts": {}}`,
+			expectedError: true,
+		},
+		{
+			name:          "invalid content",
+			content:       `not json`,
+			expectedError: true,
+		},
+		{
+			name:          "encrypted content",
+			content:       `{"encrypted": "aes-256-gcm", "key_id": "0011223344556677", "nonce": "AAAA", "ciphertext": "AAAA"}`,
+			expectedError: true,
+		},
+		{
+			name:          "legacy host named encrypted",
+			content:       `{"encrypted": "203.0.113.1"}`,
+			expectedHosts: map[string]string{"encrypted": "203.0.113.1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := DecodeHosts([]byte(tt.content))
+			if tt.expectedError {
+				if err == nil {
+					t.Error("expected error but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if len(hosts) != len(tt.expectedHosts) {
+				t.Fatalf("expected %d hosts but got %d", len(tt.expectedHosts), len(hosts))
+			}
+			for hostname, ip := range tt.expectedHosts {
+				if hosts[hostname].IP() != ip {
+					t.Errorf("expected IP %s for %s but got %s", ip, hostname, hosts[hostname].IP())
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeHosts_RoundTrip(t *testing.T) {
+	updated := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	hosts := map[string]Record{
+		"laptop": {IPv4: "203.0.113.42", UpdatedAt: updated, SeenAt: updated, Beacon: "aws", Version: "1.0", OS: "linux/amd64"},
+	}
+	content, err := EncodeHosts(hosts)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	decoded, err := DecodeHosts(content)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !reflect.DeepEqual(decoded["laptop"], hosts["laptop"]) {
+		t.Errorf("expected %+v but got %+v", hosts["laptop"], decoded["laptop"])
+	}
+}
+
+func TestRecordNewer(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		record   Record
+		other    Record
+		expected bool
+	}{
+		{name: "updated later", record: Record{UpdatedAt: now}, other: Record{UpdatedAt: now.Add(-time.Hour), SeenAt: now}, expected: true},
+		{name: "updated earlier", record: Record{UpdatedAt: now.Add(-time.Hour), SeenAt: now}, other: Record{UpdatedAt: now}, expected: false},
+		{name: "seen later", record: Record{UpdatedAt: now, SeenAt: now.Add(time.Hour)}, other: Record{UpdatedAt: now, SeenAt: now}, expected: true},
+		{name: "same times", record: Record{UpdatedAt: now, SeenAt: now}, other: Record{UpdatedAt: now, SeenAt: now}, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if newer := tt.record.Newer(tt.other); newer != tt.expected {
+				t.Errorf("expected newer %v but got %v", tt.expected, newer)
+			}
+		})
+	}
+}
+
+func TestRecordStale(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		record    Record
+		threshold time.Duration
+		expected  bool
+	}{
+		{name: "disabled", record: Record{SeenAt: now.Add(-48 * time.Hour)}, threshold: 0, expected: false},
+		{name: "fresh", record: Record{SeenAt: now.Add(-time.Hour)}, threshold: 24 * time.Hour, expected: false},
+		{name: "stale", record: Record{SeenAt: now.Add(-48 * time.Hour)}, threshold: 24 * time.Hour, expected: true},
+		{name: "never seen", record: Record{}, threshold: 24 * time.Hour, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if stale := tt.record.Stale(now, tt.threshold); stale != tt.expected {
+				t.Errorf("expected stale %v but got %v", tt.expected, stale)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	updated := now.Add(-72 * time.Hour)
+	existing := Record{IPv4: "203.0.113.1", UpdatedAt: updated, SeenAt: now.Add(-7 * time.Hour)}
+	dualStack := Record{IPv4: "203.0.113.1", IPv6: "2001:db8::1", UpdatedAt: updated, SeenAt: now.Add(-7 * time.Hour)}
+	tests := []struct {
+		name              string
+		existing          Record
+		ok                bool
+		incoming          Record
+		heartbeat         time.Duration
+		expectedWrite     bool
+		expectedUpdatedAt time.Time
+		expectedIPv6      string
+	}{
+		{
+			name:              "new host",
+			ok:                false,
+			incoming:          Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now},
+			expectedWrite:     true,
+			expectedUpdatedAt: now,
+		},
+		{
+			name:              "changed address",
+			existing:          existing,
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.2", UpdatedAt: now, SeenAt: now},
+			expectedWrite:     true,
+			expectedUpdatedAt: now,
+		},
+		{
+			name:              "push of one family keeps the other",
+			existing:          dualStack,
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now},
+			expectedWrite:     false,
+			expectedUpdatedAt: updated,
+			expectedIPv6:      "2001:db8::1",
+		},
+		{
+			name:              "changed address keeps the other family",
+			existing:          dualStack,
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.2", UpdatedAt: now, SeenAt: now},
+			expectedWrite:     true,
+			expectedUpdatedAt: now,
+			expectedIPv6:      "2001:db8::1",
+		},
+		{
+			name:              "unchanged without heartbeat",
+			existing:          existing,
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now},
+			expectedWrite:     false,
+			expectedUpdatedAt: updated,
+		},
+		{
+			name:              "unchanged with heartbeat due",
+			existing:          existing,
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now},
+			heartbeat:         6 * time.Hour,
+			expectedWrite:     true,
+			expectedUpdatedAt: updated,
+		},
+		{
+			name:              "unchanged with new ttl",
+			existing:          existing,
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now, TTL: Duration(time.Hour)},
+			expectedWrite:     true,
+			expectedUpdatedAt: updated,
+		},
+		{
+			name:              "unchanged but set manually",
+			existing:          existing,
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now, Manual: true},
+			expectedWrite:     true,
+			expectedUpdatedAt: updated,
+		},
+		{
+			name:              "unchanged with new alias",
+			existing:          existing,
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now, Aliases: []string{"nas"}},
+			expectedWrite:     true,
+			expectedUpdatedAt: updated,
+		},
+		{
+			name:              "unchanged but signature removed by a rename",
+			existing:          existing,
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now, Signature: "sig"},
+			expectedWrite:     true,
+			expectedUpdatedAt: updated,
+		},
+		{
+			name:              "unchanged and signed again",
+			existing:          Record{IPv4: "203.0.113.1", UpdatedAt: updated, SeenAt: now.Add(-7 * time.Hour), Signature: "old"},
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now, Signature: "new"},
+			expectedWrite:     false,
+			expectedUpdatedAt: updated,
+		},
+		{
+			name:              "unchanged with heartbeat not due",
+			existing:          existing,
+			ok:                true,
+			incoming:          Record{IPv4: "203.0.113.1", UpdatedAt: now, SeenAt: now},
+			heartbeat:         12 * time.Hour,
+			expectedWrite:     false,
+			expectedUpdatedAt: updated,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, write := merge(tt.existing, tt.ok, tt.incoming, tt.heartbeat)
+			if write != tt.expectedWrite {
+				t.Errorf("expected write %v but got %v", tt.expectedWrite, write)
+			}
+			if !merged.UpdatedAt.Equal(tt.expectedUpdatedAt) {
+				t.Errorf("expected updated time %v but got %v", tt.expectedUpdatedAt, merged.UpdatedAt)
+			}
+			if merged.IPv6 != tt.expectedIPv6 {
+				t.Errorf("expected IPv6 %q but got %q", tt.expectedIPv6, merged.IPv6)
+			}
+			if write && !merged.SeenAt.Equal(now) {
+				t.Errorf("expected seen time to be refreshed but got %v", merged.SeenAt)
+			}
+		})
+	}
+}
+
+func TestRecordExpired(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		record    Record
+		olderThan time.Duration
+		expected  bool
+	}{
+		{name: "recently seen", record: Record{SeenAt: now.Add(-time.Hour)}, olderThan: 24 * time.Hour, expected: false},
+		{name: "not seen for long", record: Record{SeenAt: now.Add(-48 * time.Hour)}, olderThan: 24 * time.Hour, expected: true},
+		{name: "falls back to updated time", record: Record{UpdatedAt: now.Add(-48 * time.Hour)}, olderThan: 24 * time.Hour, expected: true},
+		{name: "ttl exceeded", record: Record{SeenAt: now.Add(-2 * time.Hour), TTL: Duration(time.Hour)}, olderThan: 0, expected: true},
+		{name: "ttl not exceeded", record: Record{SeenAt: now.Add(-30 * time.Minute), TTL: Duration(time.Hour)}, olderThan: 0, expected: false},
+		{name: "no threshold", record: Record{SeenAt: now.Add(-48 * time.Hour)}, olderThan: 0, expected: false},
+		{name: "no timestamps", record: Record{IPv4: "203.0.113.1"}, olderThan: time.Hour, expected: false},
+		{name: "manual", record: Record{UpdatedAt: now.Add(-48 * time.Hour), Manual: true}, olderThan: 24 * time.Hour, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if expired := tt.record.Expired(now, tt.olderThan); expired != tt.expected {
+				t.Errorf("expected expired %v but got %v", tt.expected, expired)
+			}
+		})
+	}
+}
+
+func TestDuration_RoundTrip(t *testing.T) {
+	hosts := map[string]Record{"laptop": {IPv4: "203.0.113.1", TTL: Duration(720 * time.Hour)}}
+	content, err := EncodeHosts(hosts)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !strings.Contains(string(content), `"ttl": "720h0m0s"`) {
+		t.Errorf("expected ttl to be stored as a duration string but got %s", content)
+	}
+	decoded, err := DecodeHosts(content)
+	if err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if decoded["laptop"].TTL != hosts["laptop"].TTL {
+		t.Errorf("expected ttl %v but got %v", hosts["laptop"].TTL, decoded["laptop"].TTL)
+	}
+	if _, err := DecodeHosts([]byte(`{"schema": 2, "hosts": {"laptop": {"ttl": "forever"}}}`)); err == nil {
+		t.Error("expected error for invalid ttl but got nil")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	hosts := map[string]Record{
+		"server": {IPv4: "203.0.113.1", Aliases: []string{"nas", "laptop"}},
+		"laptop": {IPv4: "203.0.113.2"},
+	}
+	tests := []struct {
+		name             string
+		lookup           string
+		expectedHostname string
+		expectedFound    bool
+	}{
+		{name: "hostname", lookup: "server", expectedHostname: "server", expectedFound: true},
+		{name: "alias", lookup: "nas", expectedHostname: "server", expectedFound: true},
+		{name: "hostname wins over alias", lookup: "laptop", expectedHostname: "laptop", expectedFound: true},
+		{name: "unknown", lookup: "desktop", expectedFound: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hostname, record, found := Resolve(hosts, tt.lookup)
+			if found != tt.expectedFound {
+				t.Fatalf("expected found %v but got %v", tt.expectedFound, found)
+			}
+			if hostname != tt.expectedHostname {
+				t.Errorf("expected hostname %q but got %q", tt.expectedHostname, hostname)
+			}
+			if found && record.IP() != hosts[hostname].IP() {
+				t.Errorf("expected record of %s but got %+v", hostname, record)
+			}
+		})
+	}
+}
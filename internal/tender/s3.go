@@ -0,0 +1,292 @@
+package tender
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kappapee/piphos/internal/config"
+)
+
+const s3Name = "s3"
+
+// s3Store keeps the document of a namespace as an object in an S3 bucket, or a bucket
+// of any S3-compatible service such as MinIO, Cloudflare R2 or Backblaze B2.
+// Requests use path-style URLs and are signed with AWS Signature Version 4.
+type s3Store struct {
+	endpoint     string
+	region       string
+	bucket       string
+	prefix       string
+	key          string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	client       *http.Client
+	now          func() time.Time
+}
+
+// s3FromEnv creates an s3 tender configured by the environment: the bucket is named by
+// PIPHOS_S3_BUCKET, objects are stored below PIPHOS_S3_PREFIX (default: "piphos/"), the
+// region is PIPHOS_S3_REGION, AWS_REGION or AWS_DEFAULT_REGION (default: "us-east-1"),
+// and PIPHOS_S3_ENDPOINT selects another S3-compatible service (default: AWS).
+// Credentials are read from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+func s3FromEnv(opts Options) (*documentTender, error) {
+	s := &s3Store{
+		bucket:       os.Getenv("PIPHOS_S3_BUCKET"),
+		prefix:       "piphos/",
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		client:       &http.Client{Timeout: config.HTTPClientTimeout},
+		now:          time.Now,
+	}
+	if s.bucket == "" {
+		return nil, fmt.Errorf("no S3 bucket configured, set PIPHOS_S3_BUCKET")
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, fmt.Errorf("no AWS credentials found, set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if prefix, ok := os.LookupEnv("PIPHOS_S3_PREFIX"); ok {
+		s.prefix = prefix
+	}
+	for _, name := range []string{"PIPHOS_S3_REGION", "AWS_REGION", "AWS_DEFAULT_REGION"} {
+		if s.region = os.Getenv(name); s.region != "" {
+			break
+		}
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+	}
+	s.endpoint = strings.TrimSuffix(os.Getenv("PIPHOS_S3_ENDPOINT"), "/")
+	if s.endpoint == "" {
+		s.endpoint = "https://s3." + s.region + ".amazonaws.com"
+	}
+	s.key = s.prefix + documentName(opts.Namespace)
+	opts.logf("storing hosts in s3://%s/%s at %s", s.bucket, s.key, s.endpoint)
+	return &documentTender{store: s}, nil
+}
+
+// load returns the object and its ETag as version.
+func (s *s3Store) load(ctx context.Context) ([]byte, string, error) {
+	body, header, status, err := s.request(ctx, http.MethodGet, s.key, nil, nil, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if status == http.StatusNotFound {
+		return nil, "", nil
+	}
+	if status != http.StatusOK {
+		return nil, "", s3Error(status, body)
+	}
+	return body, header.Get("ETag"), nil
+}
+
+// save writes the object with a conditional PUT: If-Match on the ETag it was loaded with,
+// or If-None-Match if there was none, so the write fails if another writer got in between.
+func (s *s3Store) save(ctx context.Context, content []byte, version string) error {
+	condition := http.Header{}
+	if version == "" {
+		condition.Set("If-None-Match", "*")
+	} else {
+		condition.Set("If-Match", version)
+	}
+	body, _, status, err := s.request(ctx, http.MethodPut, s.key, nil, condition, content)
+	if err != nil {
+		return err
+	}
+	// 409 is returned if a concurrent conditional write to the same key is in progress
+	if status == http.StatusPreconditionFailed || status == http.StatusConflict {
+		return errConflict
+	}
+	if status != http.StatusOK {
+		return s3Error(status, body)
+	}
+	return nil
+}
+
+// listBucketResult is the part of a ListObjectsV2 response piphos uses.
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *s3Store) namespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+	query := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+	for {
+		body, _, status, err := s.request(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if status != http.StatusOK {
+			return nil, s3Error(status, body)
+		}
+		var result listBucketResult
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		for _, object := range result.Contents {
+			name := strings.TrimPrefix(object.Key, s.prefix)
+			if namespace, ok := documentNamespace(name); ok && !strings.Contains(name, "/") {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return namespaces, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// request sends a signed request for the object key, or for the bucket if key is empty,
+// with the given additional headers, and returns the response body, headers and status.
+func (s *s3Store) request(ctx context.Context, method, key string, query url.Values, header http.Header, content []byte) ([]byte, http.Header, int, error) {
+	path := "/" + s.bucket
+	if key != "" {
+		path += "/" + key
+	}
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	u.Path = u.Path + path
+	u.RawPath = awsEscape(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(content))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.ContentLength = int64(len(content))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("User-Agent", config.PiphosUserAgent)
+	if content != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+	signV4(req, content, s.accessKey, s.secretKey, s.region, "s3", s.now())
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to get response: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close response body: %v\n", err)
+		}
+	}()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, config.MaxResponseBodySize))
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return body, resp.Header, resp.StatusCode, nil
+}
+
+// s3Error reports an unexpected response, with the error code and message S3 returned.
+func s3Error(status int, body []byte) error {
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal(body, &e) != nil || e.Code == "" {
+		return fmt.Errorf("unexpected response status: %d", status)
+	}
+	return fmt.Errorf("unexpected response status: %d (%s: %s)", status, e.Code, e.Message)
+}
+
+// signV4 adds the X-Amz-Date, X-Amz-Content-Sha256 and Authorization headers signing req
+// and its payload with AWS Signature Version 4. The host, Content-Type, Range and any
+// X-Amz-* headers are signed.
+func signV4(req *http.Request, payload []byte, accessKey, secretKey, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "content-type" || name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := slices.Sorted(maps.Keys(headers))
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscape(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery returns query sorted by key and escaped as Signature Version 4 requires.
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key, true)+"="+awsEscape(value, true))
+		}
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes every byte of s except unreserved characters and, unless
+// encodeSlash is set, slashes.
+func awsEscape(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,227 @@
+package tender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/kappapee/piphos/internal/config"
+	"github.com/kappapee/piphos/internal/validate"
+)
+
+// store keeps the document of a single namespace as a blob, see documentTender.
+type store interface {
+	// load returns the stored document and its version, or nil if nothing is stored yet.
+	load(ctx context.Context) (content []byte, version string, err error)
+	// save replaces the stored document if it is still at version, the empty version
+	// meaning nothing is stored yet. Returns errConflict if it was changed in the meantime.
+	save(ctx context.Context, content []byte, version string) error
+	// namespaces returns the names of all namespaces with a stored document.
+	namespaces(ctx context.Context) ([]string, error)
+}
+
+// errConflict is returned by store.save if the document changed since it was loaded.
+var errConflict = errors.New("hosts were changed concurrently")
+
+// maxUpdateAttempts bounds how often documentTender.update retries after a conflict.
+const maxUpdateAttempts = 5
+
+// documentTender implements Tender on top of a store holding the encoded document of all
+// hosts in one namespace, as the file and s3 tenders do. Every write reads the document,
+// changes it and writes it back whole, starting over if another writer got in between,
+// so concurrent pushes from several hosts do not lose each other's updates.
+type documentTender struct {
+	store     store
+	codec     Codec
+	heartbeat time.Duration
+	warnings  io.Writer
+}
+
+// documentName returns the name of the document of namespace: hosts.json for the default
+// namespace and hosts-<namespace>.json for any other.
+func documentName(namespace string) string {
+	if namespace == "" || namespace == config.DefaultNamespace {
+		return "hosts.json"
+	}
+	return "hosts-" + namespace + ".json"
+}
+
+// documentNamespace returns the namespace whose document is called name, reversing documentName.
+func documentNamespace(name string) (string, bool) {
+	if name == "hosts.json" {
+		return config.DefaultNamespace, true
+	}
+	inner, ok := strings.CutPrefix(name, "hosts-")
+	if !ok {
+		return "", false
+	}
+	namespace, ok := strings.CutSuffix(inner, ".json")
+	return namespace, ok && namespace != ""
+}
+
+// read returns the stored records and their version, or nil if nothing is stored yet.
+func (d *documentTender) read(ctx context.Context) (map[string]Record, string, error) {
+	content, version, err := d.store.load(ctx)
+	if err != nil || content == nil {
+		return nil, "", err
+	}
+	if d.codec != nil {
+		content, err = d.codec.Decode(content)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	hosts, err := DecodeHosts(content)
+	return hosts, version, err
+}
+
+// write encodes hosts and stores them if the stored document is still at version.
+func (d *documentTender) write(ctx context.Context, hosts map[string]Record, version string) error {
+	content, err := EncodeHosts(hosts)
+	if err != nil {
+		return err
+	}
+	if d.codec != nil {
+		content, err = d.codec.Encode(content)
+		if err != nil {
+			return err
+		}
+	}
+	return d.store.save(ctx, content, version)
+}
+
+// update reads the stored records, nil if nothing is stored yet, and lets change modify
+// them. If change reports a modification, the records are written back unless the document
+// changed in the meantime, in which case update starts over with the new document.
+func (d *documentTender) update(ctx context.Context, change func(hosts map[string]Record) (map[string]Record, bool, error)) error {
+	for attempt := 1; ; attempt++ {
+		hosts, version, err := d.read(ctx)
+		if err != nil {
+			return err
+		}
+		hosts, changed, err := change(hosts)
+		if err != nil || !changed {
+			return err
+		}
+		err = d.write(ctx, hosts, version)
+		if !errors.Is(err, errConflict) {
+			return err
+		}
+		if attempt == maxUpdateAttempts {
+			return fmt.Errorf("failed to write hosts after %d attempts: %w", attempt, err)
+		}
+		// Back off for a random time so writers that collided do not collide again
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(rand.Int64N(int64(attempt) * int64(100*time.Millisecond)))):
+		}
+	}
+}
+
+// Pull returns all stored records, nil if nothing is stored yet.
+func (d *documentTender) Pull(ctx context.Context) (map[string]Record, error) {
+	hosts, _, err := d.read(ctx)
+	return hosts, err
+}
+
+// Push stores record under hostname, which must be a valid host name. Nothing is
+// written if the address is unchanged and no heartbeat is due, see merge.
+func (d *documentTender) Push(ctx context.Context, hostname string, record Record) error {
+	if err := validateNames(hostname, record); err != nil {
+		return err
+	}
+	return d.update(ctx, func(hosts map[string]Record) (map[string]Record, bool, error) {
+		if hosts == nil {
+			return map[string]Record{hostname: record}, true, nil
+		}
+		existing, ok := hosts[hostname]
+		checkCollisions(d.warnings, hosts, hostname, existing, ok, record)
+		merged, changed := merge(existing, ok, record, d.heartbeat)
+		hosts[hostname] = merged
+		return hosts, changed, nil
+	})
+}
+
+// Delete removes the record of the specified hostname or alias.
+func (d *documentTender) Delete(ctx context.Context, name string) error {
+	return d.update(ctx, func(hosts map[string]Record) (map[string]Record, bool, error) {
+		hostname, _, ok := Resolve(hosts, name)
+		if !ok {
+			return nil, false, fmt.Errorf("%w: %s", ErrHostNotFound, name)
+		}
+		delete(hosts, hostname)
+		return hosts, true, nil
+	})
+}
+
+// Rename moves the record of the specified hostname or alias to newHostname.
+func (d *documentTender) Rename(ctx context.Context, oldName, newHostname string) error {
+	if err := validate.Hostname(newHostname); err != nil {
+		return err
+	}
+	return d.update(ctx, func(hosts map[string]Record) (map[string]Record, bool, error) {
+		oldHostname, record, ok := Resolve(hosts, oldName)
+		if !ok {
+			return nil, false, fmt.Errorf("%w: %s", ErrHostNotFound, oldName)
+		}
+		if owner, _, found := Resolve(hosts, newHostname); found && owner != oldHostname {
+			return nil, false, fmt.Errorf("%w: %s", ErrHostExists, newHostname)
+		}
+		delete(hosts, oldHostname)
+		hosts[newHostname] = record.renamed(newHostname)
+		return hosts, true, nil
+	})
+}
+
+// Replace stores hosts as the complete document.
+func (d *documentTender) Replace(ctx context.Context, hosts map[string]Record) error {
+	return d.update(ctx, func(map[string]Record) (map[string]Record, bool, error) {
+		return hosts, true, nil
+	})
+}
+
+// Update lets change modify the stored records, see Updater.
+func (d *documentTender) Update(ctx context.Context, change func(hosts map[string]Record) (map[string]Record, bool, error)) error {
+	return d.update(ctx, change)
+}
+
+// Prune removes expired hosts. Returns nil if nothing is stored yet. Hosts are checked
+// against the document they are removed from, so a host that pushed in the meantime stays.
+func (d *documentTender) Prune(ctx context.Context, expired func(hostname string, record Record) bool, dryRun bool) ([]string, error) {
+	var removed []string
+	err := d.update(ctx, func(hosts map[string]Record) (map[string]Record, bool, error) {
+		removed = nil
+		if hosts == nil {
+			return nil, false, nil
+		}
+		removed = pruneHosts(hosts, expired)
+		return hosts, !dryRun && len(removed) > 0, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// Reseal rewrites the stored records through the current codec.
+func (d *documentTender) Reseal(ctx context.Context) error {
+	return d.update(ctx, func(hosts map[string]Record) (map[string]Record, bool, error) {
+		return hosts, hosts != nil, nil
+	})
+}
+
+// Namespaces lists the namespaces with a stored document.
+func (d *documentTender) Namespaces(ctx context.Context) ([]string, error) {
+	namespaces, err := d.store.namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(namespaces)
+	return slices.Compact(namespaces), nil
+}
@@ -0,0 +1,121 @@
+package tender
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDocumentTender_Conflict(t *testing.T) {
+	ctx := context.Background()
+	s := &memStore{}
+	d := &documentTender{store: s}
+	if err := d.Push(ctx, "laptop", NewRecord("203.0.113.1")); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	// Another host pushes between this push's read and write
+	other := &documentTender{store: &memStore{}}
+	s.beforeSave = func() {
+		s.beforeSave = nil
+		other.store = s
+		if err := other.Push(ctx, "server", NewRecord("203.0.113.2")); err != nil {
+			t.Errorf("failed to push concurrently: %v", err)
+		}
+	}
+	if err := d.Push(ctx, "desktop", NewRecord("203.0.113.3")); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	hosts, err := d.Pull(ctx)
+	if err != nil {
+		t.Fatalf("failed to pull: %v", err)
+	}
+	if len(hosts) != 3 {
+		t.Errorf("expected the concurrent push to be kept but got %+v", hosts)
+	}
+}
+
+func TestDocumentTender_PruneConflict(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	old := Record{IPv4: "203.0.113.1", UpdatedAt: now.AddDate(0, -2, 0), SeenAt: now.AddDate(0, -2, 0)}
+	s := &memStore{}
+	d := &documentTender{store: s}
+	if err := d.Replace(ctx, map[string]Record{"laptop": old, "server": old}); err != nil {
+		t.Fatalf("failed to store hosts: %v", err)
+	}
+	// laptop reports in while prune is deciding
+	s.beforeSave = func() {
+		s.beforeSave = nil
+		fresh := Record{IPv4: "203.0.113.9", UpdatedAt: now, SeenAt: now}
+		if err := (&documentTender{store: s}).Push(ctx, "laptop", fresh); err != nil {
+			t.Errorf("failed to push concurrently: %v", err)
+		}
+	}
+	removed, err := d.Prune(ctx, func(_ string, r Record) bool { return r.Expired(now, 30*24*time.Hour) }, false)
+	if err != nil {
+		t.Fatalf("failed to prune: %v", err)
+	}
+	if !slices.Equal(removed, []string{"server"}) {
+		t.Errorf("expected only server to be pruned but got %v", removed)
+	}
+	hosts, _ := d.Pull(ctx)
+	if _, ok := hosts["laptop"]; !ok {
+		t.Errorf("expected laptop, which pushed during prune, to be kept but got %+v", hosts)
+	}
+}
+
+func TestDocumentTender_ConflictGivesUp(t *testing.T) {
+	ctx := context.Background()
+	s := &memStore{}
+	d := &documentTender{store: s}
+	if err := d.Push(ctx, "laptop", NewRecord("203.0.113.1")); err != nil {
+		t.Fatalf("failed to push: %v", err)
+	}
+	saves := 0
+	s.beforeSave = func() {
+		saves++
+		s.version++
+	}
+	if err := d.Push(ctx, "server", NewRecord("203.0.113.2")); err == nil {
+		t.Error("expected error after repeated conflicts but got nil")
+	}
+	if saves != maxUpdateAttempts {
+		t.Errorf("expected %d attempts but got %d", maxUpdateAttempts, saves)
+	}
+}
+
+func TestFileTender_ConcurrentPush(t *testing.T) {
+	t.Setenv("PIPHOS_FILE_DIR", t.TempDir())
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Every host has its own tender, as separate processes would
+			ft, err := New("file", Options{})
+			if err != nil {
+				t.Errorf("failed to create tender: %v", err)
+				return
+			}
+			if err := ft.Push(ctx, fmt.Sprintf("host-%d", i), NewRecord(fmt.Sprintf("203.0.113.%d", i+1))); err != nil {
+				t.Errorf("failed to push: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	ft, err := New("file", Options{})
+	if err != nil {
+		t.Fatalf("failed to create tender: %v", err)
+	}
+	hosts, err := ft.Pull(ctx)
+	if err != nil {
+		t.Fatalf("failed to pull: %v", err)
+	}
+	if len(hosts) != 8 {
+		t.Errorf("expected all 8 concurrent pushes to be kept but got %d hosts", len(hosts))
+	}
+}
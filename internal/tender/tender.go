@@ -1,42 +1,171 @@
 // Package tender provides interfaces and implementations for storing hostname-to-IP mappings.
 //
 // The Tender interface defines a storage strategy with Pull (retrieve) and Push (update)
-// operations. The primary implementation uses GitHub Gists ("gh") as a backend, storing
-// mappings in a private gist identified by the description "_piphos_".
+// operations, plus Delete and Rename for maintaining individual hosts and Replace for
+// bulk writes. The primary implementation uses GitHub Gists ("gh") as a backend, storing
+// mappings in a private gist identified by the description "_piphos_", or
+// "_piphos_<namespace>_" when a namespace is selected. The "file" and "s3" tenders keep
+// the same document in a local directory or an S3-compatible bucket.
 package tender
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"strings"
+	"time"
 
+	"github.com/kappapee/piphos/internal/config"
 	"github.com/kappapee/piphos/internal/validate"
 )
 
-// Tender defines the interface for storing and retrieving hostname-to-IP mappings.
+// Tender defines the interface for storing and retrieving host records.
 type Tender interface {
-	// Pull retrieves all hostname-to-IP mappings from storage.
-	// Returns a map where keys are hostnames and values are IP addresses.
-	Pull(ctx context.Context) (map[string]string, error)
+	// Pull retrieves all host records from storage.
+	// Returns a map where keys are hostnames and values are the hosts' records.
+	Pull(ctx context.Context) (map[string]Record, error)
+	// Push stores or updates the record for a given hostname.
+	// If the hostname already exists with the same addresses, no update is performed,
+	// unless Options.Heartbeat asks for the last-seen time to be refreshed.
+	Push(ctx context.Context, hostname string, record Record) error
+	// Delete removes the record of hostname, which may also be one of its aliases.
+	// Returns an error wrapping ErrHostNotFound if there is no such host.
+	Delete(ctx context.Context, hostname string) error
+	// Rename moves the record of oldHostname, which may also be one of its aliases, to newHostname.
+	// The record's signature is removed, since it binds the old name; the next push signs it again.
+	// Returns an error wrapping ErrHostNotFound if oldHostname does not exist,
+	// and ErrHostExists if newHostname is already taken.
+	Rename(ctx context.Context, oldHostname, newHostname string) error
+	// Replace stores hosts as the complete set of records in a single write, replacing
+	// everything stored before. Records are stored as given, without merging, so it
+	// suits bulk copies such as migrations rather than reporting a host's address.
+	Replace(ctx context.Context, hosts map[string]Record) error
+}
+
+var (
+	// ErrHostNotFound is returned when an operation targets a host that is not stored.
+	ErrHostNotFound = errors.New("host not found")
+	// ErrHostExists is returned when an operation would overwrite another host's record.
+	ErrHostExists = errors.New("host already exists")
+)
+
+// Namespacer is implemented by tenders that can list the namespaces present in their storage.
+type Namespacer interface {
+	// Namespaces returns the sorted names of all namespaces holding hosts.
+	Namespaces(ctx context.Context) ([]string, error)
+}
+
+// Pruner is implemented by tenders that can remove hosts which stopped reporting in.
+type Pruner interface {
+	// Prune removes every host for which expired returns true and returns their sorted names.
+	// With dryRun set, nothing is removed and the hosts that would be are returned.
+	// Hosts written concurrently by a push are kept if they are no longer expired.
+	Prune(ctx context.Context, expired func(hostname string, record Record) bool, dryRun bool) ([]string, error)
+}
+
+// Resealer is implemented by tenders that can rewrite their stored content unchanged,
+// e.g. to encrypt it with a new key after the codec changed.
+type Resealer interface {
+	// Reseal reads all records and writes them back through the current codec.
+	// It does nothing if nothing is stored.
+	Reseal(ctx context.Context) error
+}
+
+// Updater is implemented by tenders that can modify their stored records atomically.
+type Updater interface {
+	// Update reads all records, nil if nothing is stored, and lets change modify them.
+	// If change reports a modification, the records it returns are stored as the complete
+	// set. If another write landed in the meantime, change runs again on the fresh records,
+	// so it must derive its result from the records it is given. It is never called concurrently.
+	Update(ctx context.Context, change func(hosts map[string]Record) (map[string]Record, bool, error)) error
+}
 
-	// Push stores or updates the IP address for a given hostname.
-	// If the hostname already exists with the same IP, no update is performed.
-	Push(ctx context.Context, hostname, ip string) error
+// Codec transforms the stored content of a tender, e.g. to encrypt it client-side.
+// Every tender stores its records through the codec, so it applies to any provider.
+type Codec interface {
+	// Encode transforms the serialised records before they are stored.
+	Encode(content []byte) ([]byte, error)
+	// Decode reverses Encode on stored content.
+	Decode(content []byte) ([]byte, error)
+}
+
+// Options holds optional settings shared by all tender providers.
+// The zero value is ready to use.
+type Options struct {
+	// Verbose receives diagnostic messages, such as where credentials were found.
+	// Secrets are never written to it. Nil disables diagnostics.
+	Verbose io.Writer
+	// Namespace selects an independent set of hosts sharing the same storage account.
+	// The empty string selects the default namespace.
+	Namespace string
+	// Warnings receives warnings about suspicious writes, such as a different machine
+	// pushing under an existing hostname. Nil discards them.
+	Warnings io.Writer
+	// Codec transforms the stored content, see crypt.Keyring. Nil stores plain JSON.
+	Codec Codec
+	// Heartbeat is the minimum interval between writes refreshing a host's last-seen
+	// time while its address is unchanged. Zero only writes when the address changes.
+	Heartbeat time.Duration
+	// Report receives a line per tender telling whether a push to several tenders
+	// succeeded, see New. Nil discards it.
+	Report io.Writer
+}
+
+// logf writes a diagnostic message if verbose output is enabled.
+func (o Options) logf(format string, args ...any) {
+	if o.Verbose != nil {
+		fmt.Fprintf(o.Verbose, format+"\n", args...)
+	}
 }
 
 // New creates a Tender instance for the specified provider.
-// Supported providers are "gh" (GitHub Gists, requires PIPHOS_GITHUB_TOKEN environment variable).
+// Supported providers are "gh" (GitHub Gists), "file" (a local directory, see fileFromEnv)
+// and "s3" (an S3-compatible bucket, see s3FromEnv). A comma-separated list such as
+// "gh,s3" combines several providers: pushes write to all of them concurrently and pulls
+// merge their records, preferring the newest. Operations that fail on only some of them
+// return a FanOutError. If PIPHOS_GITHUB_APP_ID is set, "gh"
+// authenticates as a GitHub App installation. Otherwise the GitHub token is discovered from,
+// in order: PIPHOS_GITHUB_TOKEN, the file named by PIPHOS_GITHUB_TOKEN_FILE,
+// GITHUB_TOKEN, GH_TOKEN, the gh CLI hosts.yml and the OS keyring.
 // Returns an error if the provider is unknown or required credentials are missing.
-func New(tender string) (Tender, error) {
+func New(tender string, opts Options) (Tender, error) {
+	if err := validate.Namespace(opts.Namespace); err != nil {
+		return nil, err
+	}
+	if strings.Contains(tender, ",") {
+		m, err := newMulti(strings.Split(tender, ","), opts)
+		if err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	var d *documentTender
+	var err error
 	switch tender {
+	case fileName:
+		d, err = fileFromEnv(opts)
+	case s3Name:
+		d, err = s3FromEnv(opts)
 	case "gh":
-		token := os.Getenv("PIPHOS_GITHUB_TOKEN")
-		err := validate.Token(token)
+		gh, err := githubFromEnv(opts)
 		if err != nil {
 			return nil, err
 		}
-		return newGithub(token), nil
+		gh.stamp = config.Stamp(opts.Namespace)
+		gh.heartbeat = opts.Heartbeat
+		gh.verbose = opts.Verbose
+		gh.warnings = opts.Warnings
+		gh.codec = opts.Codec
+		return gh, nil
 	default:
 		return nil, fmt.Errorf("unknown tender: %s", tender)
 	}
+	if err != nil {
+		return nil, err
+	}
+	d.heartbeat = opts.Heartbeat
+	d.warnings = opts.Warnings
+	d.codec = opts.Codec
+	return d, nil
 }
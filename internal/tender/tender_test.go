@@ -1,7 +1,8 @@
 package tender
 
 import (
-	"os"
+	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -33,13 +34,9 @@ func TestNew(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if tt.token != "" {
-				os.Setenv("PIPHOS_GITHUB_TOKEN", tt.token)
-			} else {
-				os.Unsetenv("PIPHOS_GITHUB_TOKEN")
-			}
-			defer os.Unsetenv("PIPHOS_GITHUB_TOKEN")
-			tender, err := New(tt.tender)
+			isolateCredentials(t)
+			t.Setenv("PIPHOS_GITHUB_TOKEN", tt.token)
+			tender, err := New(tt.tender, Options{})
 			if tt.expectedError {
 				if err == nil {
 					t.Error("expected error but got nil")
@@ -58,3 +55,18 @@ func TestNew(t *testing.T) {
 		})
 	}
 }
+
+func TestNewVerbose(t *testing.T) {
+	isolateCredentials(t)
+	t.Setenv("GH_TOKEN", "secret-token")
+	var verbose bytes.Buffer
+	if _, err := New("gh", Options{Verbose: &verbose}); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if !strings.Contains(verbose.String(), "GH_TOKEN") {
+		t.Errorf("expected verbose output to name the token source but got: %q", verbose.String())
+	}
+	if strings.Contains(verbose.String(), "secret-token") {
+		t.Error("verbose output must not contain the token")
+	}
+}
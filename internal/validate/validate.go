@@ -4,8 +4,34 @@ package validate
 import (
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
 )
 
+// Token kinds reported by TokenKind, derived from GitHub's token prefixes.
+const (
+	TokenClassic      = "classic"
+	TokenFineGrained  = "fine-grained"
+	TokenOAuth        = "oauth"
+	TokenUserToServer = "user-to-server"
+	TokenInstallation = "installation"
+	TokenRefresh      = "refresh"
+	TokenUnknown      = "unknown"
+)
+
+// tokenPrefixes maps GitHub token prefixes to their kind.
+var tokenPrefixes = []struct {
+	prefix string
+	kind   string
+}{
+	{"github_pat_", TokenFineGrained},
+	{"ghp_", TokenClassic},
+	{"gho_", TokenOAuth},
+	{"ghu_", TokenUserToServer},
+	{"ghs_", TokenInstallation},
+	{"ghr_", TokenRefresh},
+}
+
 // Command validates that no unexpected non-flag arguments were provided.
 // Returns an error if nonFlagArgs is greater than zero.
 func Command(nonFlagArgs int) error {
@@ -15,6 +41,18 @@ func Command(nonFlagArgs int) error {
 	return nil
 }
 
+// CommandArgs validates that the number of non-flag arguments is between minArgs and maxArgs.
+// Returns an error describing the expected count otherwise.
+func CommandArgs(nonFlagArgs, minArgs, maxArgs int) error {
+	if nonFlagArgs < minArgs {
+		return fmt.Errorf("%d missing argument(s)", minArgs-nonFlagArgs)
+	}
+	if nonFlagArgs > maxArgs {
+		return fmt.Errorf("%d unexpected argument(s)", nonFlagArgs-maxArgs)
+	}
+	return nil
+}
+
 // IP validates that the provided string is a valid IP address format.
 // Returns an error if the IP address cannot be parsed.
 func IP(ip string) error {
@@ -24,11 +62,246 @@ func IP(ip string) error {
 	return nil
 }
 
-// Token validates that the provided authentication token is non-empty.
-// Returns an error if the token is empty.
+// Token validates that the provided authentication token is non-empty and,
+// for tokens carrying a known GitHub prefix, that it has the expected shape.
+// Tokens without a known prefix (legacy 40 character tokens, enterprise tokens) are accepted.
+// Returns an error with a hint if the token cannot be used as a bearer token.
 func Token(token string) error {
 	if token == "" {
 		return fmt.Errorf("invalid token: token is empty")
 	}
+	if strings.ContainsAny(token, " \t\r\n") {
+		return fmt.Errorf("invalid token: token contains whitespace (hint: store the bare token without a \"Bearer\" or \"token\" prefix)")
+	}
+	kind := TokenKind(token)
+	if kind == TokenUnknown {
+		return nil
+	}
+	if kind == TokenRefresh {
+		return fmt.Errorf("invalid token: %s token cannot authenticate API requests (hint: use the access token it refreshes)", kind)
+	}
+	for _, p := range tokenPrefixes {
+		if p.kind != kind {
+			continue
+		}
+		body := strings.TrimPrefix(token, p.prefix)
+		if body == "" || strings.IndexFunc(body, func(r rune) bool { return !isTokenRune(r) }) != -1 {
+			return fmt.Errorf("invalid token: malformed %s token (expected %s followed by letters, digits or underscores)", kind, p.prefix)
+		}
+	}
 	return nil
 }
+
+// TokenKind returns the kind of a GitHub token based on its prefix,
+// or TokenUnknown if the prefix is not recognised.
+func TokenKind(token string) string {
+	for _, p := range tokenPrefixes {
+		if strings.HasPrefix(token, p.prefix) {
+			return p.kind
+		}
+	}
+	return TokenUnknown
+}
+
+// isTokenRune reports whether r may appear in the body of a GitHub token.
+func isTokenRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// GithubAppID validates a GitHub App or installation identifier, which must be a positive integer.
+// The name is used in the error message to tell which identifier is wrong.
+func GithubAppID(name, id string) error {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("invalid %s %q: expected a positive integer", name, id)
+	}
+	return nil
+}
+
+// maxNamespaceLength bounds namespace names, which become part of gist filenames.
+const maxNamespaceLength = 32
+
+// Namespace validates a namespace name: at most 32 lowercase letters, digits
+// or hyphens, not starting or ending with a hyphen. The empty string selects
+// the default namespace and is valid.
+func Namespace(namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	if len(namespace) > maxNamespaceLength {
+		return fmt.Errorf("invalid namespace %q: longer than %d characters", namespace, maxNamespaceLength)
+	}
+	if strings.HasPrefix(namespace, "-") || strings.HasSuffix(namespace, "-") {
+		return fmt.Errorf("invalid namespace %q: must not start or end with a hyphen", namespace)
+	}
+	for _, r := range namespace {
+		if !(r == '-' || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')) {
+			return fmt.Errorf("invalid namespace %q: only lowercase letters, digits and hyphens are allowed", namespace)
+		}
+	}
+	return nil
+}
+
+// Limits of RFC 1123 on host names and on each of their dot-separated labels.
+const (
+	maxHostnameLength = 253
+	maxLabelLength    = 63
+)
+
+// Hostname validates a host name as piphos stores it: at most 253 characters of
+// dot-separated labels, each 1 to 63 lowercase letters, digits or hyphens that neither
+// starts nor ends with a hyphen, following RFC 1123. Names that NormalizeHostname can
+// fix are rejected with the normalized form as a suggestion.
+func Hostname(hostname string) error {
+	err := checkHostname(hostname)
+	if err == nil {
+		return nil
+	}
+	if suggestion, nerr := NormalizeHostname(hostname, false); nerr == nil && suggestion != hostname {
+		return fmt.Errorf("%w, use %q instead", err, suggestion)
+	}
+	return err
+}
+
+// checkHostname applies the rules of Hostname without suggesting a fix.
+func checkHostname(hostname string) error {
+	if hostname == "" {
+		return fmt.Errorf("invalid hostname: hostname is empty")
+	}
+	if len(hostname) > maxHostnameLength {
+		return fmt.Errorf("invalid hostname %q: longer than %d characters", hostname, maxHostnameLength)
+	}
+	for _, label := range strings.Split(hostname, ".") {
+		if label == "" {
+			return fmt.Errorf("invalid hostname %q: empty label", hostname)
+		}
+		if len(label) > maxLabelLength {
+			return fmt.Errorf("invalid hostname %q: label %q longer than %d characters", hostname, label, maxLabelLength)
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("invalid hostname %q: label %q starts or ends with a hyphen", hostname, label)
+		}
+		for _, r := range label {
+			if r >= 'A' && r <= 'Z' {
+				return fmt.Errorf("invalid hostname %q: uppercase letters are not allowed", hostname)
+			}
+			if !(r == '-' || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')) {
+				return fmt.Errorf("invalid hostname %q: only lowercase letters, digits, hyphens and dots are allowed", hostname)
+			}
+		}
+	}
+	return nil
+}
+
+// NormalizeHostname turns a name such as the system's hostname into one that Hostname
+// accepts: it drops a trailing dot, lowercases the name, replaces underscores and spaces
+// with hyphens and encodes labels with other characters as punycode (e.g. "xn--bcher-kva"
+// for "bücher"), as internationalized domain names are. With stripDomain, only the first
+// label is kept, so "Laptop.local" becomes "laptop".
+// Returns an error if the result is still not a valid hostname.
+func NormalizeHostname(name string, stripDomain bool) (string, error) {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if stripDomain {
+		name, _, _ = strings.Cut(name, ".")
+	}
+	labels := strings.Split(name, ".")
+	for i, label := range labels {
+		label = strings.NewReplacer("_", "-", " ", "-").Replace(label)
+		if strings.IndexFunc(label, func(r rune) bool { return r >= 0x80 }) >= 0 {
+			label = "xn--" + punycode(label)
+		}
+		labels[i] = label
+	}
+	normalized := strings.Join(labels, ".")
+	if err := checkHostname(normalized); err != nil {
+		return "", err
+	}
+	return normalized, nil
+}
+
+// Parameters of the punycode encoding, see RFC 3492.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// punycode encodes label following RFC 3492, without the "xn--" prefix.
+func punycode(label string) string {
+	runes := []rune(label)
+	var out []byte
+	for _, r := range runes {
+		if r < 0x80 {
+			out = append(out, byte(r))
+		}
+	}
+	basic := len(out)
+	handled := basic
+	if basic > 0 {
+		out = append(out, '-')
+	}
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for handled < len(runes) {
+		// The smallest code point not handled yet
+		m := int(^uint32(0) >> 1)
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := min(max(k-bias, punyTMin), punyTMax)
+				if q < t {
+					break
+				}
+				out = append(out, punyDigit(t+(q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out = append(out, punyDigit(q))
+			bias = punyAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return string(out)
+}
+
+// punyAdapt returns the bias for the next code point, see RFC 3492 section 6.1.
+func punyAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+// punyDigit returns the character encoding the punycode digit d.
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
@@ -0,0 +1,156 @@
+// Package watch keeps a host's record up to date from a long-running process: it detects
+// the public IP on an interval and pushes only when it changed or a heartbeat is due.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/kappapee/piphos/internal/beacon"
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+const (
+	// jitter is the fraction by which each interval is randomly shortened or lengthened,
+	// so hosts started together do not keep hitting the beacon and tender at once.
+	jitter = 0.1
+	// minBackoff is the delay after the first failed check, doubled on every further failure.
+	minBackoff = 15 * time.Second
+	// maxBackoff bounds the delay between failed checks, unless the interval is longer.
+	maxBackoff = 30 * time.Minute
+)
+
+// Clock tells the time and waits. Tests replace the system clock with a fake one.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock of the running system.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Watcher periodically detects the public IP with Beacon and pushes the record of Hostname.
+// The last pushed IP is kept in memory, so the tender is only written when the IP changed,
+// on the first check, or when Heartbeat is due.
+type Watcher struct {
+	Beacon   beacon.Beacon
+	Tender   tender.Tender
+	Hostname string
+	// NewRecord builds the record pushed for ip detected at now.
+	NewRecord func(ctx context.Context, ip string, now time.Time) (tender.Record, error)
+	// Interval is the time between checks.
+	Interval time.Duration
+	// Heartbeat is the interval at which the record is pushed although the IP is unchanged.
+	// Zero only pushes when the IP changes.
+	Heartbeat time.Duration
+	// Observe is called after every successful check, e.g. to run hooks. Nil does nothing.
+	Observe func(ctx context.Context)
+	// Log receives a line for every decision. Nil discards them.
+	Log io.Writer
+	// Clock defaults to the system clock.
+	Clock Clock
+
+	// random returns a number in [0, 1) for the jitter, see rand.Float64.
+	random   func() float64
+	lastIP   string
+	lastPush time.Time
+}
+
+// Run checks and pushes until ctx is done, which is not an error. Failed checks are
+// logged and retried with exponential backoff, starting at 15s and doubling up to 30m
+// or Interval, whichever is longer.
+func (w *Watcher) Run(ctx context.Context) error {
+	if w.Interval <= 0 {
+		return fmt.Errorf("invalid interval: %s", w.Interval)
+	}
+	if w.Clock == nil {
+		w.Clock = systemClock{}
+	}
+	if w.random == nil {
+		w.random = rand.Float64
+	}
+	failures := 0
+	for {
+		delay := w.next()
+		if err := w.check(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			failures++
+			delay = backoff(failures, w.Interval)
+			w.logf("%v, retrying in %s", err, delay)
+		} else {
+			failures = 0
+			if w.Observe != nil {
+				w.Observe(ctx)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-w.Clock.After(delay):
+		}
+	}
+}
+
+// check detects the IP once and pushes the record if needed.
+func (w *Watcher) check(ctx context.Context) error {
+	ip, err := w.Beacon.Ping(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to detect IP: %w", err)
+	}
+	now := w.Clock.Now()
+	var reason string
+	switch {
+	case w.lastIP == "":
+		reason = fmt.Sprintf("IP is %s, pushing", ip)
+	case ip != w.lastIP:
+		reason = fmt.Sprintf("IP changed from %s to %s, pushing", w.lastIP, ip)
+	case w.Heartbeat > 0 && now.Sub(w.lastPush) >= w.Heartbeat:
+		reason = fmt.Sprintf("IP %s unchanged, pushing heartbeat", ip)
+	default:
+		w.logf("IP %s unchanged, nothing to push", ip)
+		return nil
+	}
+	record, err := w.NewRecord(ctx, ip, now)
+	if err != nil {
+		return err
+	}
+	w.logf("%s", reason)
+	if err := w.Tender.Push(ctx, w.Hostname, record); err != nil {
+		return fmt.Errorf("failed to push: %w", err)
+	}
+	w.lastIP, w.lastPush = ip, now
+	return nil
+}
+
+// next returns Interval shortened or lengthened by up to the jitter fraction.
+func (w *Watcher) next() time.Duration {
+	return w.Interval + time.Duration((2*w.random()-1)*jitter*float64(w.Interval))
+}
+
+// backoff returns the delay after the given number of consecutive failures.
+func backoff(failures int, interval time.Duration) time.Duration {
+	limit := max(maxBackoff, interval)
+	delay := minBackoff
+	for range failures - 1 {
+		delay *= 2
+		if delay >= limit {
+			return limit
+		}
+	}
+	return delay
+}
+
+// logf writes a timestamped line to Log if it is set.
+func (w *Watcher) logf(format string, args ...any) {
+	if w.Log != nil {
+		fmt.Fprintf(w.Log, "%s "+format+"\n", append([]any{w.Clock.Now().Format(time.RFC3339)}, args...)...)
+	}
+}
@@ -0,0 +1,218 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kappapee/piphos/internal/tender"
+)
+
+// fakeClock advances by every duration waited for, without sleeping.
+type fakeClock struct {
+	now    time.Time
+	waited []time.Duration
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waited = append(c.waited, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// fakeBeacon returns the IPs in order, an empty IP meaning a failure,
+// and cancels the watch once they are used up.
+type fakeBeacon struct {
+	ips    []string
+	cancel context.CancelFunc
+}
+
+func (b *fakeBeacon) Ping(ctx context.Context) (string, error) {
+	if len(b.ips) == 0 {
+		b.cancel()
+		return "", ctx.Err()
+	}
+	ip := b.ips[0]
+	b.ips = b.ips[1:]
+	if ip == "" {
+		return "", errors.New("beacon unreachable")
+	}
+	return ip, nil
+}
+
+// fakeTender records pushed IPs, failing the pushes listed in failures.
+type fakeTender struct {
+	pushed   []string
+	failures int
+}
+
+func (t *fakeTender) Pull(context.Context) (map[string]tender.Record, error) { return nil, nil }
+
+func (t *fakeTender) Push(_ context.Context, _ string, record tender.Record) error {
+	if t.failures > 0 {
+		t.failures--
+		return errors.New("rate limited")
+	}
+	t.pushed = append(t.pushed, record.IP())
+	return nil
+}
+
+func (t *fakeTender) Delete(context.Context, string) error { return nil }
+
+func (t *fakeTender) Rename(context.Context, string, string) error { return nil }
+
+func (t *fakeTender) Replace(context.Context, map[string]tender.Record) error { return nil }
+
+func TestWatcher(t *testing.T) {
+	tests := []struct {
+		name           string
+		ips            []string
+		pushFailures   int
+		interval       time.Duration
+		heartbeat      time.Duration
+		expectedPushed []string
+		expectedWaited []time.Duration
+	}{
+		{
+			name:           "push on change only",
+			ips:            []string{"203.0.113.1", "203.0.113.1", "203.0.113.2"},
+			interval:       5 * time.Minute,
+			expectedPushed: []string{"203.0.113.1", "203.0.113.2"},
+			expectedWaited: []time.Duration{5 * time.Minute, 5 * time.Minute, 5 * time.Minute},
+		},
+		{
+			name:           "heartbeat",
+			ips:            []string{"203.0.113.1", "203.0.113.1", "203.0.113.1"},
+			interval:       30 * time.Minute,
+			heartbeat:      time.Hour,
+			expectedPushed: []string{"203.0.113.1", "203.0.113.1"},
+			expectedWaited: []time.Duration{30 * time.Minute, 30 * time.Minute, 30 * time.Minute},
+		},
+		{
+			name:           "backoff on beacon errors",
+			ips:            []string{"", "", "", "203.0.113.1"},
+			interval:       5 * time.Minute,
+			expectedPushed: []string{"203.0.113.1"},
+			expectedWaited: []time.Duration{15 * time.Second, 30 * time.Second, time.Minute, 5 * time.Minute},
+		},
+		{
+			name:           "failed push is retried",
+			ips:            []string{"203.0.113.1", "203.0.113.1"},
+			pushFailures:   1,
+			interval:       5 * time.Minute,
+			expectedPushed: []string{"203.0.113.1"},
+			expectedWaited: []time.Duration{15 * time.Second, 5 * time.Minute},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			clock := &fakeClock{now: time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)}
+			ft := &fakeTender{failures: tt.pushFailures}
+			var log bytes.Buffer
+			w := &Watcher{
+				Beacon:   &fakeBeacon{ips: tt.ips, cancel: cancel},
+				Tender:   ft,
+				Hostname: "server",
+				NewRecord: func(ctx context.Context, ip string, now time.Time) (tender.Record, error) {
+					return tender.NewRecord(ip), nil
+				},
+				Interval:  tt.interval,
+				Heartbeat: tt.heartbeat,
+				Log:       &log,
+				Clock:     clock,
+				random:    func() float64 { return 0.5 },
+			}
+			if err := w.Run(ctx); err != nil {
+				t.Fatalf("expected no error but got: %v", err)
+			}
+			if !slices.Equal(ft.pushed, tt.expectedPushed) {
+				t.Errorf("expected pushes %v but got %v", tt.expectedPushed, ft.pushed)
+			}
+			if !slices.Equal(clock.waited, tt.expectedWaited) {
+				t.Errorf("expected waits %v but got %v", tt.expectedWaited, clock.waited)
+			}
+			if lines := strings.Count(log.String(), "\n"); lines < len(tt.ips) {
+				t.Errorf("expected a log line per check but got: %q", log.String())
+			}
+		})
+	}
+}
+
+func TestWatcher_Jitter(t *testing.T) {
+	w := &Watcher{Interval: 10 * time.Minute}
+	for _, tt := range []struct {
+		random   float64
+		expected time.Duration
+	}{
+		{random: 0, expected: 9 * time.Minute},
+		{random: 0.5, expected: 10 * time.Minute},
+		{random: 0.75, expected: 10*time.Minute + 30*time.Second},
+	} {
+		w.random = func() float64 { return tt.random }
+		if d := w.next(); d != tt.expected {
+			t.Errorf("expected %s for %v but got %s", tt.expected, tt.random, d)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name          string
+		failures      int
+		interval      time.Duration
+		expectedDelay time.Duration
+	}{
+		{name: "first failure", failures: 1, interval: 5 * time.Minute, expectedDelay: 15 * time.Second},
+		{name: "doubling", failures: 4, interval: 5 * time.Minute, expectedDelay: 2 * time.Minute},
+		{name: "capped", failures: 20, interval: 5 * time.Minute, expectedDelay: 30 * time.Minute},
+		{name: "long interval", failures: 20, interval: 2 * time.Hour, expectedDelay: 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := backoff(tt.failures, tt.interval); d != tt.expectedDelay {
+				t.Errorf("expected %s but got %s", tt.expectedDelay, d)
+			}
+		})
+	}
+}
+
+func TestWatcher_InvalidInterval(t *testing.T) {
+	w := &Watcher{}
+	if err := w.Run(context.Background()); err == nil {
+		t.Error("expected error but got nil")
+	}
+}
+
+func TestWatcher_Observe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	observed := 0
+	w := &Watcher{
+		Beacon:   &fakeBeacon{ips: []string{"203.0.113.1", "", "203.0.113.1"}, cancel: cancel},
+		Tender:   &fakeTender{},
+		Hostname: "server",
+		NewRecord: func(ctx context.Context, ip string, now time.Time) (tender.Record, error) {
+			return tender.NewRecord(ip), nil
+		},
+		Interval: 5 * time.Minute,
+		Observe:  func(context.Context) { observed++ },
+		Clock:    &fakeClock{},
+		random:   func() float64 { return 0.5 },
+	}
+	if err := w.Run(ctx); err != nil {
+		t.Fatalf("expected no error but got: %v", err)
+	}
+	if observed != 2 {
+		t.Errorf("expected an observation per successful check but got %d", observed)
+	}
+}